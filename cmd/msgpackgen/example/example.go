@@ -0,0 +1,46 @@
+// Package example shows the methods written by msgpackgen.
+package example
+
+import "time"
+
+//go:generate go run msgpackconv/cmd/msgpackgen
+
+// Point is written as a map with the keys "x" and "y".
+//
+//msgpack:generate
+type Point struct {
+	X int `msgpack:"x"`
+	Y int `msgpack:"y"`
+}
+
+// Vec is written as the array [x, y, z].
+//
+//msgpack:generate
+type Vec struct {
+	_msgpack struct{} `msgpack:",asarray"`
+	X, Y, Z  float32
+}
+
+// Shape covers every field kind msgpackgen writes directly, and a few that
+// fall back to reflection.
+//
+//msgpack:generate
+type Shape struct {
+	Name     string            `msgpack:"name"`
+	Closed   bool              `msgpack:"closed,omitempty"`
+	Points   []Point           `msgpack:"points"`
+	Center   *Point            `msgpack:"center,omitempty"`
+	Normal   Vec               `msgpack:"normal"`
+	Origin   Vec               `msgpack:"origin,omitempty"`
+	Weights  []float64         `msgpack:"weights,omitempty"`
+	Layer    uint8             `msgpack:"layer"`
+	Depth    int16             `msgpack:"depth"`
+	Labels   map[string]string `msgpack:"labels,omitempty"`
+	Children []*Shape          `msgpack:"children,omitempty"`
+	Data     []byte            `msgpack:"data,omitempty"`
+	Extra    map[int]string    `msgpack:"extra,omitempty"`
+	Meta     interface{}       `msgpack:"meta,omitempty"`
+	Bounds   [2]Point          `msgpack:"bounds,omitempty"`
+	Created  time.Time         `msgpack:"-"`
+	internal int
+}
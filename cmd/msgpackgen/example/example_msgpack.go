@@ -0,0 +1,882 @@
+// Code generated by msgpackgen. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+	"maps"
+	"msgpackconv/msgpack"
+	"slices"
+)
+
+// MarshalMsgpack returns the message pack encoding of x.
+func (x Point) MarshalMsgpack() ([]byte, error) {
+	return x.AppendMsgpack(make([]byte, 0, x.EncodedSize()))
+}
+
+// AppendMsgpack appends the message pack encoding of x to b.
+func (x Point) AppendMsgpack(b []byte) ([]byte, error) {
+	var err error
+	b = msgpack.AppendMapHeader(b, 2)
+	b = msgpack.AppendString(b, "x")
+	b = msgpack.AppendInt(b, int64(x.X))
+	b = msgpack.AppendString(b, "y")
+	b = msgpack.AppendInt(b, int64(x.Y))
+	return b, err
+}
+
+// EncodedSize returns the length of the message pack encoding of x.
+func (x Point) EncodedSize() int {
+	n := 0
+	n += msgpack.MapHeaderSize(2)
+	n += msgpack.StringSize("x")
+	n += msgpack.IntSize(int64(x.X))
+	n += msgpack.StringSize("y")
+	n += msgpack.IntSize(int64(x.Y))
+	return n
+}
+
+// UnmarshalMsgpack decodes the message pack encoding of a single value into x.
+func (x *Point) UnmarshalMsgpack(b []byte) error {
+	rest, err := x.ReadMsgpack(b)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return msgpack.ErrInvalidMsgPack
+	}
+	return nil
+}
+
+// ReadMsgpack decodes the first value of b into x and returns the rest of b.
+func (x *Point) ReadMsgpack(b []byte) ([]byte, error) {
+	rest, isNil := msgpack.ReadNil(b)
+	if isNil {
+		return rest, nil
+	}
+	var err error
+	var n int
+	if msgpack.PeekKind(b) == msgpack.ArrayKind {
+		if n, rest, err = msgpack.ReadArrayHeader(b); err != nil {
+			return b, err
+		}
+		if n != 2 {
+			return b, fmt.Errorf("%w: array of %d elements into Point with 2 fields", msgpack.ErrArrayLength, n)
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+		} else {
+			var v0 int64
+			if v0, rest, err = msgpack.ReadInt(rest, 64); err != nil {
+				return b, err
+			}
+			x.X = int(v0)
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+		} else {
+			var v0 int64
+			if v0, rest, err = msgpack.ReadInt(rest, 64); err != nil {
+				return b, err
+			}
+			x.Y = int(v0)
+		}
+		return rest, nil
+	}
+	if n, rest, err = msgpack.ReadMapHeader(b); err != nil {
+		return b, err
+	}
+	for range n {
+		var key string
+		if key, rest, err = msgpack.ReadString(rest); err != nil {
+			return b, err
+		}
+		switch key {
+		case "x":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+			} else {
+				var v0 int64
+				if v0, rest, err = msgpack.ReadInt(rest, 64); err != nil {
+					return b, err
+				}
+				x.X = int(v0)
+			}
+		case "y":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+			} else {
+				var v0 int64
+				if v0, rest, err = msgpack.ReadInt(rest, 64); err != nil {
+					return b, err
+				}
+				x.Y = int(v0)
+			}
+		default:
+			if rest, err = msgpack.Skip(rest); err != nil {
+				return b, err
+			}
+		}
+	}
+	return rest, nil
+}
+
+// MarshalMsgpack returns the message pack encoding of x.
+func (x Vec) MarshalMsgpack() ([]byte, error) {
+	return x.AppendMsgpack(make([]byte, 0, x.EncodedSize()))
+}
+
+// AppendMsgpack appends the message pack encoding of x to b.
+func (x Vec) AppendMsgpack(b []byte) ([]byte, error) {
+	var err error
+	b = msgpack.AppendArrayHeader(b, 3)
+	b = msgpack.AppendFloat32(b, x.X)
+	b = msgpack.AppendFloat32(b, x.Y)
+	b = msgpack.AppendFloat32(b, x.Z)
+	return b, err
+}
+
+// EncodedSize returns the length of the message pack encoding of x.
+func (x Vec) EncodedSize() int {
+	n := 0
+	n += msgpack.ArrayHeaderSize(3)
+	n += 5
+	n += 5
+	n += 5
+	return n
+}
+
+// UnmarshalMsgpack decodes the message pack encoding of a single value into x.
+func (x *Vec) UnmarshalMsgpack(b []byte) error {
+	rest, err := x.ReadMsgpack(b)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return msgpack.ErrInvalidMsgPack
+	}
+	return nil
+}
+
+// ReadMsgpack decodes the first value of b into x and returns the rest of b.
+func (x *Vec) ReadMsgpack(b []byte) ([]byte, error) {
+	rest, isNil := msgpack.ReadNil(b)
+	if isNil {
+		return rest, nil
+	}
+	var err error
+	var n int
+	if msgpack.PeekKind(b) == msgpack.ArrayKind {
+		if n, rest, err = msgpack.ReadArrayHeader(b); err != nil {
+			return b, err
+		}
+		if n != 3 {
+			return b, fmt.Errorf("%w: array of %d elements into Vec with 3 fields", msgpack.ErrArrayLength, n)
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+		} else {
+			var v0 float64
+			if v0, rest, err = msgpack.ReadFloat(rest, 32); err != nil {
+				return b, err
+			}
+			x.X = float32(v0)
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+		} else {
+			var v0 float64
+			if v0, rest, err = msgpack.ReadFloat(rest, 32); err != nil {
+				return b, err
+			}
+			x.Y = float32(v0)
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+		} else {
+			var v0 float64
+			if v0, rest, err = msgpack.ReadFloat(rest, 32); err != nil {
+				return b, err
+			}
+			x.Z = float32(v0)
+		}
+		return rest, nil
+	}
+	if n, rest, err = msgpack.ReadMapHeader(b); err != nil {
+		return b, err
+	}
+	for range n {
+		var key string
+		if key, rest, err = msgpack.ReadString(rest); err != nil {
+			return b, err
+		}
+		switch key {
+		case "X":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+			} else {
+				var v0 float64
+				if v0, rest, err = msgpack.ReadFloat(rest, 32); err != nil {
+					return b, err
+				}
+				x.X = float32(v0)
+			}
+		case "Y":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+			} else {
+				var v0 float64
+				if v0, rest, err = msgpack.ReadFloat(rest, 32); err != nil {
+					return b, err
+				}
+				x.Y = float32(v0)
+			}
+		case "Z":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+			} else {
+				var v0 float64
+				if v0, rest, err = msgpack.ReadFloat(rest, 32); err != nil {
+					return b, err
+				}
+				x.Z = float32(v0)
+			}
+		default:
+			if rest, err = msgpack.Skip(rest); err != nil {
+				return b, err
+			}
+		}
+	}
+	return rest, nil
+}
+
+// MarshalMsgpack returns the message pack encoding of x.
+func (x Shape) MarshalMsgpack() ([]byte, error) {
+	return x.AppendMsgpack(make([]byte, 0, x.EncodedSize()))
+}
+
+// AppendMsgpack appends the message pack encoding of x to b.
+func (x Shape) AppendMsgpack(b []byte) ([]byte, error) {
+	var err error
+	fields := 5
+	if x.Closed {
+		fields++
+	}
+	if x.Center != nil {
+		fields++
+	}
+	if x.Origin.X != 0 || x.Origin.Y != 0 || x.Origin.Z != 0 {
+		fields++
+	}
+	if x.Weights != nil {
+		fields++
+	}
+	if x.Labels != nil {
+		fields++
+	}
+	if x.Children != nil {
+		fields++
+	}
+	if x.Data != nil {
+		fields++
+	}
+	if x.Extra != nil {
+		fields++
+	}
+	if x.Meta != nil {
+		fields++
+	}
+	if x.Bounds != *new([2]Point) {
+		fields++
+	}
+	b = msgpack.AppendMapHeader(b, fields)
+	b = msgpack.AppendString(b, "name")
+	b = msgpack.AppendString(b, x.Name)
+	if x.Closed {
+		b = msgpack.AppendString(b, "closed")
+		b = msgpack.AppendBool(b, x.Closed)
+	}
+	b = msgpack.AppendString(b, "points")
+	if x.Points == nil {
+		b = msgpack.AppendNil(b)
+	} else {
+		b = msgpack.AppendArrayHeader(b, len(x.Points))
+		for _, e0 := range x.Points {
+			if b, err = e0.AppendMsgpack(b); err != nil {
+				return b, err
+			}
+		}
+	}
+	if x.Center != nil {
+		b = msgpack.AppendString(b, "center")
+		if x.Center == nil {
+			b = msgpack.AppendNil(b)
+		} else {
+			if b, err = (*x.Center).AppendMsgpack(b); err != nil {
+				return b, err
+			}
+		}
+	}
+	b = msgpack.AppendString(b, "normal")
+	if b, err = x.Normal.AppendMsgpack(b); err != nil {
+		return b, err
+	}
+	if x.Origin.X != 0 || x.Origin.Y != 0 || x.Origin.Z != 0 {
+		b = msgpack.AppendString(b, "origin")
+		if b, err = x.Origin.AppendMsgpack(b); err != nil {
+			return b, err
+		}
+	}
+	if x.Weights != nil {
+		b = msgpack.AppendString(b, "weights")
+		if x.Weights == nil {
+			b = msgpack.AppendNil(b)
+		} else {
+			b = msgpack.AppendArrayHeader(b, len(x.Weights))
+			for _, e0 := range x.Weights {
+				b = msgpack.AppendFloat64(b, e0)
+			}
+		}
+	}
+	b = msgpack.AppendString(b, "layer")
+	b = msgpack.AppendUint(b, uint64(x.Layer))
+	b = msgpack.AppendString(b, "depth")
+	b = msgpack.AppendInt(b, int64(x.Depth))
+	if x.Labels != nil {
+		b = msgpack.AppendString(b, "labels")
+		if x.Labels == nil {
+			b = msgpack.AppendNil(b)
+		} else {
+			b = msgpack.AppendMapHeader(b, len(x.Labels))
+			for _, k0 := range slices.Sorted(maps.Keys(x.Labels)) {
+				b = msgpack.AppendString(b, k0)
+				b = msgpack.AppendString(b, x.Labels[k0])
+			}
+		}
+	}
+	if x.Children != nil {
+		b = msgpack.AppendString(b, "children")
+		if x.Children == nil {
+			b = msgpack.AppendNil(b)
+		} else {
+			b = msgpack.AppendArrayHeader(b, len(x.Children))
+			for _, e0 := range x.Children {
+				if e0 == nil {
+					b = msgpack.AppendNil(b)
+				} else {
+					if b, err = (*e0).AppendMsgpack(b); err != nil {
+						return b, err
+					}
+				}
+			}
+		}
+	}
+	if x.Data != nil {
+		b = msgpack.AppendString(b, "data")
+		if x.Data == nil {
+			b = msgpack.AppendNil(b)
+		} else {
+			b = msgpack.AppendBytes(b, x.Data)
+		}
+	}
+	if x.Extra != nil {
+		b = msgpack.AppendString(b, "extra")
+		if b, err = msgpack.AppendAny(b, x.Extra); err != nil {
+			return b, err
+		}
+	}
+	if x.Meta != nil {
+		b = msgpack.AppendString(b, "meta")
+		if b, err = msgpack.AppendAny(b, x.Meta); err != nil {
+			return b, err
+		}
+	}
+	if x.Bounds != *new([2]Point) {
+		b = msgpack.AppendString(b, "bounds")
+		if b, err = msgpack.AppendAny(b, x.Bounds); err != nil {
+			return b, err
+		}
+	}
+	return b, err
+}
+
+// EncodedSize returns the length of the message pack encoding of x.
+func (x Shape) EncodedSize() int {
+	n := 0
+	fields := 5
+	if x.Closed {
+		fields++
+	}
+	if x.Center != nil {
+		fields++
+	}
+	if x.Origin.X != 0 || x.Origin.Y != 0 || x.Origin.Z != 0 {
+		fields++
+	}
+	if x.Weights != nil {
+		fields++
+	}
+	if x.Labels != nil {
+		fields++
+	}
+	if x.Children != nil {
+		fields++
+	}
+	if x.Data != nil {
+		fields++
+	}
+	if x.Extra != nil {
+		fields++
+	}
+	if x.Meta != nil {
+		fields++
+	}
+	if x.Bounds != *new([2]Point) {
+		fields++
+	}
+	n += msgpack.MapHeaderSize(fields)
+	n += msgpack.StringSize("name")
+	n += msgpack.StringSize(x.Name)
+	if x.Closed {
+		n += msgpack.StringSize("closed")
+		n += 1
+	}
+	n += msgpack.StringSize("points")
+	if x.Points == nil {
+		n++
+	} else {
+		n += msgpack.ArrayHeaderSize(len(x.Points))
+		for _, e0 := range x.Points {
+			n += e0.EncodedSize()
+		}
+	}
+	if x.Center != nil {
+		n += msgpack.StringSize("center")
+		if x.Center == nil {
+			n++
+		} else {
+			n += (*x.Center).EncodedSize()
+		}
+	}
+	n += msgpack.StringSize("normal")
+	n += x.Normal.EncodedSize()
+	if x.Origin.X != 0 || x.Origin.Y != 0 || x.Origin.Z != 0 {
+		n += msgpack.StringSize("origin")
+		n += x.Origin.EncodedSize()
+	}
+	if x.Weights != nil {
+		n += msgpack.StringSize("weights")
+		if x.Weights == nil {
+			n++
+		} else {
+			n += msgpack.ArrayHeaderSize(len(x.Weights))
+			n += len(x.Weights) * 9
+		}
+	}
+	n += msgpack.StringSize("layer")
+	n += msgpack.UintSize(uint64(x.Layer))
+	n += msgpack.StringSize("depth")
+	n += msgpack.IntSize(int64(x.Depth))
+	if x.Labels != nil {
+		n += msgpack.StringSize("labels")
+		if x.Labels == nil {
+			n++
+		} else {
+			n += msgpack.MapHeaderSize(len(x.Labels))
+			for k0, e0 := range x.Labels {
+				n += msgpack.StringSize(e0)
+				n += msgpack.StringSize(k0)
+			}
+		}
+	}
+	if x.Children != nil {
+		n += msgpack.StringSize("children")
+		if x.Children == nil {
+			n++
+		} else {
+			n += msgpack.ArrayHeaderSize(len(x.Children))
+			for _, e0 := range x.Children {
+				if e0 == nil {
+					n++
+				} else {
+					n += (*e0).EncodedSize()
+				}
+			}
+		}
+	}
+	if x.Data != nil {
+		n += msgpack.StringSize("data")
+		if x.Data == nil {
+			n++
+		} else {
+			n += msgpack.BytesSize(x.Data)
+		}
+	}
+	if x.Extra != nil {
+		n += msgpack.StringSize("extra")
+		n += msgpack.AnySize(x.Extra)
+	}
+	if x.Meta != nil {
+		n += msgpack.StringSize("meta")
+		n += msgpack.AnySize(x.Meta)
+	}
+	if x.Bounds != *new([2]Point) {
+		n += msgpack.StringSize("bounds")
+		n += msgpack.AnySize(x.Bounds)
+	}
+	return n
+}
+
+// UnmarshalMsgpack decodes the message pack encoding of a single value into x.
+func (x *Shape) UnmarshalMsgpack(b []byte) error {
+	rest, err := x.ReadMsgpack(b)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return msgpack.ErrInvalidMsgPack
+	}
+	return nil
+}
+
+// ReadMsgpack decodes the first value of b into x and returns the rest of b.
+func (x *Shape) ReadMsgpack(b []byte) ([]byte, error) {
+	rest, isNil := msgpack.ReadNil(b)
+	if isNil {
+		return rest, nil
+	}
+	var err error
+	var n int
+	if msgpack.PeekKind(b) == msgpack.ArrayKind {
+		if n, rest, err = msgpack.ReadArrayHeader(b); err != nil {
+			return b, err
+		}
+		if n != 15 {
+			return b, fmt.Errorf("%w: array of %d elements into Shape with 15 fields", msgpack.ErrArrayLength, n)
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+		} else {
+			if x.Name, rest, err = msgpack.ReadString(rest); err != nil {
+				return b, err
+			}
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+		} else {
+			if x.Closed, rest, err = msgpack.ReadBool(rest); err != nil {
+				return b, err
+			}
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+			x.Points = nil
+		} else {
+			var n0 int
+			if n0, rest, err = msgpack.ReadArrayHeader(rest); err != nil {
+				return b, err
+			}
+			x.Points = make([]Point, n0)
+			for i0 := range x.Points {
+				if rest, err = x.Points[i0].ReadMsgpack(rest); err != nil {
+					return b, err
+				}
+			}
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+			x.Center = nil
+		} else {
+			x.Center = new(Point)
+			if rest, err = (*x.Center).ReadMsgpack(rest); err != nil {
+				return b, err
+			}
+		}
+		if rest, err = x.Normal.ReadMsgpack(rest); err != nil {
+			return b, err
+		}
+		if rest, err = x.Origin.ReadMsgpack(rest); err != nil {
+			return b, err
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+			x.Weights = nil
+		} else {
+			var n0 int
+			if n0, rest, err = msgpack.ReadArrayHeader(rest); err != nil {
+				return b, err
+			}
+			x.Weights = make([]float64, n0)
+			for i0 := range x.Weights {
+				if r, ok := msgpack.ReadNil(rest); ok {
+					rest = r
+				} else {
+					var v1 float64
+					if v1, rest, err = msgpack.ReadFloat(rest, 64); err != nil {
+						return b, err
+					}
+					x.Weights[i0] = float64(v1)
+				}
+			}
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+		} else {
+			var v0 uint64
+			if v0, rest, err = msgpack.ReadUint(rest, 8); err != nil {
+				return b, err
+			}
+			x.Layer = uint8(v0)
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+		} else {
+			var v0 int64
+			if v0, rest, err = msgpack.ReadInt(rest, 16); err != nil {
+				return b, err
+			}
+			x.Depth = int16(v0)
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+			x.Labels = nil
+		} else {
+			var n0 int
+			if n0, rest, err = msgpack.ReadMapHeader(rest); err != nil {
+				return b, err
+			}
+			x.Labels = make(map[string]string, n0)
+			for range n0 {
+				var k0 string
+				if k0, rest, err = msgpack.ReadString(rest); err != nil {
+					return b, err
+				}
+				var v0 string
+				if r, ok := msgpack.ReadNil(rest); ok {
+					rest = r
+				} else {
+					if v0, rest, err = msgpack.ReadString(rest); err != nil {
+						return b, err
+					}
+				}
+				x.Labels[k0] = v0
+			}
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+			x.Children = nil
+		} else {
+			var n0 int
+			if n0, rest, err = msgpack.ReadArrayHeader(rest); err != nil {
+				return b, err
+			}
+			x.Children = make([]*Shape, n0)
+			for i0 := range x.Children {
+				if r, ok := msgpack.ReadNil(rest); ok {
+					rest = r
+					x.Children[i0] = nil
+				} else {
+					x.Children[i0] = new(Shape)
+					if rest, err = (*x.Children[i0]).ReadMsgpack(rest); err != nil {
+						return b, err
+					}
+				}
+			}
+		}
+		if r, ok := msgpack.ReadNil(rest); ok {
+			rest = r
+			x.Data = nil
+		} else {
+			if x.Data, rest, err = msgpack.ReadBytes(rest); err != nil {
+				return b, err
+			}
+		}
+		if rest, err = msgpack.ReadAny(rest, &x.Extra); err != nil {
+			return b, err
+		}
+		if rest, err = msgpack.ReadAny(rest, &x.Meta); err != nil {
+			return b, err
+		}
+		if rest, err = msgpack.ReadAny(rest, &x.Bounds); err != nil {
+			return b, err
+		}
+		return rest, nil
+	}
+	if n, rest, err = msgpack.ReadMapHeader(b); err != nil {
+		return b, err
+	}
+	for range n {
+		var key string
+		if key, rest, err = msgpack.ReadString(rest); err != nil {
+			return b, err
+		}
+		switch key {
+		case "name":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+			} else {
+				if x.Name, rest, err = msgpack.ReadString(rest); err != nil {
+					return b, err
+				}
+			}
+		case "closed":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+			} else {
+				if x.Closed, rest, err = msgpack.ReadBool(rest); err != nil {
+					return b, err
+				}
+			}
+		case "points":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+				x.Points = nil
+			} else {
+				var n0 int
+				if n0, rest, err = msgpack.ReadArrayHeader(rest); err != nil {
+					return b, err
+				}
+				x.Points = make([]Point, n0)
+				for i0 := range x.Points {
+					if rest, err = x.Points[i0].ReadMsgpack(rest); err != nil {
+						return b, err
+					}
+				}
+			}
+		case "center":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+				x.Center = nil
+			} else {
+				x.Center = new(Point)
+				if rest, err = (*x.Center).ReadMsgpack(rest); err != nil {
+					return b, err
+				}
+			}
+		case "normal":
+			if rest, err = x.Normal.ReadMsgpack(rest); err != nil {
+				return b, err
+			}
+		case "origin":
+			if rest, err = x.Origin.ReadMsgpack(rest); err != nil {
+				return b, err
+			}
+		case "weights":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+				x.Weights = nil
+			} else {
+				var n0 int
+				if n0, rest, err = msgpack.ReadArrayHeader(rest); err != nil {
+					return b, err
+				}
+				x.Weights = make([]float64, n0)
+				for i0 := range x.Weights {
+					if r, ok := msgpack.ReadNil(rest); ok {
+						rest = r
+					} else {
+						var v1 float64
+						if v1, rest, err = msgpack.ReadFloat(rest, 64); err != nil {
+							return b, err
+						}
+						x.Weights[i0] = float64(v1)
+					}
+				}
+			}
+		case "layer":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+			} else {
+				var v0 uint64
+				if v0, rest, err = msgpack.ReadUint(rest, 8); err != nil {
+					return b, err
+				}
+				x.Layer = uint8(v0)
+			}
+		case "depth":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+			} else {
+				var v0 int64
+				if v0, rest, err = msgpack.ReadInt(rest, 16); err != nil {
+					return b, err
+				}
+				x.Depth = int16(v0)
+			}
+		case "labels":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+				x.Labels = nil
+			} else {
+				var n0 int
+				if n0, rest, err = msgpack.ReadMapHeader(rest); err != nil {
+					return b, err
+				}
+				x.Labels = make(map[string]string, n0)
+				for range n0 {
+					var k0 string
+					if k0, rest, err = msgpack.ReadString(rest); err != nil {
+						return b, err
+					}
+					var v0 string
+					if r, ok := msgpack.ReadNil(rest); ok {
+						rest = r
+					} else {
+						if v0, rest, err = msgpack.ReadString(rest); err != nil {
+							return b, err
+						}
+					}
+					x.Labels[k0] = v0
+				}
+			}
+		case "children":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+				x.Children = nil
+			} else {
+				var n0 int
+				if n0, rest, err = msgpack.ReadArrayHeader(rest); err != nil {
+					return b, err
+				}
+				x.Children = make([]*Shape, n0)
+				for i0 := range x.Children {
+					if r, ok := msgpack.ReadNil(rest); ok {
+						rest = r
+						x.Children[i0] = nil
+					} else {
+						x.Children[i0] = new(Shape)
+						if rest, err = (*x.Children[i0]).ReadMsgpack(rest); err != nil {
+							return b, err
+						}
+					}
+				}
+			}
+		case "data":
+			if r, ok := msgpack.ReadNil(rest); ok {
+				rest = r
+				x.Data = nil
+			} else {
+				if x.Data, rest, err = msgpack.ReadBytes(rest); err != nil {
+					return b, err
+				}
+			}
+		case "extra":
+			if rest, err = msgpack.ReadAny(rest, &x.Extra); err != nil {
+				return b, err
+			}
+		case "meta":
+			if rest, err = msgpack.ReadAny(rest, &x.Meta); err != nil {
+				return b, err
+			}
+		case "bounds":
+			if rest, err = msgpack.ReadAny(rest, &x.Bounds); err != nil {
+				return b, err
+			}
+		default:
+			if rest, err = msgpack.Skip(rest); err != nil {
+				return b, err
+			}
+		}
+	}
+	return rest, nil
+}
@@ -0,0 +1,147 @@
+// Code generated by msgpackgen. DO NOT EDIT.
+
+package example
+
+import (
+	"bytes"
+	"msgpackconv/msgpack"
+	"reflect"
+	"testing"
+)
+
+func TestPointMsgpack(t *testing.T) {
+	// plain 沒有產生的方法，msgpack.Marshal 會以 reflection 編碼
+	type plain Point
+	for _, v := range []Point{{}, sampleMsgpackPoint()} {
+		got, err := v.MarshalMsgpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := msgpack.Marshal(plain(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("MarshalMsgpack() = % x, want % x", got, want)
+		}
+		if n := v.EncodedSize(); n != len(got) {
+			t.Errorf("EncodedSize() = %d, want %d", n, len(got))
+		}
+		var decoded Point
+		if err := decoded.UnmarshalMsgpack(got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, v) {
+			t.Errorf("UnmarshalMsgpack() = %+v, want %+v", decoded, v)
+		}
+		var reflected plain
+		if err := msgpack.Unmarshal(got, &reflected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(Point(reflected), v) {
+			t.Errorf("msgpack.Unmarshal() = %+v, want %+v", reflected, v)
+		}
+	}
+}
+
+func sampleMsgpackPoint() Point {
+	return Point{
+		X: -2,
+		Y: -2,
+	}
+}
+
+func TestVecMsgpack(t *testing.T) {
+	// plain 沒有產生的方法，msgpack.Marshal 會以 reflection 編碼
+	type plain Vec
+	for _, v := range []Vec{{}, sampleMsgpackVec()} {
+		got, err := v.MarshalMsgpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := msgpack.Marshal(plain(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("MarshalMsgpack() = % x, want % x", got, want)
+		}
+		if n := v.EncodedSize(); n != len(got) {
+			t.Errorf("EncodedSize() = %d, want %d", n, len(got))
+		}
+		var decoded Vec
+		if err := decoded.UnmarshalMsgpack(got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, v) {
+			t.Errorf("UnmarshalMsgpack() = %+v, want %+v", decoded, v)
+		}
+		var reflected plain
+		if err := msgpack.Unmarshal(got, &reflected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(Vec(reflected), v) {
+			t.Errorf("msgpack.Unmarshal() = %+v, want %+v", reflected, v)
+		}
+	}
+}
+
+func sampleMsgpackVec() Vec {
+	return Vec{
+		X: 1.5,
+		Y: 1.5,
+		Z: 1.5,
+	}
+}
+
+func TestShapeMsgpack(t *testing.T) {
+	// plain 沒有產生的方法，msgpack.Marshal 會以 reflection 編碼
+	type plain Shape
+	for _, v := range []Shape{{}, sampleMsgpackShape()} {
+		got, err := v.MarshalMsgpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := msgpack.Marshal(plain(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("MarshalMsgpack() = % x, want % x", got, want)
+		}
+		if n := v.EncodedSize(); n != len(got) {
+			t.Errorf("EncodedSize() = %d, want %d", n, len(got))
+		}
+		var decoded Shape
+		if err := decoded.UnmarshalMsgpack(got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, v) {
+			t.Errorf("UnmarshalMsgpack() = %+v, want %+v", decoded, v)
+		}
+		var reflected plain
+		if err := msgpack.Unmarshal(got, &reflected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(Shape(reflected), v) {
+			t.Errorf("msgpack.Unmarshal() = %+v, want %+v", reflected, v)
+		}
+	}
+}
+
+func sampleMsgpackShape() Shape {
+	return Shape{
+		Name:     "a",
+		Closed:   true,
+		Points:   []Point{Point{}},
+		Center:   new(Point),
+		Normal:   sampleMsgpackVec(),
+		Origin:   sampleMsgpackVec(),
+		Weights:  []float64{1.5},
+		Layer:    2,
+		Depth:    -2,
+		Labels:   map[string]string{"k": "a"},
+		Children: []*Shape{new(Shape)},
+		Data:     []byte{1},
+	}
+}
@@ -0,0 +1,89 @@
+package example
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"msgpackconv/msgpack"
+)
+
+func benchmarkShape() Shape {
+	return Shape{
+		Name:    "triangle",
+		Closed:  true,
+		Points:  []Point{{0, 0}, {3, 0}, {0, 4}},
+		Center:  &Point{1, 1},
+		Normal:  Vec{Z: 1},
+		Weights: []float64{0.25, 0.5, 0.25},
+		Labels:  map[string]string{"color": "red", "layer": "top"},
+	}
+}
+
+func TestShapeFallbackFields(t *testing.T) {
+	// Extra、Meta 與 Bounds 經由 msgpack.AppendAny 與 msgpack.ReadAny 編碼
+	v := benchmarkShape()
+	v.Origin = Vec{X: 1}
+	v.Extra = map[int]string{1: "one", -2: "minus two"}
+	v.Meta = []interface{}{"tag", uint64(7), true}
+	v.Bounds = [2]Point{{0, 0}, {3, 4}}
+	got, err := v.MarshalMsgpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := msgpack.Marshal(reflected(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("MarshalMsgpack() = % x, want % x", got, want)
+	}
+	var decoded Shape
+	if err := decoded.UnmarshalMsgpack(got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, v) {
+		t.Errorf("UnmarshalMsgpack() = %+v, want %+v", decoded, v)
+	}
+}
+
+// reflected 沒有產生的方法，msgpack.Marshal 會以 reflection 編碼
+type reflected Shape
+
+func BenchmarkMarshalGenerated(b *testing.B) {
+	v := benchmarkShape()
+	for b.Loop() {
+		if _, err := v.MarshalMsgpack(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalReflect(b *testing.B) {
+	v := reflected(benchmarkShape())
+	for b.Loop() {
+		if _, err := msgpack.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalGenerated(b *testing.B) {
+	data, _ := benchmarkShape().MarshalMsgpack()
+	for b.Loop() {
+		var v Shape
+		if err := v.UnmarshalMsgpack(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalReflect(b *testing.B) {
+	data, _ := benchmarkShape().MarshalMsgpack()
+	for b.Loop() {
+		var v reflected
+		if err := msgpack.Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// generate 回傳 filename 中標記的 struct 的方法與測試程式碼
+func generate(filename string) ([]byte, []byte, error) {
+	pkg, structs, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(structs) == 0 {
+		return nil, nil, fmt.Errorf("%s: no struct marked with %s", filename, directive)
+	}
+
+	g := &generator{imports: map[string]bool{"msgpackconv/msgpack": true, "fmt": true}, structs: map[string]*structDef{}}
+	for _, s := range structs {
+		g.structs[s.name] = s
+	}
+	for _, s := range structs {
+		g.writeStruct(s)
+	}
+	code, err := g.source(pkg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := &generator{imports: map[string]bool{"bytes": true, "msgpackconv/msgpack": true, "reflect": true, "testing": true}}
+	for _, s := range structs {
+		t.writeTest(s)
+	}
+	test, err := t.source(pkg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return code, test, nil
+}
+
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+	// 標記的 struct，以名稱查詢
+	structs map[string]*structDef
+}
+
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+func (g *generator) source(pkg string) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by msgpackgen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	for _, path := range slices.Sorted(maps.Keys(g.imports)) {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString(")\n\n")
+	out.Write(g.buf.Bytes())
+	return format.Source(out.Bytes())
+}
+
+func (g *generator) writeStruct(s *structDef) {
+	g.p("// MarshalMsgpack returns the message pack encoding of x.")
+	g.p("func (x %s) MarshalMsgpack() ([]byte, error) {", s.name)
+	g.p("return x.AppendMsgpack(make([]byte, 0, x.EncodedSize()))")
+	g.p("}\n")
+
+	g.p("// AppendMsgpack appends the message pack encoding of x to b.")
+	g.p("func (x %s) AppendMsgpack(b []byte) ([]byte, error) {", s.name)
+	g.p("var err error")
+	g.writeHeader(s, "b = msgpack.AppendArrayHeader(b, %s)", "b = msgpack.AppendMapHeader(b, %s)")
+	for _, f := range s.fields {
+		v := "x." + f.goName
+		if !s.asArray && f.omitEmpty {
+			g.p("if %s {", g.nonZero(v, f.typ))
+		}
+		if !s.asArray {
+			g.p("b = msgpack.AppendString(b, %q)", f.key)
+		}
+		g.encode(v, f.typ, 0)
+		if !s.asArray && f.omitEmpty {
+			g.p("}")
+		}
+	}
+	g.p("return b, err")
+	g.p("}\n")
+
+	g.p("// EncodedSize returns the length of the message pack encoding of x.")
+	g.p("func (x %s) EncodedSize() int {", s.name)
+	g.p("n := 0")
+	g.writeHeader(s, "n += msgpack.ArrayHeaderSize(%s)", "n += msgpack.MapHeaderSize(%s)")
+	for _, f := range s.fields {
+		v := "x." + f.goName
+		if !s.asArray && f.omitEmpty {
+			g.p("if %s {", g.nonZero(v, f.typ))
+		}
+		if !s.asArray {
+			g.p("n += msgpack.StringSize(%q)", f.key)
+		}
+		g.size(v, f.typ, 0)
+		if !s.asArray && f.omitEmpty {
+			g.p("}")
+		}
+	}
+	g.p("return n")
+	g.p("}\n")
+
+	g.p("// UnmarshalMsgpack decodes the message pack encoding of a single value into x.")
+	g.p("func (x *%s) UnmarshalMsgpack(b []byte) error {", s.name)
+	g.p("rest, err := x.ReadMsgpack(b)")
+	g.p("if err != nil {")
+	g.p("return err")
+	g.p("}")
+	g.p("if len(rest) != 0 {")
+	g.p("return msgpack.ErrInvalidMsgPack")
+	g.p("}")
+	g.p("return nil")
+	g.p("}\n")
+
+	g.p("// ReadMsgpack decodes the first value of b into x and returns the rest of b.")
+	g.p("func (x *%s) ReadMsgpack(b []byte) ([]byte, error) {", s.name)
+	g.p("rest, isNil := msgpack.ReadNil(b)")
+	g.p("if isNil {")
+	g.p("return rest, nil")
+	g.p("}")
+	g.p("var err error")
+	g.p("var n int")
+	g.p("if msgpack.PeekKind(b) == msgpack.ArrayKind {")
+	g.p("if n, rest, err = msgpack.ReadArrayHeader(b); err != nil {")
+	g.p("return b, err")
+	g.p("}")
+	g.p("if n != %d {", len(s.fields))
+	g.p("return b, fmt.Errorf(\"%%w: array of %%d elements into %s with %d fields\", msgpack.ErrArrayLength, n)", s.name, len(s.fields))
+	g.p("}")
+	for _, f := range s.fields {
+		g.decode("x."+f.goName, f.typ, 0)
+	}
+	g.p("return rest, nil")
+	g.p("}")
+	g.p("if n, rest, err = msgpack.ReadMapHeader(b); err != nil {")
+	g.p("return b, err")
+	g.p("}")
+	g.p("for range n {")
+	g.p("var key string")
+	g.p("if key, rest, err = msgpack.ReadString(rest); err != nil {")
+	g.p("return b, err")
+	g.p("}")
+	g.p("switch key {")
+	for _, f := range s.fields {
+		g.p("case %q:", f.key)
+		g.decode("x."+f.goName, f.typ, 0)
+	}
+	g.p("default:")
+	g.p("if rest, err = msgpack.Skip(rest); err != nil {")
+	g.p("return b, err")
+	g.p("}")
+	g.p("}")
+	g.p("}")
+	g.p("return rest, nil")
+	g.p("}\n")
+}
+
+// writeHeader 寫出 array 或 map header，map 的長度需扣除 omitempty 的欄位
+func (g *generator) writeHeader(s *structDef, arrayFormat, mapFormat string) {
+	if s.asArray {
+		g.p(arrayFormat, fmt.Sprint(len(s.fields)))
+		return
+	}
+	fixed := 0
+	optional := []fieldDef{}
+	for _, f := range s.fields {
+		if f.omitEmpty {
+			optional = append(optional, f)
+		} else {
+			fixed++
+		}
+	}
+	if len(optional) == 0 {
+		g.p(mapFormat, fmt.Sprint(fixed))
+		return
+	}
+	g.p("fields := %d", fixed)
+	for _, f := range optional {
+		g.p("if %s {", g.nonZero("x."+f.goName, f.typ))
+		g.p("fields++")
+		g.p("}")
+	}
+	g.p(mapFormat, "fields")
+}
+
+// nonZero 回傳判斷欄位不是 zero value 的條件，與 reflect.Value.IsZero 相同
+func (g *generator) nonZero(v string, td *typeDef) string {
+	switch td.kind {
+	case kindBool:
+		return v
+	case kindInt, kindUint, kindFloat:
+		return v + " != 0"
+	case kindString:
+		return v + ` != ""`
+	case kindBytes, kindSlice, kindPointer, kindMap:
+		return v + " != nil"
+	case kindStruct:
+		// struct 的所有欄位，包括未寫入的欄位，都是 zero value 時才是 zero value
+		s := g.structs[td.expr]
+		conds := []string{}
+		for _, f := range slices.Concat(s.fields, s.hidden) {
+			conds = append(conds, g.nonZero(v+"."+f.goName, f.typ))
+		}
+		if len(conds) == 0 {
+			return "false"
+		}
+		return "(" + strings.Join(conds, " || ") + ")"
+	}
+	if td.nilable {
+		return v + " != nil"
+	}
+	// 其他類型與同類型的 zero value 比較，必須是 comparable 的類型
+	return fmt.Sprintf("%s != *new(%s)", v, td.expr)
+}
+
+func (g *generator) encode(v string, td *typeDef, depth int) {
+	switch td.kind {
+	case kindBool:
+		g.p("b = msgpack.AppendBool(b, %s)", v)
+	case kindInt:
+		g.p("b = msgpack.AppendInt(b, int64(%s))", v)
+	case kindUint:
+		g.p("b = msgpack.AppendUint(b, uint64(%s))", v)
+	case kindFloat:
+		g.p("b = msgpack.AppendFloat%d(b, %s)", td.bits, v)
+	case kindString:
+		g.p("b = msgpack.AppendString(b, %s)", v)
+	case kindStruct:
+		g.p("if b, err = %s.AppendMsgpack(b); err != nil {", v)
+		g.p("return b, err")
+		g.p("}")
+	case kindAny:
+		g.p("if b, err = msgpack.AppendAny(b, %s); err != nil {", v)
+		g.p("return b, err")
+		g.p("}")
+	default:
+		g.p("if %s == nil {", v)
+		g.p("b = msgpack.AppendNil(b)")
+		g.p("} else {")
+		switch td.kind {
+		case kindBytes:
+			g.p("b = msgpack.AppendBytes(b, %s)", v)
+		case kindPointer:
+			g.encode("(*"+v+")", td.elem, depth)
+		case kindSlice:
+			e := fmt.Sprintf("e%d", depth)
+			g.p("b = msgpack.AppendArrayHeader(b, len(%s))", v)
+			g.p("for _, %s := range %s {", e, v)
+			g.encode(e, td.elem, depth+1)
+			g.p("}")
+		case kindMap:
+			g.imports["maps"] = true
+			g.imports["slices"] = true
+			k := fmt.Sprintf("k%d", depth)
+			g.p("b = msgpack.AppendMapHeader(b, len(%s))", v)
+			g.p("for _, %s := range slices.Sorted(maps.Keys(%s)) {", k, v)
+			g.p("b = msgpack.AppendString(b, %s)", k)
+			g.encode(v+"["+k+"]", td.elem, depth+1)
+			g.p("}")
+		}
+		g.p("}")
+	}
+}
+
+// fixedSize 回傳固定長度類型的編碼長度
+func fixedSize(td *typeDef) int {
+	switch td.kind {
+	case kindBool:
+		return 1
+	case kindFloat:
+		return 1 + td.bits/8
+	}
+	return 0
+}
+
+func (g *generator) size(v string, td *typeDef, depth int) {
+	if n := fixedSize(td); n > 0 {
+		g.p("n += %d", n)
+		return
+	}
+	switch td.kind {
+	case kindInt:
+		g.p("n += msgpack.IntSize(int64(%s))", v)
+	case kindUint:
+		g.p("n += msgpack.UintSize(uint64(%s))", v)
+	case kindString:
+		g.p("n += msgpack.StringSize(%s)", v)
+	case kindStruct:
+		g.p("n += %s.EncodedSize()", v)
+	case kindAny:
+		g.p("n += msgpack.AnySize(%s)", v)
+	default:
+		g.p("if %s == nil {", v)
+		g.p("n++")
+		g.p("} else {")
+		switch td.kind {
+		case kindBytes:
+			g.p("n += msgpack.BytesSize(%s)", v)
+		case kindPointer:
+			g.size("(*"+v+")", td.elem, depth)
+		case kindSlice:
+			g.p("n += msgpack.ArrayHeaderSize(len(%s))", v)
+			if n := fixedSize(td.elem); n > 0 {
+				g.p("n += len(%s) * %d", v, n)
+			} else {
+				e := fmt.Sprintf("e%d", depth)
+				g.p("for _, %s := range %s {", e, v)
+				g.size(e, td.elem, depth+1)
+				g.p("}")
+			}
+		case kindMap:
+			k := fmt.Sprintf("k%d", depth)
+			g.p("n += msgpack.MapHeaderSize(len(%s))", v)
+			if n := fixedSize(td.elem); n > 0 {
+				g.p("n += len(%s) * %d", v, n)
+				g.p("for %s := range %s {", k, v)
+			} else {
+				e := fmt.Sprintf("e%d", depth)
+				g.p("for %s, %s := range %s {", k, e, v)
+				g.size(e, td.elem, depth+1)
+			}
+			g.p("n += msgpack.StringSize(%s)", k)
+			g.p("}")
+		}
+		g.p("}")
+	}
+}
+
+func (g *generator) decode(v string, td *typeDef, depth int) {
+	switch td.kind {
+	case kindStruct:
+		g.p("if rest, err = %s.ReadMsgpack(rest); err != nil {", v)
+		g.p("return b, err")
+		g.p("}")
+		return
+	case kindAny:
+		g.p("if rest, err = msgpack.ReadAny(rest, &%s); err != nil {", v)
+		g.p("return b, err")
+		g.p("}")
+		return
+	}
+
+	// nil 會將 pointer, slice 與 map 設為 nil，其他類型維持原值
+	g.p("if r, ok := msgpack.ReadNil(rest); ok {")
+	g.p("rest = r")
+	switch td.kind {
+	case kindBytes, kindSlice, kindPointer, kindMap:
+		g.p("%s = nil", v)
+	}
+	g.p("} else {")
+	tmp := fmt.Sprintf("v%d", depth)
+	switch td.kind {
+	case kindBool:
+		g.p("if %s, rest, err = msgpack.ReadBool(rest); err != nil {", v)
+		g.p("return b, err")
+		g.p("}")
+	case kindString:
+		g.p("if %s, rest, err = msgpack.ReadString(rest); err != nil {", v)
+		g.p("return b, err")
+		g.p("}")
+	case kindBytes:
+		g.p("if %s, rest, err = msgpack.ReadBytes(rest); err != nil {", v)
+		g.p("return b, err")
+		g.p("}")
+	case kindInt, kindUint, kindFloat:
+		read := map[typeKind]string{kindInt: "ReadInt", kindUint: "ReadUint", kindFloat: "ReadFloat"}[td.kind]
+		goType := map[typeKind]string{kindInt: "int64", kindUint: "uint64", kindFloat: "float64"}[td.kind]
+		g.p("var %s %s", tmp, goType)
+		g.p("if %s, rest, err = msgpack.%s(rest, %d); err != nil {", tmp, read, td.bits)
+		g.p("return b, err")
+		g.p("}")
+		g.p("%s = %s(%s)", v, td.expr, tmp)
+	case kindPointer:
+		g.p("%s = new(%s)", v, td.elem.expr)
+		g.decode("(*"+v+")", td.elem, depth+1)
+	case kindSlice:
+		n, i := fmt.Sprintf("n%d", depth), fmt.Sprintf("i%d", depth)
+		g.p("var %s int", n)
+		g.p("if %s, rest, err = msgpack.ReadArrayHeader(rest); err != nil {", n)
+		g.p("return b, err")
+		g.p("}")
+		g.p("%s = make(%s, %s)", v, td.expr, n)
+		g.p("for %s := range %s {", i, v)
+		g.decode(v+"["+i+"]", td.elem, depth+1)
+		g.p("}")
+	case kindMap:
+		n, k := fmt.Sprintf("n%d", depth), fmt.Sprintf("k%d", depth)
+		g.p("var %s int", n)
+		g.p("if %s, rest, err = msgpack.ReadMapHeader(rest); err != nil {", n)
+		g.p("return b, err")
+		g.p("}")
+		g.p("%s = make(%s, %s)", v, td.expr, n)
+		g.p("for range %s {", n)
+		g.p("var %s string", k)
+		g.p("if %s, rest, err = msgpack.ReadString(rest); err != nil {", k)
+		g.p("return b, err")
+		g.p("}")
+		g.p("var %s %s", tmp, td.elem.expr)
+		g.decode(tmp, td.elem, depth+1)
+		g.p("%s[%s] = %s", v, k, tmp)
+		g.p("}")
+	}
+	g.p("}")
+}
+
+func (g *generator) writeTest(s *structDef) {
+	g.p("func Test%sMsgpack(t *testing.T) {", s.name)
+	g.p("// plain 沒有產生的方法，msgpack.Marshal 會以 reflection 編碼")
+	g.p("type plain %s", s.name)
+	g.p("for _, v := range []%s{{}, sampleMsgpack%s()} {", s.name, s.name)
+	g.p("got, err := v.MarshalMsgpack()")
+	g.p("if err != nil {")
+	g.p("t.Fatal(err)")
+	g.p("}")
+	g.p("want, err := msgpack.Marshal(plain(v))")
+	g.p("if err != nil {")
+	g.p("t.Fatal(err)")
+	g.p("}")
+	g.p("if !bytes.Equal(got, want) {")
+	g.p("t.Errorf(\"MarshalMsgpack() = %% x, want %% x\", got, want)")
+	g.p("}")
+	g.p("if n := v.EncodedSize(); n != len(got) {")
+	g.p("t.Errorf(\"EncodedSize() = %%d, want %%d\", n, len(got))")
+	g.p("}")
+	g.p("var decoded %s", s.name)
+	g.p("if err := decoded.UnmarshalMsgpack(got); err != nil {")
+	g.p("t.Fatal(err)")
+	g.p("}")
+	g.p("if !reflect.DeepEqual(decoded, v) {")
+	g.p("t.Errorf(\"UnmarshalMsgpack() = %%+v, want %%+v\", decoded, v)")
+	g.p("}")
+	g.p("var reflected plain")
+	g.p("if err := msgpack.Unmarshal(got, &reflected); err != nil {")
+	g.p("t.Fatal(err)")
+	g.p("}")
+	g.p("if !reflect.DeepEqual(%s(reflected), v) {", s.name)
+	g.p("t.Errorf(\"msgpack.Unmarshal() = %%+v, want %%+v\", reflected, v)")
+	g.p("}")
+	g.p("}")
+	g.p("}\n")
+
+	g.p("func sampleMsgpack%s() %s {", s.name, s.name)
+	g.p("return %s{", s.name)
+	for _, f := range s.fields {
+		if sample := sampleValue(f.typ, true); sample != "" {
+			g.p("%s: %s,", f.goName, sample)
+		}
+	}
+	g.p("}")
+	g.p("}\n")
+}
+
+// sampleValue 回傳測試用的非 zero value，其他類型留空
+func sampleValue(td *typeDef, top bool) string {
+	switch td.kind {
+	case kindBool:
+		return "true"
+	case kindInt:
+		return "-2"
+	case kindUint:
+		return "2"
+	case kindFloat:
+		return "1.5"
+	case kindString:
+		return `"a"`
+	case kindBytes:
+		return td.expr + "{1}"
+	case kindPointer:
+		return "new(" + td.elem.expr + ")"
+	case kindStruct:
+		// 只在最外層展開，避免遞迴的類型
+		if top {
+			return "sampleMsgpack" + td.expr + "()"
+		}
+		return td.expr + "{}"
+	case kindSlice:
+		if elem := sampleValue(td.elem, false); elem != "" {
+			return td.expr + "{" + elem + "}"
+		}
+	case kindMap:
+		if elem := sampleValue(td.elem, false); elem != "" {
+			return td.expr + `{"k": ` + elem + "}"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+// Command msgpackgen writes reflection-free message pack methods for Go
+// structs.
+//
+// Mark a struct with a //msgpack:generate line in its doc comment and add
+//
+//	//go:generate go run msgpackconv/cmd/msgpackgen
+//
+// to the file. For every marked struct of $GOFILE, msgpackgen writes
+// MarshalMsgpack, AppendMsgpack, UnmarshalMsgpack, ReadMsgpack and
+// EncodedSize methods to <file>_msgpack.go, and tests that check them
+// against msgpack.Marshal and msgpack.Unmarshal to <file>_msgpack_test.go.
+//
+// The methods write the same bytes as msgpack.Marshal and honor the same
+// struct tags. Fields of builtin types, slices, pointers, maps with string
+// keys and other marked structs of the file are written directly; any other
+// field type falls back to msgpack.AppendAny and msgpack.ReadAny. Map keys
+// are matched exactly when decoding. With omitempty, such a field is
+// compared with nil when it is a map, interface, func or chan and with the
+// zero value of its type otherwise, so that type must be comparable.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("msgpackgen: ")
+	file := flag.String("file", os.Getenv("GOFILE"), "Go source file to read")
+	flag.Parse()
+	if *file == "" {
+		log.Fatal("no input file: run from go generate or pass -file")
+	}
+
+	code, test, err := generate(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	base := strings.TrimSuffix(*file, ".go")
+	if err := os.WriteFile(base+"_msgpack.go", code, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(base+"_msgpack_test.go", test, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("msgpackgen: wrote %s_msgpack.go and %s_msgpack_test.go\n", base, base)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestGenerateExample(t *testing.T) {
+	code, test, err := generate("example/example.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  []byte
+	}{
+		{"example/example_msgpack.go", code},
+		{"example/example_msgpack_test.go", test},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := os.ReadFile(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tt.got, want) {
+				t.Errorf("%s is out of date, run go generate ./cmd/msgpackgen/example", tt.name)
+			}
+		})
+	}
+}
+
+func TestGenerateNoStruct(t *testing.T) {
+	if _, _, err := generate("main.go"); err == nil {
+		t.Error("generate() error = nil, want error")
+	}
+}
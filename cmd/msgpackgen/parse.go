@@ -0,0 +1,210 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const directive = "//msgpack:generate"
+
+type structDef struct {
+	name string
+	// 以 array 編碼，欄位依宣告順序排列
+	asArray bool
+	fields  []fieldDef
+	// 不寫入但判斷 zero value 時需要的欄位，例如未匯出的欄位
+	hidden []fieldDef
+}
+
+type fieldDef struct {
+	goName    string
+	key       string
+	omitEmpty bool
+	typ       *typeDef
+}
+
+type typeKind int
+
+const (
+	kindBool typeKind = iota
+	kindInt
+	kindUint
+	kindFloat
+	kindString
+	kindBytes
+	kindSlice
+	kindPointer
+	kindMap
+	kindStruct
+	// 其他類型交給 msgpack.AppendAny 與 msgpack.ReadAny
+	kindAny
+)
+
+type typeDef struct {
+	kind typeKind
+	// Go 的類型寫法，例如 "[]Point"
+	expr string
+	bits int
+	// slice, pointer 的元素或 map 的 value
+	elem *typeDef
+	// kindAny 中 zero value 為 nil 的類型，例如 interface 與 map
+	nilable bool
+}
+
+var builtinTypes = map[string]typeDef{
+	"bool":    {kind: kindBool},
+	"string":  {kind: kindString},
+	"int":     {kind: kindInt, bits: 64},
+	"int8":    {kind: kindInt, bits: 8},
+	"int16":   {kind: kindInt, bits: 16},
+	"int32":   {kind: kindInt, bits: 32},
+	"rune":    {kind: kindInt, bits: 32},
+	"int64":   {kind: kindInt, bits: 64},
+	"uint":    {kind: kindUint, bits: 64},
+	"uint8":   {kind: kindUint, bits: 8},
+	"byte":    {kind: kindUint, bits: 8},
+	"uint16":  {kind: kindUint, bits: 16},
+	"uint32":  {kind: kindUint, bits: 32},
+	"uint64":  {kind: kindUint, bits: 64},
+	"float32": {kind: kindFloat, bits: 32},
+	"float64": {kind: kindFloat, bits: 64},
+}
+
+// parseFile 讀取 Go 檔案中標記為 //msgpack:generate 的 struct
+func parseFile(filename string) (string, []*structDef, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return "", nil, err
+	}
+
+	specs := []*ast.TypeSpec{}
+	marked := map[string]bool{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			doc := ts.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			if _, isStruct := ts.Type.(*ast.StructType); isStruct && hasDirective(doc) {
+				specs = append(specs, ts)
+				marked[ts.Name.Name] = true
+			}
+		}
+	}
+
+	structs := []*structDef{}
+	for _, ts := range specs {
+		def := &structDef{name: ts.Name.Name}
+		for _, f := range ts.Type.(*ast.StructType).Fields.List {
+			tag := ""
+			if f.Tag != nil {
+				tag = reflect.StructTag(strings.Trim(f.Tag.Value, "`")).Get("msgpack")
+			}
+			key, opts, _ := strings.Cut(tag, ",")
+			options := strings.Split(opts, ",")
+			names := []string{}
+			for _, name := range f.Names {
+				names = append(names, name.Name)
+			}
+			if len(names) == 0 {
+				// embedded field 以類型名稱作為欄位名稱
+				names = append(names, embeddedName(f.Type))
+			}
+			for _, name := range names {
+				if name == "_msgpack" {
+					def.asArray = slices.Contains(options, "asarray")
+					continue
+				}
+				if name == "_" {
+					continue
+				}
+				if !ast.IsExported(name) || tag == "-" {
+					def.hidden = append(def.hidden, fieldDef{goName: name, typ: resolveType(f.Type, marked)})
+					continue
+				}
+				fieldKey := key
+				if fieldKey == "" {
+					fieldKey = name
+				}
+				def.fields = append(def.fields, fieldDef{
+					goName:    name,
+					key:       fieldKey,
+					omitEmpty: slices.Contains(options, "omitempty"),
+					typ:       resolveType(f.Type, marked),
+				})
+			}
+		}
+		structs = append(structs, def)
+	}
+	return file.Name.Name, structs, nil
+}
+
+func hasDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == directive {
+			return true
+		}
+	}
+	return false
+}
+
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+func resolveType(expr ast.Expr, marked map[string]bool) *typeDef {
+	td := &typeDef{kind: kindAny, expr: types.ExprString(expr)}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if builtin, ok := builtinTypes[t.Name]; ok {
+			builtin.expr = td.expr
+			return &builtin
+		}
+		if marked[t.Name] {
+			td.kind = kindStruct
+		}
+		td.nilable = t.Name == "any" || t.Name == "error"
+	case *ast.ArrayType:
+		if t.Len != nil {
+			break
+		}
+		elem := resolveType(t.Elt, marked)
+		if elem.kind == kindUint && elem.bits == 8 {
+			td.kind = kindBytes
+		} else {
+			td.kind, td.elem = kindSlice, elem
+		}
+	case *ast.StarExpr:
+		td.kind, td.elem = kindPointer, resolveType(t.X, marked)
+	case *ast.MapType:
+		if key, ok := t.Key.(*ast.Ident); ok && key.Name == "string" {
+			td.kind, td.elem = kindMap, resolveType(t.Value, marked)
+		}
+		td.nilable = true
+	case *ast.InterfaceType, *ast.FuncType, *ast.ChanType:
+		td.nilable = true
+	}
+	return td
+}
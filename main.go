@@ -1,13 +1,150 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"os"
+
 	"msgpackconv/msgpack"
+	"msgpackconv/msgpack/ubjson"
 )
 
+const usage = `usage: msgpackconv <command> [flags] < input > output
+
+commands:
+  tojson    convert message pack or UBJSON values to JSON
+  fromjson  convert JSON values to message pack or UBJSON
+  tocsv     convert an array or sequence of maps to CSV
+  fromcsv   convert CSV with a header row to an array of maps
+
+Run "msgpackconv <command> -h" for the flags of a command.
+`
+
 func main() {
-	msg := msgpack.FromJSON([]byte(`{"str": "a"}`))
-	fmt.Printf("% x\n", msg)
-	json := msgpack.ToJSON(msg)
-	fmt.Println(string(json))
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "tojson":
+		err = toJSON(os.Args[2:])
+	case "fromjson":
+		err = fromJSON(os.Args[2:])
+	case "tocsv":
+		err = toCSV(os.Args[2:])
+	case "fromcsv":
+		err = fromCSV(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "msgpackconv:", err)
+		os.Exit(1)
+	}
+}
+
+func toJSON(args []string) error {
+	fs := flag.NewFlagSet("tojson", flag.ExitOnError)
+	opts := msgpack.ToJSONOptions{}
+	fs.StringVar(&opts.Indent, "indent", "", "indent nested values with `string`")
+	fs.StringVar(&opts.Prefix, "prefix", "", "begin each indented line with `string`")
+	escapeHTML := fs.Bool("escape-html", true, "escape <, > and & in strings")
+	fs.BoolVar(&opts.SortKeys, "sort-keys", false, "sort map keys instead of keeping wire order")
+	fs.BoolVar(&opts.ASCII, "ascii", false, "escape non-ASCII characters as \\uXXXX")
+	fs.BoolVar(&opts.TrailingNewline, "newline", true, "end the output with a newline")
+	fs.Func("duplicate-keys", "handle repeated map keys: last (default), error or first", func(s string) error {
+		policies := map[string]msgpack.DuplicateKeyPolicy{
+			"error": msgpack.DuplicateKeysError,
+			"last":  msgpack.DuplicateKeysLast,
+			"first": msgpack.DuplicateKeysFirst,
+		}
+		policy, ok := policies[s]
+		if !ok {
+			return fmt.Errorf("unknown policy %q", s)
+		}
+		opts.DuplicateKeys = policy
+		return nil
+	})
+	format := formatFlag(fs, "read values in `format`: msgpack (default) or ubjson")
+	fs.Parse(args)
+	opts.DisableHTMLEscape = !*escapeHTML
+	var src io.Reader = os.Stdin
+	if *format == "ubjson" {
+		src = pipe(func(w io.Writer) error {
+			return ubjson.ToMsgpackStream(w, os.Stdin)
+		})
+	}
+	return msgpack.ToJSONStreamWithOptions(os.Stdout, src, opts)
+}
+
+func fromJSON(args []string) error {
+	fs := flag.NewFlagSet("fromjson", flag.ExitOnError)
+	format := formatFlag(fs, "write values in `format`: msgpack (default) or ubjson")
+	optimize := fs.Bool("optimize", false, "write UBJSON arrays and objects with counts and types")
+	fs.Parse(args)
+	if *format != "ubjson" {
+		return msgpack.FromJSONStream(os.Stdout, os.Stdin)
+	}
+	src := pipe(func(w io.Writer) error {
+		return msgpack.FromJSONStream(w, os.Stdin)
+	})
+	return ubjson.FromMsgpackStream(os.Stdout, src, ubjson.Options{Optimize: *optimize})
+}
+
+// pipe 在另一個 goroutine 中執行 convert，回傳讀取其輸出的 reader，兩段轉換
+// 逐值進行而不需暫存全部的輸出
+func pipe(convert func(io.Writer) error) io.Reader {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(convert(w))
+	}()
+	return r
+}
+
+// formatFlag 定義 -format，binary 的一端為 message pack 或 UBJSON
+func formatFlag(fs *flag.FlagSet, usage string) *string {
+	format := "msgpack"
+	fs.Func("format", usage, func(s string) error {
+		if s != "msgpack" && s != "ubjson" {
+			return fmt.Errorf("unknown format %q", s)
+		}
+		format = s
+		return nil
+	})
+	return &format
+}
+
+func toCSV(args []string) error {
+	fs := flag.NewFlagSet("tocsv", flag.ExitOnError)
+	tsv := fs.Bool("tsv", false, "separate fields with tabs")
+	fs.Parse(args)
+	return convertCSV(msgpack.ToCSV, *tsv, false)
+}
+
+func fromCSV(args []string) error {
+	fs := flag.NewFlagSet("fromcsv", flag.ExitOnError)
+	tsv := fs.Bool("tsv", false, "separate fields with tabs")
+	infer := fs.Bool("infer", false, "write ints, floats, booleans and empty fields as those kinds instead of str")
+	fs.Parse(args)
+	return convertCSV(msgpack.FromCSV, *tsv, *infer)
+}
+
+func convertCSV(convert func([]byte, msgpack.CSVOptions) ([]byte, error), tsv, infer bool) error {
+	opts := msgpack.CSVOptions{InferTypes: infer}
+	if tsv {
+		opts.Comma = '\t'
+	}
+	in, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	out, err := convert(in, opts)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
 }
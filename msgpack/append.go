@@ -0,0 +1,145 @@
+package msgpack
+
+import (
+	"math"
+	"reflect"
+)
+
+// The Append functions write single values with the same formats as
+// FromJSON and Marshal. They let code encode without reflection, such as
+// the methods written by cmd/msgpackgen; the Size functions return the
+// number of bytes the matching Append call writes.
+
+func AppendNil(b []byte) []byte {
+	return append(b, FirstByte["nil"])
+}
+
+func AppendBool(b []byte, v bool) []byte {
+	return append(b, getBoolFormat(v))
+}
+
+func AppendInt(b []byte, v int64) []byte {
+	if v >= 0 {
+		return append(b, getPositiveIntFormat(uint64(v))...)
+	}
+	return append(b, getNegativeIntFormat(v)...)
+}
+
+func AppendUint(b []byte, v uint64) []byte {
+	return append(b, getPositiveIntFormat(v)...)
+}
+
+func AppendFloat32(b []byte, v float32) []byte {
+	return append(b, getFloat32Format(v)...)
+}
+
+func AppendFloat64(b []byte, v float64) []byte {
+	return append(b, getFloatFormat(v)...)
+}
+
+func AppendString(b []byte, s string) []byte {
+	return append(b, getStrFormat(s)...)
+}
+
+// AppendBytes appends v as bin.
+func AppendBytes(b []byte, v []byte) []byte {
+	return append(b, getBinFormat(v)...)
+}
+
+func AppendArrayHeader(b []byte, n int) []byte {
+	return append(b, getArrayFormat(n)...)
+}
+
+func AppendMapHeader(b []byte, n int) []byte {
+	return append(b, getMapFormat(n)...)
+}
+
+// AppendAny appends v encoded by Marshal.
+func AppendAny(b []byte, v interface{}) ([]byte, error) {
+	var e encodeState
+	return e.appendReflect(b, reflect.ValueOf(v))
+}
+
+func IntSize(v int64) int {
+	if v >= 0 {
+		return UintSize(uint64(v))
+	}
+	switch {
+	case v >= -32:
+		return 1
+	case float64(v) > -math.Pow(2, 7):
+		return 2
+	case float64(v) > -math.Pow(2, 15):
+		return 3
+	case float64(v) > -math.Pow(2, 31):
+		return 5
+	default:
+		return 9
+	}
+}
+
+func UintSize(v uint64) int {
+	switch {
+	case v < 128:
+		return 1
+	case float64(v) < math.Pow(2, 8):
+		return 2
+	case float64(v) < math.Pow(2, 16):
+		return 3
+	case float64(v) < math.Pow(2, 32):
+		return 5
+	default:
+		return 9
+	}
+}
+
+func StringSize(s string) int {
+	l := len(s)
+	switch {
+	case l < 32:
+		return 1 + l
+	case float64(l) < math.Pow(2, 8):
+		return 2 + l
+	case float64(l) < math.Pow(2, 16):
+		return 3 + l
+	default:
+		return 5 + l
+	}
+}
+
+func BytesSize(v []byte) int {
+	l := len(v)
+	switch {
+	case float64(l) < math.Pow(2, 8):
+		return 2 + l
+	case float64(l) < math.Pow(2, 16):
+		return 3 + l
+	default:
+		return 5 + l
+	}
+}
+
+func ArrayHeaderSize(n int) int {
+	switch {
+	case n < 16:
+		return 1
+	case n < int(math.Pow(2, 16)):
+		return 3
+	default:
+		return 5
+	}
+}
+
+func MapHeaderSize(n int) int {
+	return ArrayHeaderSize(n)
+}
+
+// AnySize returns the length of the Marshal encoding of v, or 0 if v
+// cannot be encoded.
+func AnySize(v interface{}) int {
+	b, err := AppendAny(nil, v)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
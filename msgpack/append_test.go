@@ -0,0 +1,68 @@
+package msgpack_test
+
+import (
+	. "msgpackconv/msgpack"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendInt(t *testing.T) {
+	tests := []struct {
+		name string
+		v    int64
+		want []byte
+	}{
+		{"positive fixint", 5, []byte{0x05}},
+		{"uint8", 200, []byte{0xcc, 0xc8}},
+		{"negative fixint", -32, []byte{0xe0}},
+		{"int8", -100, []byte{0xd0, 0x9c}},
+		{"int64", -1 << 40, []byte{0xd3, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AppendInt(nil, tt.v)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, len(got), IntSize(tt.v))
+		})
+	}
+}
+
+func TestAppendMatchesMarshal(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name   string
+		v      interface{}
+		append func([]byte) []byte
+		size   int
+	}{
+		{"uint", uint64(70000), func(b []byte) []byte { return AppendUint(b, 70000) }, UintSize(70000)},
+		{"float32", float32(1.5), func(b []byte) []byte { return AppendFloat32(b, 1.5) }, 5},
+		{"float64", 1.5, func(b []byte) []byte { return AppendFloat64(b, 1.5) }, 9},
+		{"string", long, func(b []byte) []byte { return AppendString(b, long) }, StringSize(long)},
+		{"bytes", []byte{1, 2}, func(b []byte) []byte { return AppendBytes(b, []byte{1, 2}) }, BytesSize([]byte{1, 2})},
+		{"nil", nil, AppendNil, 1},
+		{"bool", true, func(b []byte) []byte { return AppendBool(b, true) }, 1},
+		{"array", []int{1, 2}, func(b []byte) []byte {
+			return AppendInt(AppendInt(AppendArrayHeader(b, 2), 1), 2)
+		}, ArrayHeaderSize(2) + 2},
+		{"map", map[string]int{"a": 1}, func(b []byte) []byte {
+			return AppendInt(AppendString(AppendMapHeader(b, 1), "a"), 1)
+		}, MapHeaderSize(1) + 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := Marshal(tt.v)
+			assert.NoError(t, err)
+			got := tt.append([]byte{0xc3})
+			assert.Equal(t, want, got[1:])
+			assert.Equal(t, len(want), tt.size)
+
+			got, err = AppendAny(nil, tt.v)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+			assert.Equal(t, len(want), AnySize(tt.v))
+		})
+	}
+}
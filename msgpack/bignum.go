@@ -0,0 +1,136 @@
+package msgpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// BigIntExtType and BigFloatExtType are the ext types that hold numbers
+// beyond 64 bits. FromJSONWithOptions writes them with BigNumbersExt, and
+// the JSON conversions write them back as exact JSON numbers. A big.Int is
+// stored as a sign byte, 0 or 1 for negative, followed by the big-endian
+// magnitude; a big.Float is stored as its GobEncode bytes.
+const (
+	BigIntExtType   int8 = 16
+	BigFloatExtType int8 = 17
+)
+
+// BigNumberPolicy selects how FromJSONWithOptions writes a JSON number that
+// no int64, uint64 or float64 holds exactly, such as 2^64 or a decimal
+// with more digits than a float64 keeps.
+type BigNumberPolicy int
+
+const (
+	// BigNumbersFloat writes the closest float64, as FromJSON does.
+	BigNumbersFloat BigNumberPolicy = iota
+	// BigNumbersString writes the number literal as a str.
+	BigNumbersString
+	// BigNumbersExt writes a BigIntExtType or BigFloatExtType ext.
+	BigNumbersExt
+	// BigNumbersError fails the conversion with ErrBigNumber.
+	BigNumbersError
+)
+
+// bigNumberFormat 依照 policy 編碼無法以 64 bits 精確表示的數字，數字能精確
+// 表示時 ok 為 false
+func bigNumberFormat(n json.Number, policy BigNumberPolicy) (b []byte, ok bool, err error) {
+	if policy == BigNumbersFloat || fitsNumber(n) {
+		return nil, false, nil
+	}
+	switch policy {
+	case BigNumbersString:
+		return getStrFormat(string(n)), true, nil
+	case BigNumbersExt:
+		if isIntegerLiteral(n) {
+			i, _ := new(big.Int).SetString(string(n), 10)
+			return getExtFormat(BigIntExtType, bigIntBytes(i)), true, nil
+		}
+		// 十進位每個數字約需要 3.33 bits，另外保留 64 bits
+		f, _, err := big.ParseFloat(string(n), 10, uint(len(n))*4+64, big.ToNearestEven)
+		if err != nil {
+			return nil, true, fmt.Errorf("%w: %s", ErrBigNumber, n)
+		}
+		data, err := f.GobEncode()
+		if err != nil {
+			return nil, true, err
+		}
+		return getExtFormat(BigFloatExtType, data), true, nil
+	}
+	return nil, true, fmt.Errorf("%w: %s", ErrBigNumber, n)
+}
+
+func isIntegerLiteral(n json.Number) bool {
+	return !strings.ContainsAny(string(n), ".eE")
+}
+
+// fitsNumber 回傳 n 是否能以 int64, uint64 或 float64 精確表示
+func fitsNumber(n json.Number) bool {
+	if isIntegerLiteral(n) {
+		if _, err := n.Int64(); err == nil {
+			return true
+		}
+		_, err := strconv.ParseUint(string(n), 10, 64)
+		return err == nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return false
+	}
+	// float64 最短的十進位表示法與原本的數字相同時才是精確的
+	neg, digits, exp, ok := normalizeDecimal(string(n))
+	fNeg, fDigits, fExp, _ := normalizeDecimal(strconv.FormatFloat(f, 'e', -1, 64))
+	return ok && neg == fNeg && digits == fDigits && (digits == "" || exp == fExp)
+}
+
+// normalizeDecimal 將十進位數字拆為正負號、去除前後 0 的有效數字，以及
+// 數值等於 0.digits × 10^exp 的 exp
+func normalizeDecimal(s string) (neg bool, digits string, exp int, ok bool) {
+	neg = strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(strings.TrimPrefix(s[i+1:], "+"))
+		if err != nil {
+			return false, "", 0, false
+		}
+		exp, s = e, s[:i]
+	}
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	exp += len(intPart)
+	digits = intPart + fracPart
+	trimmed := strings.TrimLeft(digits, "0")
+	exp -= len(digits) - len(trimmed)
+	return neg, strings.TrimRight(trimmed, "0"), exp, true
+}
+
+func bigIntBytes(i *big.Int) []byte {
+	sign := byte(0)
+	if i.Sign() < 0 {
+		sign = 1
+	}
+	return append([]byte{sign}, i.Bytes()...)
+}
+
+// bigNumberText 將 BigIntExtType 或 BigFloatExtType 的資料轉為 JSON 數字
+func bigNumberText(typ int8, data []byte) (string, error) {
+	switch {
+	case typ == BigIntExtType && len(data) > 0 && data[0] <= 1:
+		i := new(big.Int).SetBytes(data[1:])
+		if data[0] == 1 {
+			i.Neg(i)
+		}
+		return i.Text(10), nil
+	case typ == BigFloatExtType:
+		f := new(big.Float)
+		if err := f.GobDecode(data); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidMsgPack, err)
+		}
+		if f.IsInf() {
+			return "", fmt.Errorf("%w: %s", ErrNotJSONCompatible, f)
+		}
+		return f.Text('g', -1), nil
+	}
+	return "", fmt.Errorf("%w: ext type %d", ErrNotJSONCompatible, typ)
+}
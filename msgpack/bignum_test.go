@@ -0,0 +1,83 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"math/big"
+	. "msgpackconv/msgpack"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBigNumbers(t *testing.T) {
+	type args struct {
+		json   string
+		policy BigNumberPolicy
+	}
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{"fits uint64", args{`18446744073709551615`, BigNumbersString}, []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"fits float64", args{`0.1`, BigNumbersError}, []byte{0xcb, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{"integral float", args{`2.50e1`, BigNumbersError}, []byte{0x19}},
+		{"big int as string", args{`18446744073709551616`, BigNumbersString}, append([]byte{0xb4}, "18446744073709551616"...)},
+		{"big int as ext", args{`-18446744073709551616`, BigNumbersExt}, []byte{0xc7, 0x0a, 0x10, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"precise decimal as string", args{`0.10000000000000000001`, BigNumbersString}, append([]byte{0xb6}, "0.10000000000000000001"...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromJSONWithOptions([]byte(tt.args.json), FromJSONOptions{BigNumbers: tt.args.policy})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := FromJSONWithOptions([]byte(`[1e400]`), FromJSONOptions{BigNumbers: BigNumbersError})
+	assert.ErrorIs(t, err, ErrBigNumber)
+}
+
+func TestBigNumbersRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"big int", `[123456789012345678901234567890, -1]`, `[123456789012345678901234567890,-1]`},
+		{"big float", `{"pi": 3.14159265358979323846264338327950288}`, `{"pi":3.14159265358979323846264338327950288}`},
+		{"exponent", `1e400`, `1e+400`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := FromJSONWithOptions([]byte(tt.json), FromJSONOptions{BigNumbers: BigNumbersExt})
+			assert.NoError(t, err)
+
+			got, err := ToJSONWithOptions(msg, ToJSONOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+
+			var buf bytes.Buffer
+			assert.NoError(t, ToJSONStream(&buf, bytes.NewReader(msg)))
+			assert.Equal(t, tt.want, buf.String())
+
+			v, err := DecodeValue(msg)
+			assert.NoError(t, err)
+			got, err = v.MarshalJSON()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+
+			assert.Equal(t, tt.want, string(ToJSON(msg)))
+		})
+	}
+}
+
+func TestBigIntExt(t *testing.T) {
+	// 以 Value 寫入的 big.Int 也能轉為 JSON
+	i, _ := new(big.Int).SetString("-340282366920938463463374607431768211456", 10)
+	msg, err := Ext(BigIntExtType, append([]byte{1}, i.Bytes()...)).MarshalMsgpack()
+	assert.NoError(t, err)
+	got, err := ToJSONWithOptions(msg, ToJSONOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, i.String(), string(got))
+}
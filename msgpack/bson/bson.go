@@ -0,0 +1,99 @@
+// Package bson converts BSON documents to message pack and back through
+// msgpack.Value, without a MongoDB driver. Documents become maps and
+// arrays arrays; int32 and int64 become ints, double a float64, binary
+// bin, datetime a timestamp ext and ObjectId an ObjectIDExtType ext.
+package bson
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"msgpackconv/msgpack"
+)
+
+var (
+	ErrInvalidBSON     = errors.New("invalid BSON")
+	ErrUnrepresentable = errors.New("value has no counterpart in the other format")
+)
+
+// ObjectIDExtType is the ext type that holds the 12 bytes of an ObjectId,
+// and BinaryExtType the subtype byte followed by the data of a binary
+// whose subtype is not generic (0x00 or the old 0x02).
+const (
+	ObjectIDExtType int8 = 18
+	BinaryExtType   int8 = 19
+)
+
+// ToMsgpack converts exactly one BSON document to a message pack map.
+func ToMsgpack(bson []byte) ([]byte, error) {
+	v, err := DecodeValue(bson)
+	if err != nil {
+		return nil, err
+	}
+	return v.MarshalMsgpack()
+}
+
+// FromMsgpack converts exactly one message pack map to a BSON document.
+func FromMsgpack(msgpackconv []byte) ([]byte, error) {
+	v, err := msgpack.DecodeValue(msgpackconv)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeValue(v)
+}
+
+// ToMsgpackStream converts a sequence of BSON documents, such as a
+// mongodump file, to a sequence of message pack maps.
+func ToMsgpackStream(dst io.Writer, src io.Reader) error {
+	r := bufio.NewReader(src)
+	w := bufio.NewWriter(dst)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err == io.EOF {
+			return w.Flush()
+		} else if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBSON, err)
+		}
+		n := binary.LittleEndian.Uint32(size[:])
+		if n < minDocumentSize {
+			return fmt.Errorf("%w: document of %d bytes", ErrInvalidBSON, n)
+		}
+		doc := make([]byte, n)
+		copy(doc, size[:])
+		if _, err := io.ReadFull(r, doc[4:]); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBSON, io.ErrUnexpectedEOF)
+		}
+		b, err := ToMsgpack(doc)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+}
+
+// FromMsgpackStream converts a sequence of message pack maps to a sequence
+// of BSON documents.
+func FromMsgpackStream(dst io.Writer, src io.Reader) error {
+	dec := msgpack.NewDecoder(src)
+	w := bufio.NewWriter(dst)
+	for {
+		var v msgpack.Value
+		if err := dec.Decode(&v); err == io.EOF {
+			return w.Flush()
+		} else if err != nil {
+			return err
+		}
+		b, err := EncodeValue(v)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package bson
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"msgpackconv/msgpack"
+)
+
+// element types
+const (
+	typeDouble   = 0x01
+	typeString   = 0x02
+	typeDocument = 0x03
+	typeArray    = 0x04
+	typeBinary   = 0x05
+	// undefined 已不建議使用，讀取時視為 null
+	typeUndefined = 0x06
+	typeObjectID  = 0x07
+	typeBool      = 0x08
+	typeDateTime  = 0x09
+	typeNull      = 0x0a
+	typeInt32     = 0x10
+	typeInt64     = 0x12
+)
+
+// binary subtypes
+const (
+	subtypeGeneric = 0x00
+	// 舊的 binary subtype，資料前還有一個 int32 長度
+	subtypeBinaryOld = 0x02
+)
+
+// 空的 document 為 int32 長度與結尾的 0x00
+const minDocumentSize = 5
+
+// DecodeValue decodes exactly one BSON document as a map.
+func DecodeValue(data []byte) (msgpack.Value, error) {
+	d := &decoder{data: data}
+	v, err := d.document(false)
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	if d.off != len(data) {
+		return msgpack.Value{}, fmt.Errorf("%w: %d bytes after the document", ErrInvalidBSON, len(data)-d.off)
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	off  int
+}
+
+func (d *decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrInvalidBSON, d.off, fmt.Sprintf(format, args...))
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.off {
+		return nil, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+func (d *decoder) int32() (int32, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (d *decoder) int64() (int64, error) {
+	b, err := d.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(b)), nil
+}
+
+func (d *decoder) cstring() (string, error) {
+	i := bytes.IndexByte(d.data[d.off:], 0)
+	if i < 0 {
+		return "", d.errorf("unterminated element name")
+	}
+	s := string(d.data[d.off : d.off+i])
+	d.off += i + 1
+	return s, nil
+}
+
+// document 讀取 document，isArray 時忽略 key 轉為 array
+func (d *decoder) document(isArray bool) (msgpack.Value, error) {
+	start := d.off
+	size, err := d.int32()
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	if size < minDocumentSize || int(size) > len(d.data)-start {
+		d.off = start
+		return msgpack.Value{}, d.errorf("document of %d bytes", size)
+	}
+	end := start + int(size)
+	items := []msgpack.Value{}
+	entries := []msgpack.Entry{}
+	for d.off < end-1 {
+		typ := d.data[d.off]
+		d.off++
+		name, err := d.cstring()
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		v, err := d.element(typ)
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		if isArray {
+			items = append(items, v)
+		} else {
+			entries = append(entries, msgpack.Pair(name, v))
+		}
+	}
+	if d.off != end-1 || d.data[d.off] != 0 {
+		return msgpack.Value{}, d.errorf("document does not end at its declared size")
+	}
+	d.off++
+	if isArray {
+		return msgpack.Array(items...), nil
+	}
+	return msgpack.Map(entries...), nil
+}
+
+func (d *decoder) element(typ byte) (msgpack.Value, error) {
+	switch typ {
+	case typeDouble:
+		n, err := d.int64()
+		return msgpack.Float64(math.Float64frombits(uint64(n))), err
+	case typeString:
+		s, err := d.string()
+		return msgpack.Str(s), err
+	case typeDocument:
+		return d.document(false)
+	case typeArray:
+		return d.document(true)
+	case typeBinary:
+		return d.binary()
+	case typeUndefined, typeNull:
+		return msgpack.Nil(), nil
+	case typeObjectID:
+		b, err := d.read(12)
+		return msgpack.Ext(ObjectIDExtType, b), err
+	case typeBool:
+		b, err := d.read(1)
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		if b[0] > 1 {
+			return msgpack.Value{}, d.errorf("boolean byte 0x%02x", b[0])
+		}
+		return msgpack.Bool(b[0] == 1), nil
+	case typeDateTime:
+		ms, err := d.int64()
+		return msgpack.Timestamp(time.UnixMilli(ms)), err
+	case typeInt32:
+		n, err := d.int32()
+		return intValue(int64(n)), err
+	case typeInt64:
+		n, err := d.int64()
+		return intValue(n), err
+	}
+	return msgpack.Value{}, fmt.Errorf("%w at offset %d: element type 0x%02x", ErrUnrepresentable, d.off, typ)
+}
+
+func intValue(n int64) msgpack.Value {
+	if n < 0 {
+		return msgpack.Int(n)
+	}
+	return msgpack.Uint(uint64(n))
+}
+
+func (d *decoder) string() (string, error) {
+	n, err := d.int32()
+	if err != nil {
+		return "", err
+	}
+	if n < 1 {
+		return "", d.errorf("string of %d bytes", n)
+	}
+	b, err := d.read(int(n))
+	if err != nil {
+		return "", err
+	}
+	if b[n-1] != 0 {
+		return "", d.errorf("unterminated string")
+	}
+	return string(b[:n-1]), nil
+}
+
+func (d *decoder) binary() (msgpack.Value, error) {
+	n, err := d.int32()
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	if n < 0 {
+		return msgpack.Value{}, d.errorf("binary of %d bytes", n)
+	}
+	b, err := d.read(int(n) + 1)
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	subtype, data := b[0], b[1:]
+	switch subtype {
+	case subtypeGeneric:
+		return msgpack.Bin(data), nil
+	case subtypeBinaryOld:
+		if len(data) < 4 || int(binary.LittleEndian.Uint32(data)) != len(data)-4 {
+			return msgpack.Value{}, d.errorf("binary subtype 0x02 with a wrong inner length")
+		}
+		return msgpack.Bin(data[4:]), nil
+	}
+	return msgpack.Ext(BinaryExtType, b), nil
+}
@@ -0,0 +1,80 @@
+package bson_test
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"msgpackconv/msgpack"
+	. "msgpackconv/msgpack/bson"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// doc 以 hex 寫成的 elements 組成 document，自動加上長度與結尾
+func doc(elements ...string) string {
+	body := strings.Join(elements, "")
+	size := binary.LittleEndian.AppendUint32(nil, uint32(len(body)/2+5))
+	return hex.EncodeToString(size) + body + "00"
+}
+
+func TestDecodeValue(t *testing.T) {
+	tests := []struct {
+		name string
+		bson string
+		want string
+	}{
+		{"empty", doc(), `{}`},
+		{"double", doc("01" + "6100" + "000000000000f83f"), `{"a": 1.5}`},
+		{"string", doc("02" + "6100" + "03000000" + "686900"), `{"a": "hi"}`},
+		{"document", doc("03"+"6100"+doc("08"+"6200"+"01"), "0a"+"6300"), `{"a": {"b": true}, "c": nil}`},
+		{"array", doc("04" + "6100" + doc("10"+"3000"+"ffffffff", "10"+"3100"+"02000000")), `{"a": [-1, 2]}`},
+		{"binary", doc("05" + "6100" + "02000000" + "00" + "00ff"), `{"a": h'00ff'}`},
+		{"old binary", doc("05" + "6100" + "05000000" + "02" + "01000000" + "ff"), `{"a": h'ff'}`},
+		{"uuid binary", doc("05" + "6100" + "01000000" + "04" + "ff"), `{"a": ext(19, h'04ff')}`},
+		{"undefined", doc("06" + "6100"), `{"a": nil}`},
+		{"ObjectId", doc("07" + "5f696400" + "507f1f77bcf86cd799439011"), `{"_id": ext(18, h'507f1f77bcf86cd799439011')}`},
+		{"bool", doc("08"+"6100"+"00", "08"+"6200"+"01"), `{"a": false, "b": true}`},
+		{"datetime", doc("09" + "6100" + "e803000000000000"), `{"a": ext(-1, h'00000001')}`},
+		{"datetime with milliseconds", doc("09" + "6100" + "e903000000000000"), `{"a": ext(-1, h'003d090000000001')}`},
+		{"int64", doc("12" + "6100" + "0000000001000000"), `{"a": 4294967296}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.bson)
+			got, err := ToMsgpack(data)
+			assert.NoError(t, err)
+			diag, err := msgpack.ToDiagnostic(got)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, diag)
+		})
+	}
+}
+
+func TestDecodeValueFail(t *testing.T) {
+	tests := []struct {
+		name string
+		bson string
+		err  error
+	}{
+		{"empty", "", ErrInvalidBSON},
+		{"size too large", "06000000" + "00", ErrInvalidBSON},
+		{"size too small", "04000000", ErrInvalidBSON},
+		{"missing terminator", "0500000001", ErrInvalidBSON},
+		{"element beyond document", "08000000" + "10" + "6100" + "01000000" + "00", ErrInvalidBSON},
+		{"unterminated name", "07000000" + "106100", ErrInvalidBSON},
+		{"unterminated string", doc("02" + "6100" + "01000000" + "61"), ErrInvalidBSON},
+		{"negative binary size", doc("05" + "6100" + "ffffffff" + "00"), ErrInvalidBSON},
+		{"bad boolean", doc("08" + "6100" + "02"), ErrInvalidBSON},
+		{"trailing data", doc() + "00", ErrInvalidBSON},
+		{"regular expression", doc("0b" + "6100" + "00" + "00"), ErrUnrepresentable},
+		{"decimal128", doc("13" + "6100" + strings.Repeat("00", 16)), ErrUnrepresentable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.bson)
+			_, err := DecodeValue(data)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package bson
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"msgpackconv/msgpack"
+)
+
+// EncodeValue encodes a map with str keys as a BSON document. Ints are
+// written as int32 when they fit and int64 otherwise, floats as double and
+// timestamp exts as datetime, whose precision is milliseconds; finer
+// precision is truncated. Uint values above int64 and ext values other
+// than timestamp, ObjectIDExtType and BinaryExtType fail with
+// ErrUnrepresentable.
+func EncodeValue(v msgpack.Value) ([]byte, error) {
+	if v.Kind() != msgpack.MapKind {
+		return nil, fmt.Errorf("%w: document must be a map, not %s", ErrUnrepresentable, v.Kind())
+	}
+	return appendDocument(nil, v)
+}
+
+// appendDocument 先保留 int32 長度，寫完所有 element 後再填入
+func appendDocument(ans []byte, v msgpack.Value) ([]byte, error) {
+	start := len(ans)
+	ans = append(ans, 0, 0, 0, 0)
+	var err error
+	if v.Kind() == msgpack.ArrayKind {
+		for i, item := range v.Elements() {
+			if ans, err = appendElement(ans, strconv.Itoa(i), item); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		for key, val := range v.Entries() {
+			name, ok := key.Str()
+			if !ok {
+				return nil, fmt.Errorf("%w: document key of kind %s", ErrUnrepresentable, key.Kind())
+			}
+			if strings.IndexByte(name, 0) >= 0 {
+				return nil, fmt.Errorf("%w: document key %q contains a NUL byte", ErrUnrepresentable, name)
+			}
+			if ans, err = appendElement(ans, name, val); err != nil {
+				return nil, err
+			}
+		}
+	}
+	ans = append(ans, 0)
+	if len(ans)-start > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: document of %d bytes", ErrUnrepresentable, len(ans)-start)
+	}
+	binary.LittleEndian.PutUint32(ans[start:], uint32(len(ans)-start))
+	return ans, nil
+}
+
+func appendElement(ans []byte, name string, v msgpack.Value) ([]byte, error) {
+	// type 在寫完值後才確定，先保留位置
+	typeAt := len(ans)
+	ans = append(append(ans, 0), name...)
+	ans = append(ans, 0)
+	var typ byte
+	var err error
+	switch v.Kind() {
+	case msgpack.NilKind:
+		typ = typeNull
+	case msgpack.BoolKind:
+		b, _ := v.Bool()
+		typ = typeBool
+		if b {
+			ans = append(ans, 1)
+		} else {
+			ans = append(ans, 0)
+		}
+	case msgpack.IntKind, msgpack.UintKind:
+		n, ok := v.Int()
+		if !ok {
+			u, _ := v.Uint()
+			return nil, fmt.Errorf("%w: %s holds %d, beyond int64", ErrUnrepresentable, name, u)
+		}
+		if n >= math.MinInt32 && n <= math.MaxInt32 {
+			typ = typeInt32
+			ans = binary.LittleEndian.AppendUint32(ans, uint32(n))
+		} else {
+			typ = typeInt64
+			ans = binary.LittleEndian.AppendUint64(ans, uint64(n))
+		}
+	case msgpack.Float32Kind, msgpack.Float64Kind:
+		f, _ := v.Float()
+		typ = typeDouble
+		ans = binary.LittleEndian.AppendUint64(ans, math.Float64bits(f))
+	case msgpack.StrKind:
+		s, _ := v.Str()
+		typ = typeString
+		ans = binary.LittleEndian.AppendUint32(ans, uint32(len(s)+1))
+		ans = append(append(ans, s...), 0)
+	case msgpack.BinKind:
+		b, _ := v.Bytes()
+		typ = typeBinary
+		ans = binary.LittleEndian.AppendUint32(ans, uint32(len(b)))
+		ans = append(append(ans, subtypeGeneric), b...)
+	case msgpack.ArrayKind:
+		typ = typeArray
+		if ans, err = appendDocument(ans, v); err != nil {
+			return nil, err
+		}
+	case msgpack.MapKind:
+		typ = typeDocument
+		if ans, err = appendDocument(ans, v); err != nil {
+			return nil, err
+		}
+	case msgpack.ExtKind:
+		if typ, ans, err = appendExt(ans, name, v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, msgpack.ErrKindMismatch
+	}
+	ans[typeAt] = typ
+	return ans, nil
+}
+
+func appendExt(ans []byte, name string, v msgpack.Value) (byte, []byte, error) {
+	typ, _ := v.ExtType()
+	data, _ := v.Bytes()
+	switch {
+	case typ == msgpack.TimestampExtType:
+		t, ok := v.Time()
+		if !ok {
+			return 0, nil, fmt.Errorf("%w: timestamp ext of %d bytes", msgpack.ErrInvalidMsgPack, len(data))
+		}
+		return typeDateTime, binary.LittleEndian.AppendUint64(ans, uint64(t.UnixMilli())), nil
+	case typ == ObjectIDExtType:
+		if len(data) != 12 {
+			return 0, nil, fmt.Errorf("%w: ObjectId of %d bytes", msgpack.ErrInvalidMsgPack, len(data))
+		}
+		return typeObjectID, append(ans, data...), nil
+	case typ == BinaryExtType:
+		if len(data) == 0 {
+			return 0, nil, fmt.Errorf("%w: binary ext without a subtype", msgpack.ErrInvalidMsgPack)
+		}
+		ans = binary.LittleEndian.AppendUint32(ans, uint32(len(data)-1))
+		return typeBinary, append(ans, data...), nil
+	}
+	return 0, nil, fmt.Errorf("%w: %s holds ext type %d", ErrUnrepresentable, name, typ)
+}
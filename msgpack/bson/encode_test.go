@@ -0,0 +1,82 @@
+package bson_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"msgpackconv/msgpack"
+	. "msgpackconv/msgpack/bson"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value msgpack.Value
+		want  string
+	}{
+		{"empty", msgpack.Map(), doc()},
+		{"int32", msgpack.Map(msgpack.Pair("a", msgpack.Int(-1))), doc("10" + "6100" + "ffffffff")},
+		{"int64", msgpack.Map(msgpack.Pair("a", msgpack.Uint(1<<32))), doc("12" + "6100" + "0000000001000000")},
+		{"float", msgpack.Map(msgpack.Pair("a", msgpack.Float32(1.5))), doc("01" + "6100" + "000000000000f83f")},
+		{"str", msgpack.Map(msgpack.Pair("a", msgpack.Str("hi"))), doc("02" + "6100" + "03000000" + "686900")},
+		{"bin", msgpack.Map(msgpack.Pair("a", msgpack.Bin([]byte{0xff}))), doc("05" + "6100" + "01000000" + "00" + "ff")},
+		{"nil and bool", msgpack.Map(msgpack.Pair("a", msgpack.Nil()), msgpack.Pair("b", msgpack.Bool(true))),
+			doc("0a"+"6100", "08"+"6200"+"01")},
+		{"array", msgpack.Map(msgpack.Pair("a", msgpack.Array(msgpack.Str("x"), msgpack.Map()))),
+			doc("04" + "6100" + doc("02"+"3000"+"02000000"+"7800", "03"+"3100"+doc()))},
+		{"timestamp", msgpack.Map(msgpack.Pair("a", msgpack.Timestamp(time.UnixMilli(1001)))), doc("09" + "6100" + "e903000000000000")},
+		{"truncated timestamp", msgpack.Map(msgpack.Pair("a", msgpack.Timestamp(time.Unix(1, 999)))), doc("09" + "6100" + "e803000000000000")},
+		{"ObjectId", msgpack.Map(msgpack.Pair("_id", msgpack.Ext(ObjectIDExtType, bytes.Repeat([]byte{1}, 12)))),
+			doc("07" + "5f696400" + strings.Repeat("01", 12))},
+		{"binary subtype", msgpack.Map(msgpack.Pair("a", msgpack.Ext(BinaryExtType, []byte{0x04, 0xff}))), doc("05" + "6100" + "01000000" + "04" + "ff")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncodeValue(tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, hex.EncodeToString(got))
+		})
+	}
+}
+
+func TestEncodeValueFail(t *testing.T) {
+	tests := []struct {
+		name  string
+		value msgpack.Value
+		err   error
+	}{
+		{"not a map", msgpack.Array(), ErrUnrepresentable},
+		{"int key", msgpack.Map(msgpack.Entry{Key: msgpack.Uint(1), Value: msgpack.Nil()}), ErrUnrepresentable},
+		{"NUL in key", msgpack.Map(msgpack.Pair("a\x00", msgpack.Nil())), ErrUnrepresentable},
+		{"uint64", msgpack.Map(msgpack.Pair("a", msgpack.Uint(1<<63))), ErrUnrepresentable},
+		{"other ext", msgpack.Map(msgpack.Pair("a", msgpack.Ext(5, nil))), ErrUnrepresentable},
+		{"short ObjectId", msgpack.Map(msgpack.Pair("a", msgpack.Ext(ObjectIDExtType, []byte{1}))), msgpack.ErrInvalidMsgPack},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := EncodeValue(tt.value)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	first, _ := hex.DecodeString(doc("10" + "6100" + "01000000"))
+	second, _ := hex.DecodeString(doc("07" + "5f696400" + "507f1f77bcf86cd799439011"))
+	dump := append(first, second...)
+
+	var msgpackconv bytes.Buffer
+	assert.NoError(t, ToMsgpackStream(&msgpackconv, bytes.NewReader(dump)))
+	assert.Equal(t, "81a16101"+"81a35f6964c70c12507f1f77bcf86cd799439011", hex.EncodeToString(msgpackconv.Bytes()))
+
+	var back bytes.Buffer
+	assert.NoError(t, FromMsgpackStream(&back, &msgpackconv))
+	assert.Equal(t, dump, back.Bytes())
+
+	err := ToMsgpackStream(&msgpackconv, bytes.NewReader(dump[:len(dump)-1]))
+	assert.ErrorIs(t, err, ErrInvalidBSON)
+}
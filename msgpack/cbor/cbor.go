@@ -0,0 +1,119 @@
+// Package cbor converts CBOR (RFC 8949) to message pack and back through
+// msgpack.Value. Byte strings are bin, tag 1 epoch times are timestamp
+// exts, tags 2 and 3 bignums and negative integers below -2^63 are
+// msgpack.BigIntExtType exts, and other ext types travel as byte strings
+// under the tags given to RegisterTag.
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"msgpackconv/msgpack"
+)
+
+var (
+	ErrInvalidCBOR     = errors.New("invalid CBOR")
+	ErrUnrepresentable = errors.New("item has no counterpart in the other format")
+	ErrTagRegistered   = errors.New("tag or ext type already registered")
+)
+
+// Options controls how CBOR items without an exact message pack counterpart
+// are converted.
+type Options struct {
+	// Strict fails with ErrUnrepresentable on the items that are otherwise
+	// approximated: simple values other than false, true and null become
+	// nil, indefinite-length items become definite ones, unknown tags are
+	// dropped, timestamps with nanoseconds a float64 does not hold are
+	// rounded and float64 epoch times finer than nanoseconds are rounded.
+	Strict bool
+}
+
+// ToMsgpack converts exactly one CBOR item to message pack.
+func ToMsgpack(data []byte) ([]byte, error) {
+	return ToMsgpackWithOptions(data, Options{})
+}
+
+func ToMsgpackWithOptions(data []byte, opts Options) ([]byte, error) {
+	v, err := DecodeValue(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return v.MarshalMsgpack()
+}
+
+// FromMsgpack converts exactly one message pack value to CBOR.
+func FromMsgpack(msgpackconv []byte) ([]byte, error) {
+	return FromMsgpackWithOptions(msgpackconv, Options{})
+}
+
+func FromMsgpackWithOptions(msgpackconv []byte, opts Options) ([]byte, error) {
+	v, err := msgpack.DecodeValue(msgpackconv)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeValue(v, opts)
+}
+
+// 保留給 package 本身處理的 tag：0 與 1 為時間，2 與 3 為 bignum
+const (
+	tagDateTime     = 0
+	tagEpochTime    = 1
+	tagPosBignum    = 2
+	tagNegBignum    = 3
+	tagSelfDescribe = 55799
+)
+
+var registry = struct {
+	sync.RWMutex
+	tags  map[int8]uint64
+	types map[uint64]int8
+}{tags: map[int8]uint64{}, types: map[uint64]int8{}}
+
+// RegisterTag makes ext values of extType convert to their data as a byte
+// string under tag, and such tagged byte strings convert back to the ext.
+// Tags 0 to 3 and 55799, the timestamp, msgpack.BigIntExtType and
+// msgpack.BigFloatExtType ext types and a type or tag that is already
+// registered are refused with ErrTagRegistered.
+func RegisterTag(extType int8, tag uint64) error {
+	if tag <= tagNegBignum || tag == tagSelfDescribe || extType == msgpack.TimestampExtType ||
+		extType == msgpack.BigIntExtType || extType == msgpack.BigFloatExtType {
+		return fmt.Errorf("%w: tag %d for ext type %d is reserved", ErrTagRegistered, tag, extType)
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.tags[extType]; ok {
+		return fmt.Errorf("%w: ext type %d", ErrTagRegistered, extType)
+	}
+	if _, ok := registry.types[tag]; ok {
+		return fmt.Errorf("%w: tag %d", ErrTagRegistered, tag)
+	}
+	registry.tags[extType] = tag
+	registry.types[tag] = extType
+	return nil
+}
+
+// UnregisterTag removes the tag registered for extType, if any.
+func UnregisterTag(extType int8) {
+	registry.Lock()
+	defer registry.Unlock()
+	if tag, ok := registry.tags[extType]; ok {
+		delete(registry.tags, extType)
+		delete(registry.types, tag)
+	}
+}
+
+func tagOf(extType int8) (uint64, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	tag, ok := registry.tags[extType]
+	return tag, ok
+}
+
+func extTypeOf(tag uint64) (int8, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	typ, ok := registry.types[tag]
+	return typ, ok
+}
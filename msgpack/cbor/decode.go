@@ -0,0 +1,368 @@
+package cbor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+	"unicode/utf8"
+
+	"msgpackconv/msgpack"
+)
+
+// major types
+const (
+	majorUint = iota
+	majorNegInt
+	majorBytes
+	majorText
+	majorArray
+	majorMap
+	majorTag
+	majorSimple
+)
+
+// additional information 為 31 時長度不定，major type 7 則是 break
+const indefinite = 31
+
+// DecodeValue decodes exactly one CBOR item.
+func DecodeValue(data []byte, opts Options) (msgpack.Value, error) {
+	d := &decoder{data: data, opts: opts}
+	v, err := d.value()
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	if d.off != len(data) {
+		return msgpack.Value{}, fmt.Errorf("%w: %d bytes after the item", ErrInvalidCBOR, len(data)-d.off)
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	off  int
+	opts Options
+}
+
+func (d *decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrInvalidCBOR, d.off, fmt.Sprintf(format, args...))
+}
+
+// unrepresentable 在 strict 時回傳位於 off 的 item 無法轉換的錯誤，否則回傳
+// nil 讓呼叫者近似轉換
+func (d *decoder) unrepresentable(off int, format string, args ...any) error {
+	if !d.opts.Strict {
+		return nil
+	}
+	return fmt.Errorf("%w at offset %d: %s", ErrUnrepresentable, off, fmt.Sprintf(format, args...))
+}
+
+func (d *decoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+// head 讀取 initial byte 與其後的 argument
+func (d *decoder) head() (major, info byte, arg uint64, err error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		// 24 到 27 依序為 1, 2, 4, 8 bytes 的 argument
+		b, err := d.read(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, c := range b {
+			arg = arg<<8 | uint64(c)
+		}
+		return major, info, arg, nil
+	case info == indefinite:
+		return major, info, 0, nil
+	}
+	d.off--
+	return 0, 0, 0, d.errorf("reserved additional information %d", info)
+}
+
+// isBreak 回傳下一個 byte 是否為 break，是的話一併讀掉
+func (d *decoder) isBreak() (bool, error) {
+	if d.off >= len(d.data) {
+		return false, d.errorf("unexpected end of data")
+	}
+	if d.data[d.off] != majorSimple<<5|indefinite {
+		return false, nil
+	}
+	d.off++
+	return true, nil
+}
+
+func (d *decoder) value() (msgpack.Value, error) {
+	start := d.off
+	major, info, arg, err := d.head()
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	if info == indefinite {
+		if major == majorUint || major == majorNegInt || major == majorTag || major == majorSimple {
+			d.off = start
+			return msgpack.Value{}, d.errorf("unexpected indefinite length or break")
+		}
+		if err := d.unrepresentable(start, "indefinite-length item"); err != nil {
+			return msgpack.Value{}, err
+		}
+	}
+	switch major {
+	case majorUint:
+		return msgpack.Uint(arg), nil
+	case majorNegInt:
+		if arg <= math.MaxInt64 {
+			return msgpack.Int(-1 - int64(arg)), nil
+		}
+		// 與 tag 3 的 bignum 相同，以 BigIntExtType 精確表示
+		return bignum(true, binary.BigEndian.AppendUint64(nil, arg)), nil
+	case majorBytes:
+		b, err := d.bytes(major, info, arg)
+		return msgpack.Bin(b), err
+	case majorText:
+		b, err := d.bytes(major, info, arg)
+		return msgpack.Str(string(b)), err
+	case majorArray:
+		return d.array(info, arg)
+	case majorMap:
+		return d.mapValue(info, arg)
+	case majorTag:
+		return d.tag(start, arg)
+	}
+	return d.simple(start, info, arg)
+}
+
+// bytes 讀取 byte string 或 text string，長度不定時將各段串接起來
+func (d *decoder) bytes(major, info byte, arg uint64) ([]byte, error) {
+	if info != indefinite {
+		b, err := d.read(arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == majorText && !utf8.Valid(b) {
+			return nil, d.errorf("text string is not valid UTF-8")
+		}
+		return b, nil
+	}
+	ans := []byte{}
+	for {
+		if end, err := d.isBreak(); err != nil || end {
+			return ans, err
+		}
+		chunkMajor, chunkInfo, chunkArg, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkInfo == indefinite {
+			return nil, d.errorf("chunk of an indefinite-length string has major type %d", chunkMajor)
+		}
+		chunk, err := d.bytes(major, chunkInfo, chunkArg)
+		if err != nil {
+			return nil, err
+		}
+		ans = append(ans, chunk...)
+	}
+}
+
+func (d *decoder) array(info byte, arg uint64) (msgpack.Value, error) {
+	// 每個 item 至少 1 byte，先確認長度合理再配置記憶體
+	if info != indefinite && arg > uint64(len(d.data)-d.off) {
+		return msgpack.Value{}, d.errorf("unexpected end of data")
+	}
+	items := []msgpack.Value{}
+	for i := uint64(0); info == indefinite || i < arg; i++ {
+		if info == indefinite {
+			if end, err := d.isBreak(); err != nil || end {
+				return msgpack.Array(items...), err
+			}
+		}
+		item, err := d.value()
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		items = append(items, item)
+	}
+	return msgpack.Array(items...), nil
+}
+
+func (d *decoder) mapValue(info byte, arg uint64) (msgpack.Value, error) {
+	if info != indefinite && arg > uint64(len(d.data)-d.off)/2 {
+		return msgpack.Value{}, d.errorf("unexpected end of data")
+	}
+	entries := []msgpack.Entry{}
+	for i := uint64(0); info == indefinite || i < arg; i++ {
+		if info == indefinite {
+			if end, err := d.isBreak(); err != nil || end {
+				return msgpack.Map(entries...), err
+			}
+		}
+		key, err := d.value()
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		val, err := d.value()
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		entries = append(entries, msgpack.Entry{Key: key, Value: val})
+	}
+	return msgpack.Map(entries...), nil
+}
+
+func (d *decoder) tag(start int, tag uint64) (msgpack.Value, error) {
+	content, err := d.value()
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	switch tag {
+	case tagDateTime:
+		s, ok := content.Str()
+		if !ok {
+			return msgpack.Value{}, d.tagError(start, tag, content)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			d.off = start
+			return msgpack.Value{}, d.errorf("tag 0: %v", err)
+		}
+		return msgpack.Timestamp(t), nil
+	case tagEpochTime:
+		return d.epochTime(start, content)
+	case tagPosBignum, tagNegBignum:
+		b, ok := content.Bytes()
+		if !ok || content.Kind() != msgpack.BinKind {
+			return msgpack.Value{}, d.tagError(start, tag, content)
+		}
+		return bignum(tag == tagNegBignum, b), nil
+	case tagSelfDescribe:
+		return content, nil
+	}
+	if typ, ok := extTypeOf(tag); ok {
+		b, ok := content.Bytes()
+		if !ok || content.Kind() != msgpack.BinKind {
+			return msgpack.Value{}, d.tagError(start, tag, content)
+		}
+		return msgpack.Ext(typ, b), nil
+	}
+	if err := d.unrepresentable(start, "unregistered tag %d", tag); err != nil {
+		return msgpack.Value{}, err
+	}
+	return content, nil
+}
+
+func (d *decoder) tagError(start int, tag uint64, content msgpack.Value) error {
+	d.off = start
+	return d.errorf("tag %d cannot hold %s", tag, content.Kind())
+}
+
+// epochTime 將 tag 1 的秒數轉為 timestamp ext
+func (d *decoder) epochTime(start int, content msgpack.Value) (msgpack.Value, error) {
+	if sec, ok := content.Int(); ok {
+		return msgpack.Timestamp(time.Unix(sec, 0)), nil
+	}
+	f, ok := content.Float()
+	if !ok {
+		return msgpack.Value{}, d.tagError(start, tagEpochTime, content)
+	}
+	// ±2^63 秒以外的時間 time.Time 無法表示
+	if math.IsNaN(f) || f < -(1<<63) || f >= 1<<63 {
+		d.off = start
+		return msgpack.Value{}, d.errorf("tag 1 holds %v", f)
+	}
+	sec, nsec := splitSeconds(f)
+	// 與 appendTimestamp 相同，以寫回的 float64 判斷是否有捨入
+	if float64(sec)+float64(nsec)/1e9 != f {
+		if err := d.unrepresentable(start, "epoch time %v rounded to nanoseconds", f); err != nil {
+			return msgpack.Value{}, err
+		}
+	}
+	return msgpack.Timestamp(time.Unix(sec, nsec)), nil
+}
+
+// splitSeconds 將秒數拆為整數秒與四捨五入的 nanoseconds
+func splitSeconds(f float64) (sec, nsec int64) {
+	whole := math.Floor(f)
+	nsec = int64(math.Round((f - whole) * 1e9))
+	sec = int64(whole)
+	if nsec == 1e9 {
+		sec, nsec = sec+1, 0
+	}
+	return sec, nsec
+}
+
+// bignum 將 tag 2 或 3 的大小轉為整數，64 bits 放不下時轉為
+// msgpack.BigIntExtType ext
+func bignum(negative bool, b []byte) msgpack.Value {
+	n := new(big.Int).SetBytes(b)
+	if negative {
+		// tag 3 的值為 -1 - n
+		n.Add(n, big.NewInt(1)).Neg(n)
+	}
+	switch {
+	case n.IsUint64():
+		return msgpack.Uint(n.Uint64())
+	case n.IsInt64():
+		return msgpack.Int(n.Int64())
+	}
+	sign := byte(0)
+	if negative {
+		sign = 1
+	}
+	return msgpack.Ext(msgpack.BigIntExtType, append([]byte{sign}, n.Bytes()...))
+}
+
+func (d *decoder) simple(start int, info byte, arg uint64) (msgpack.Value, error) {
+	switch info {
+	case 20, 21:
+		return msgpack.Bool(info == 21), nil
+	case 22:
+		return msgpack.Nil(), nil
+	case 25:
+		return msgpack.Float32(halfToFloat32(uint16(arg))), nil
+	case 26:
+		return msgpack.Float32(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return msgpack.Float64(math.Float64frombits(arg)), nil
+	}
+	if info == 24 && arg < 32 {
+		d.off = start
+		return msgpack.Value{}, d.errorf("simple value %d in two bytes", arg)
+	}
+	if err := d.unrepresentable(start, "simple value %d", arg); err != nil {
+		return msgpack.Value{}, err
+	}
+	return msgpack.Nil(), nil
+}
+
+// halfToFloat32 將 IEEE 754 half precision 轉為 float32，所有值皆可精確表示
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		// subnormal 的值為 frac × 2^-24
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
@@ -0,0 +1,183 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"math"
+	"msgpackconv/msgpack"
+	. "msgpackconv/msgpack/cbor"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeValue(t *testing.T) {
+	// RFC 8949 Appendix A 的範例
+	tests := []struct {
+		name string
+		cbor string
+		want msgpack.Value
+	}{
+		{"0", "00", msgpack.Uint(0)},
+		{"23", "17", msgpack.Uint(23)},
+		{"24", "1818", msgpack.Uint(24)},
+		{"1000", "1903e8", msgpack.Uint(1000)},
+		{"1000000", "1a000f4240", msgpack.Uint(1000000)},
+		{"max uint64", "1bffffffffffffffff", msgpack.Uint(math.MaxUint64)},
+		{"-1", "20", msgpack.Int(-1)},
+		{"-1000", "3903e7", msgpack.Int(-1000)},
+		{"min int64", "3b7fffffffffffffff", msgpack.Int(math.MinInt64)},
+		{"half 1.5", "f93e00", msgpack.Float32(1.5)},
+		{"half 65504", "f97bff", msgpack.Float32(65504)},
+		{"half subnormal", "f90001", msgpack.Float32(5.960464477539063e-8)},
+		{"half -0", "f98000", msgpack.Float32(float32(math.Copysign(0, -1)))},
+		{"half infinity", "f97c00", msgpack.Float32(float32(math.Inf(1)))},
+		{"float 100000", "fa47c35000", msgpack.Float32(100000)},
+		{"double 1.1", "fb3ff199999999999a", msgpack.Float64(1.1)},
+		{"false", "f4", msgpack.Bool(false)},
+		{"true", "f5", msgpack.Bool(true)},
+		{"null", "f6", msgpack.Nil()},
+		{"byte string", "4401020304", msgpack.Bin([]byte{1, 2, 3, 4})},
+		{"text string", "62c3bc", msgpack.Str("ü")},
+		{"array", "8301820203820405", msgpack.Array(
+			msgpack.Uint(1),
+			msgpack.Array(msgpack.Uint(2), msgpack.Uint(3)),
+			msgpack.Array(msgpack.Uint(4), msgpack.Uint(5)),
+		)},
+		{"map", "a201020304", msgpack.Map(
+			msgpack.Entry{Key: msgpack.Uint(1), Value: msgpack.Uint(2)},
+			msgpack.Entry{Key: msgpack.Uint(3), Value: msgpack.Uint(4)},
+		)},
+		{"map with str keys", "a26161016162820203", msgpack.Map(
+			msgpack.Pair("a", msgpack.Uint(1)),
+			msgpack.Pair("b", msgpack.Array(msgpack.Uint(2), msgpack.Uint(3))),
+		)},
+		{"epoch time", "c11a514b67b0", msgpack.Timestamp(time.Unix(1363896240, 0))},
+		{"epoch time float", "c1fb41d452d9ec200000", msgpack.Timestamp(time.Unix(1363896240, 5e8))},
+		{"date time", "c074323031332d30332d32315432303a30343a30305a", msgpack.Timestamp(time.Unix(1363896240, 0))},
+		{"bignum fits uint64", "c249010000000000000000", msgpack.Ext(msgpack.BigIntExtType, []byte{0, 1, 0, 0, 0, 0, 0, 0, 0, 0})},
+		{"small bignum", "c24101", msgpack.Uint(1)},
+		{"negative bignum", "c349010000000000000000", msgpack.Ext(msgpack.BigIntExtType, []byte{1, 1, 0, 0, 0, 0, 0, 0, 0, 1})},
+		{"negative integer below int64", "3bffffffffffffffff", msgpack.Ext(msgpack.BigIntExtType, []byte{1, 1, 0, 0, 0, 0, 0, 0, 0, 0})},
+		{"small negative bignum", "c34101", msgpack.Int(-2)},
+		{"self-described", "d9d9f7f6", msgpack.Nil()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.cbor)
+			for _, strict := range []bool{false, true} {
+				got, err := DecodeValue(data, Options{Strict: strict})
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodeValueApproximate(t *testing.T) {
+	tests := []struct {
+		name string
+		cbor string
+		want msgpack.Value
+		err  string
+	}{
+		{
+			"indefinite byte string",
+			"5f42010243030405ff",
+			msgpack.Bin([]byte{1, 2, 3, 4, 5}),
+			"item has no counterpart in the other format at offset 0: indefinite-length item",
+		},
+		{
+			"indefinite text string",
+			"7f657374726561646d696e67ff",
+			msgpack.Str("streaming"),
+			"item has no counterpart in the other format at offset 0: indefinite-length item",
+		},
+		{
+			"indefinite array",
+			"9f018202039f0405ffff",
+			msgpack.Array(
+				msgpack.Uint(1),
+				msgpack.Array(msgpack.Uint(2), msgpack.Uint(3)),
+				msgpack.Array(msgpack.Uint(4), msgpack.Uint(5)),
+			),
+			"item has no counterpart in the other format at offset 0: indefinite-length item",
+		},
+		{
+			"indefinite map",
+			"bf6346756ef563416d7421ff",
+			msgpack.Map(msgpack.Pair("Fun", msgpack.Bool(true)), msgpack.Pair("Amt", msgpack.Int(-2))),
+			"item has no counterpart in the other format at offset 0: indefinite-length item",
+		},
+		{
+			"undefined",
+			"f7",
+			msgpack.Nil(),
+			"item has no counterpart in the other format at offset 0: simple value 23",
+		},
+		{
+			"simple value",
+			"81f8ff",
+			msgpack.Array(msgpack.Nil()),
+			"item has no counterpart in the other format at offset 1: simple value 255",
+		},
+		{
+			"epoch time below nanoseconds",
+			"c1fb3ff000000006df38",
+			msgpack.Timestamp(time.Unix(1, 0)),
+			"item has no counterpart in the other format at offset 0: epoch time 1.0000000001 rounded to nanoseconds",
+		},
+		{
+			"unregistered tag",
+			"d82076687474703a2f2f7777772e6578616d706c652e636f6d",
+			msgpack.Str("http://www.example.com"),
+			"item has no counterpart in the other format at offset 0: unregistered tag 32",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.cbor)
+			got, err := DecodeValue(data, Options{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			_, err = DecodeValue(data, Options{Strict: true})
+			assert.ErrorIs(t, err, ErrUnrepresentable)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestDecodeValueFail(t *testing.T) {
+	tests := []struct {
+		name string
+		cbor string
+		err  string
+	}{
+		{"empty", "", "invalid CBOR at offset 0: unexpected end of data"},
+		{"short argument", "19ff", "invalid CBOR at offset 1: unexpected end of data"},
+		{"short string", "43aabb", "invalid CBOR at offset 1: unexpected end of data"},
+		{"long array", "9bffffffffffffffff", "invalid CBOR at offset 9: unexpected end of data"},
+		{"reserved additional information", "1c", "invalid CBOR at offset 0: reserved additional information 28"},
+		{"indefinite integer", "1f", "invalid CBOR at offset 0: unexpected indefinite length or break"},
+		{"break outside container", "ff", "invalid CBOR at offset 0: unexpected indefinite length or break"},
+		{"nested indefinite chunk", "5f5fffff", "invalid CBOR at offset 2: chunk of an indefinite-length string has major type 2"},
+		{"chunk of other major type", "5f6161ff", "invalid CBOR at offset 2: chunk of an indefinite-length string has major type 3"},
+		{"unterminated array", "9f01", "invalid CBOR at offset 2: unexpected end of data"},
+		{"invalid UTF-8", "61ff", "invalid CBOR at offset 2: text string is not valid UTF-8"},
+		{"two-byte simple value below 32", "f818", "invalid CBOR at offset 0: simple value 24 in two bytes"},
+		{"epoch time as string", "c16161", "invalid CBOR at offset 0: tag 1 cannot hold str"},
+		{"epoch time infinity", "c1f97c00", "invalid CBOR at offset 0: tag 1 holds +Inf"},
+		{"bignum as uint", "c201", "invalid CBOR at offset 0: tag 2 cannot hold uint"},
+		{"bad date time", "c06161", `invalid CBOR at offset 0: tag 0: parsing time "a" as "2006-01-02T15:04:05.999999999Z07:00": cannot parse "a" as "2006"`},
+		{"trailing data", "0000", "invalid CBOR: 1 bytes after the item"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.cbor)
+			_, err := DecodeValue(data, Options{})
+			assert.ErrorIs(t, err, ErrInvalidCBOR)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
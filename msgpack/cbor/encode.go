@@ -0,0 +1,150 @@
+package cbor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+
+	"msgpackconv/msgpack"
+)
+
+// EncodeValue encodes v as CBOR. Floats keep their width, and ext values
+// without a timestamp, bignum or registered tag fail with
+// ErrUnrepresentable.
+func EncodeValue(v msgpack.Value, opts Options) ([]byte, error) {
+	return appendValue(nil, v, opts)
+}
+
+// appendHead 以最短的 argument 寫入 initial byte
+func appendHead(ans []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(ans, major|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(ans, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(ans, major|25), uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(ans, major|26), uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64(append(ans, major|27), arg)
+}
+
+func appendInt(ans []byte, i int64) []byte {
+	if i < 0 {
+		return appendHead(ans, majorNegInt, uint64(-1-i))
+	}
+	return appendHead(ans, majorUint, uint64(i))
+}
+
+func appendFloat64(ans []byte, f float64) []byte {
+	return binary.BigEndian.AppendUint64(append(ans, majorSimple<<5|27), math.Float64bits(f))
+}
+
+func appendValue(ans []byte, v msgpack.Value, opts Options) ([]byte, error) {
+	var err error
+	switch v.Kind() {
+	case msgpack.NilKind:
+		ans = append(ans, majorSimple<<5|22)
+	case msgpack.BoolKind:
+		b, _ := v.Bool()
+		if b {
+			ans = append(ans, majorSimple<<5|21)
+		} else {
+			ans = append(ans, majorSimple<<5|20)
+		}
+	case msgpack.IntKind:
+		i, _ := v.Int()
+		ans = appendInt(ans, i)
+	case msgpack.UintKind:
+		u, _ := v.Uint()
+		ans = appendHead(ans, majorUint, u)
+	case msgpack.Float32Kind:
+		f, _ := v.Float()
+		ans = binary.BigEndian.AppendUint32(append(ans, majorSimple<<5|26), math.Float32bits(float32(f)))
+	case msgpack.Float64Kind:
+		f, _ := v.Float()
+		ans = appendFloat64(ans, f)
+	case msgpack.StrKind:
+		s, _ := v.Str()
+		ans = append(appendHead(ans, majorText, uint64(len(s))), s...)
+	case msgpack.BinKind:
+		b, _ := v.Bytes()
+		ans = append(appendHead(ans, majorBytes, uint64(len(b))), b...)
+	case msgpack.ExtKind:
+		return appendExt(ans, v, opts)
+	case msgpack.ArrayKind:
+		ans = appendHead(ans, majorArray, uint64(v.Len()))
+		for _, item := range v.Elements() {
+			if ans, err = appendValue(ans, item, opts); err != nil {
+				return nil, err
+			}
+		}
+	case msgpack.MapKind:
+		ans = appendHead(ans, majorMap, uint64(v.Len()))
+		for key, val := range v.Entries() {
+			if ans, err = appendValue(ans, key, opts); err != nil {
+				return nil, err
+			}
+			if ans, err = appendValue(ans, val, opts); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, msgpack.ErrKindMismatch
+	}
+	return ans, nil
+}
+
+func appendExt(ans []byte, v msgpack.Value, opts Options) ([]byte, error) {
+	typ, _ := v.ExtType()
+	data, _ := v.Bytes()
+	switch typ {
+	case msgpack.TimestampExtType:
+		return appendTimestamp(ans, v, opts)
+	case msgpack.BigIntExtType:
+		if len(data) == 0 || data[0] > 1 {
+			return nil, fmt.Errorf("%w: big.Int ext data % x", msgpack.ErrInvalidMsgPack, data)
+		}
+		n := new(big.Int).SetBytes(data[1:])
+		if data[0] == 0 {
+			ans = appendHead(ans, majorTag, tagPosBignum)
+		} else {
+			// tag 3 的值為 -1 - n，因此寫入大小減 1
+			if n.Sign() == 0 {
+				return nil, fmt.Errorf("%w: big.Int ext data % x", msgpack.ErrInvalidMsgPack, data)
+			}
+			n.Sub(n, big.NewInt(1))
+			ans = appendHead(ans, majorTag, tagNegBignum)
+		}
+		b := n.Bytes()
+		return append(appendHead(ans, majorBytes, uint64(len(b))), b...), nil
+	}
+	tag, ok := tagOf(typ)
+	if !ok {
+		return nil, fmt.Errorf("%w: ext type %d has no registered tag", ErrUnrepresentable, typ)
+	}
+	ans = appendHead(ans, majorTag, tag)
+	return append(appendHead(ans, majorBytes, uint64(len(data))), data...), nil
+}
+
+// appendTimestamp 將 timestamp ext 寫為 tag 1，有 nanoseconds 時秒數為
+// float64
+func appendTimestamp(ans []byte, v msgpack.Value, opts Options) ([]byte, error) {
+	t, ok := v.Time()
+	if !ok {
+		return nil, fmt.Errorf("%w: timestamp ext of %d bytes", msgpack.ErrInvalidMsgPack, v.Len())
+	}
+	ans = appendHead(ans, majorTag, tagEpochTime)
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	if nsec == 0 {
+		return appendInt(ans, sec), nil
+	}
+	f := float64(sec) + float64(nsec)/1e9
+	if s, n := splitSeconds(f); opts.Strict && (s != sec || n != nsec) {
+		return nil, fmt.Errorf("%w: float64 seconds cannot hold %v", ErrUnrepresentable, t)
+	}
+	return appendFloat64(ans, f), nil
+}
@@ -0,0 +1,120 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"math"
+	"msgpackconv/msgpack"
+	. "msgpackconv/msgpack/cbor"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value msgpack.Value
+		want  string
+	}{
+		{"uint", msgpack.Uint(1000000), "1a000f4240"},
+		{"max uint64", msgpack.Uint(math.MaxUint64), "1bffffffffffffffff"},
+		{"int", msgpack.Int(-1000), "3903e7"},
+		{"min int64", msgpack.Int(math.MinInt64), "3b7fffffffffffffff"},
+		{"float32", msgpack.Float32(1.5), "fa3fc00000"},
+		{"float64", msgpack.Float64(1.1), "fb3ff199999999999a"},
+		{"nil", msgpack.Nil(), "f6"},
+		{"bool", msgpack.Array(msgpack.Bool(false), msgpack.Bool(true)), "82f4f5"},
+		{"str", msgpack.Str("IETF"), "6449455446"},
+		{"bin", msgpack.Bin([]byte{1, 2, 3, 4}), "4401020304"},
+		{"map", msgpack.Map(
+			msgpack.Pair("a", msgpack.Uint(1)),
+			msgpack.Entry{Key: msgpack.Uint(2), Value: msgpack.Array(msgpack.Nil())},
+		), "a26161010281f6"},
+		{"timestamp", msgpack.Timestamp(time.Unix(1363896240, 0)), "c11a514b67b0"},
+		{"negative timestamp", msgpack.Timestamp(time.Unix(-1, 0)), "c120"},
+		{"timestamp with nanoseconds", msgpack.Timestamp(time.Unix(1363896240, 5e8)), "c1fb41d452d9ec200000"},
+		{"big int", msgpack.Ext(msgpack.BigIntExtType, []byte{0, 1, 0, 0, 0, 0, 0, 0, 0, 0}), "c249010000000000000000"},
+		{"negative big int", msgpack.Ext(msgpack.BigIntExtType, []byte{1, 1, 0, 0, 0, 0, 0, 0, 0, 1}), "c349010000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncodeValue(tt.value, Options{Strict: true})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, hex.EncodeToString(got))
+
+			back, err := DecodeValue(got, Options{Strict: true})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.value, back)
+		})
+	}
+}
+
+func TestEncodeValueFail(t *testing.T) {
+	tests := []struct {
+		name  string
+		value msgpack.Value
+		opts  Options
+		err   error
+	}{
+		{"unregistered ext", msgpack.Ext(100, []byte{1}), Options{}, ErrUnrepresentable},
+		{"inexact timestamp", msgpack.Timestamp(time.Unix(1363896240, 1)), Options{Strict: true}, ErrUnrepresentable},
+		{"bad timestamp", msgpack.Ext(msgpack.TimestampExtType, []byte{1}), Options{}, msgpack.ErrInvalidMsgPack},
+		{"bad big int", msgpack.Ext(msgpack.BigIntExtType, []byte{2}), Options{}, msgpack.ErrInvalidMsgPack},
+		{"invalid value", msgpack.Array(msgpack.Value{}), Options{}, msgpack.ErrKindMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := EncodeValue(tt.value, tt.opts)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	// 不要求 strict 時以最接近的 float64 秒數寫入
+	got, err := EncodeValue(msgpack.Timestamp(time.Unix(1363896240, 1)), Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "c1fb41d452d9ec000000", hex.EncodeToString(got))
+}
+
+func TestRegisterTag(t *testing.T) {
+	assert.NoError(t, RegisterTag(42, 40000))
+	t.Cleanup(func() { UnregisterTag(42) })
+	assert.ErrorIs(t, RegisterTag(42, 40001), ErrTagRegistered)
+	assert.ErrorIs(t, RegisterTag(43, 40000), ErrTagRegistered)
+	assert.ErrorIs(t, RegisterTag(43, 1), ErrTagRegistered)
+	assert.ErrorIs(t, RegisterTag(msgpack.TimestampExtType, 40002), ErrTagRegistered)
+	assert.ErrorIs(t, RegisterTag(msgpack.BigIntExtType, 40002), ErrTagRegistered)
+	assert.ErrorIs(t, RegisterTag(msgpack.BigFloatExtType, 40002), ErrTagRegistered)
+
+	ext := msgpack.Ext(42, []byte{0xca, 0xfe})
+	got, err := EncodeValue(ext, Options{Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "d99c4042cafe", hex.EncodeToString(got))
+	back, err := DecodeValue(got, Options{Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, ext, back)
+
+	_, err = DecodeValue([]byte{0xd9, 0x9c, 0x40, 0x01}, Options{})
+	assert.ErrorIs(t, err, ErrInvalidCBOR)
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	// msgpack: {"t": timestamp 32, "b": bin 8, "f": float32}
+	msgpackconv := []byte{
+		0x83,
+		0xa1, 0x74, 0xd6, 0xff, 0x51, 0x4b, 0x67, 0xb0,
+		0xa1, 0x62, 0xc4, 0x02, 0x01, 0x02,
+		0xa1, 0x66, 0xca, 0x3f, 0xc0, 0x00, 0x00,
+	}
+	cbor, err := FromMsgpack(msgpackconv)
+	assert.NoError(t, err)
+	assert.Equal(t, "a36174c11a514b67b061624201026166fa3fc00000", hex.EncodeToString(cbor))
+	back, err := ToMsgpackWithOptions(cbor, Options{Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, msgpackconv, back)
+
+	_, err = FromMsgpack([]byte{0xc1})
+	assert.ErrorIs(t, err, msgpack.ErrInvalidMsgPack)
+	_, err = ToMsgpack([]byte{0x1c})
+	assert.ErrorIs(t, err, ErrInvalidCBOR)
+}
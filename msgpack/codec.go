@@ -0,0 +1,66 @@
+package msgpack
+
+import (
+	"reflect"
+	"sync"
+)
+
+// codec 為一個類型編譯好的編碼與解碼函式，Marshal 與 Unmarshal 只在第一次
+// 遇到該類型時檢查 struct tag 與實作的 interface
+type codec struct {
+	encode encoderFunc
+	decode decoderFunc
+}
+
+var codecCache sync.Map // map[reflect.Type]*codec
+
+func cachedCodec(t reflect.Type) *codec {
+	if c, ok := codecCache.Load(t); ok {
+		return c.(*codec)
+	}
+
+	// 先存入等待編譯完成的 codec，遞迴的類型會取得它而不會無限遞迴
+	var wg sync.WaitGroup
+	wg.Add(1)
+	c := &codec{}
+	pending := &codec{
+		encode: func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			wg.Wait()
+			return c.encode(e, ans, rv)
+		},
+		decode: func(d *decodeState, msgpackconv []byte, rv reflect.Value) (int, error) {
+			wg.Wait()
+			return c.decode(d, msgpackconv, rv)
+		},
+	}
+	if actual, loaded := codecCache.LoadOrStore(t, pending); loaded {
+		return actual.(*codec)
+	}
+	c.encode, c.decode = newEncoder(t), newDecoder(t)
+	wg.Done()
+	codecCache.Store(t, c)
+	return c
+}
+
+// DecodeAs decodes data, which must hold exactly one value, into a new T
+// with the rules of Unmarshal.
+func DecodeAs[T any](data []byte) (T, error) {
+	var v T
+	var d decodeState
+	n, err := cachedCodec(reflect.TypeFor[T]()).decode(&d, data, reflect.ValueOf(&v).Elem())
+	if err != nil {
+		return v, err
+	}
+	if n != len(data) {
+		return v, ErrInvalidMsgPack
+	}
+	return v, nil
+}
+
+// EncodeFrom returns the message pack encoding of v with the rules of
+// Marshal. Because v is addressable here, methods with pointer receivers
+// are used as if Marshal were given &v.
+func EncodeFrom[T any](v T) ([]byte, error) {
+	var e encodeState
+	return cachedCodec(reflect.TypeFor[T]()).encode(&e, nil, reflect.ValueOf(&v).Elem())
+}
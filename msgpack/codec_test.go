@@ -0,0 +1,81 @@
+package msgpack_test
+
+import (
+	. "msgpackconv/msgpack"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tree struct {
+	Value    int     `msgpack:"value"`
+	Children []*tree `msgpack:"children,omitempty"`
+}
+
+// celsius 只以指標實作 Marshaler
+type celsius int
+
+func (c *celsius) MarshalMsgpack() ([]byte, error) {
+	return []byte{0xa1, 0x43}, nil
+}
+
+func TestDecodeAs(t *testing.T) {
+	p, err := DecodeAs[point]([]byte{0x82, 0xa1, 0x78, 0x01, 0xa5, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0xa1, 0x70})
+	assert.NoError(t, err)
+	assert.Equal(t, point{X: 1, Label: "p"}, p)
+
+	s, err := DecodeAs[[]uint8]([]byte{0x92, 0x01, 0x02})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint8{1, 2}, s)
+
+	m, err := DecodeAs[map[string][]int]([]byte{0x81, 0xa1, 0x61, 0x91, 0xff})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]int{"a": {-1}}, m)
+
+	i, err := DecodeAs[interface{}]([]byte{0xa1, 0x61})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", i)
+
+	_, err = DecodeAs[int8]([]byte{0xcc, 0xc8})
+	assert.ErrorIs(t, err, ErrUnmarshalType)
+	_, err = DecodeAs[int]([]byte{0x01, 0x02})
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+}
+
+func TestEncodeFrom(t *testing.T) {
+	got, err := EncodeFrom(map[string]int{"b": 2, "a": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x82, 0xa1, 0x61, 0x01, 0xa1, 0x62, 0x02}, got)
+
+	got, err = EncodeFrom[interface{}](nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xc0}, got)
+
+	// EncodeFrom 的值是 addressable，會使用指標的 MarshalMsgpack
+	got, err = EncodeFrom(celsius(1))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xa1, 0x43}, got)
+	got, err = Marshal(celsius(1))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01}, got)
+}
+
+func TestCodecRecursiveType(t *testing.T) {
+	v := tree{Value: 1, Children: []*tree{{Value: 2}, {Value: 3, Children: []*tree{{Value: 4}}}}}
+
+	// 同時第一次使用同一個類型
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := EncodeFrom(v)
+			assert.NoError(t, err)
+			got, err := DecodeAs[tree](b)
+			assert.NoError(t, err)
+			assert.Equal(t, v, got)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,229 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVOptions controls ToCSV and FromCSV.
+type CSVOptions struct {
+	// Comma is the field delimiter; '\t' gives TSV. Zero means ','.
+	Comma rune
+	// InferTypes makes FromCSV write fields that look like ints, floats
+	// or booleans (true or false in any case) as those kinds, and empty
+	// fields as nil. Numbers with leading zeros, such as zip codes, stay
+	// str.
+	InferTypes bool
+}
+
+func (opts CSVOptions) comma() rune {
+	if opts.Comma == 0 {
+		return ','
+	}
+	return opts.Comma
+}
+
+// ToCSV converts an array of maps, or a sequence of maps, to CSV. The
+// header row is the union of the keys in the order they first appear, and
+// a row without a key leaves its field empty. Keys must be str or int;
+// nil becomes an empty field, bin is base64 encoded, timestamp exts are
+// written in RFC 3339 and nested arrays and maps as JSON.
+func ToCSV(msgpackconv []byte, opts CSVOptions) ([]byte, error) {
+	rows, err := csvRows(msgpackconv)
+	if err != nil {
+		return nil, err
+	}
+	// header 為所有 key 依第一次出現的順序
+	columns := map[string]int{}
+	header := []string{}
+	records := make([][]string, 0, len(rows))
+	for i, row := range rows {
+		if row.kind != MapKind {
+			return nil, fmt.Errorf("%w: row %d is a %s, not a map", ErrNotCSVCompatible, i, row.kind)
+		}
+		record := make([]string, len(header))
+		for _, e := range row.entries {
+			key, err := csvKey(e.Key)
+			if err != nil {
+				return nil, err
+			}
+			col, ok := columns[key]
+			if !ok {
+				col = len(header)
+				columns[key] = col
+				header = append(header, key)
+			}
+			for len(record) <= col {
+				record = append(record, "")
+			}
+			if record[col], err = csvField(e.Value); err != nil {
+				return nil, err
+			}
+		}
+		records = append(records, record)
+	}
+	if len(header) == 0 {
+		return []byte{}, nil
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = opts.comma()
+	w.Write(header)
+	for _, record := range records {
+		// 前面的 row 沒有後來才出現的 key，補上空欄位
+		for len(record) < len(header) {
+			record = append(record, "")
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvRows 回傳唯一一個 array 的元素，或是連續多個值本身
+func csvRows(msgpackconv []byte) ([]Value, error) {
+	rows := []Value{}
+	for idx := 0; idx < len(msgpackconv); {
+		v, n, err := decodeValue(msgpackconv[idx:])
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, v)
+		idx += n
+	}
+	if len(rows) == 1 && rows[0].kind == ArrayKind {
+		return rows[0].items, nil
+	}
+	return rows, nil
+}
+
+func csvKey(key Value) (string, error) {
+	switch key.kind {
+	case StrKind:
+		return key.str, nil
+	case IntKind:
+		return strconv.FormatInt(int64(key.num), 10), nil
+	case UintKind:
+		return strconv.FormatUint(key.num, 10), nil
+	}
+	return "", fmt.Errorf("%w: map key of kind %s", ErrNotCSVCompatible, key.kind)
+}
+
+func csvField(v Value) (string, error) {
+	if v.kind == ArrayKind || v.kind == MapKind {
+		b, err := v.MarshalJSON()
+		return string(b), err
+	}
+	s, ok := scalarText(v)
+	if !ok {
+		return "", fmt.Errorf("%w: ext type %d", ErrNotCSVCompatible, v.extType)
+	}
+	return s, nil
+}
+
+// scalarText 回傳 ToCSV 與 ToXML 寫入的文字，array、map 與 timestamp 以外
+// 的 ext 沒有文字形式
+func scalarText(v Value) (string, bool) {
+	switch v.kind {
+	case NilKind:
+		return "", true
+	case BoolKind:
+		return strconv.FormatBool(v.num == 1), true
+	case IntKind:
+		return strconv.FormatInt(int64(v.num), 10), true
+	case UintKind:
+		return strconv.FormatUint(v.num, 10), true
+	case Float32Kind, Float64Kind:
+		f, _ := v.Float()
+		bits := 64
+		if v.kind == Float32Kind {
+			bits = 32
+		}
+		s := strconv.FormatFloat(f, 'g', -1, bits)
+		// 整數值加上 .0，推斷類型時才會讀回為 float
+		if strings.Trim(s, "-0123456789") == "" {
+			s += ".0"
+		}
+		return s, true
+	case StrKind:
+		return v.str, true
+	case BinKind:
+		return base64.StdEncoding.EncodeToString(v.bytes), true
+	case ExtKind:
+		if t, ok := v.Time(); ok {
+			return t.Format(time.RFC3339Nano), true
+		}
+	}
+	return "", false
+}
+
+// FromCSV converts CSV with a header row to an array of maps, one per
+// row, whose keys are the header fields in order.
+func FromCSV(data []byte, opts CSVOptions) ([]byte, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = opts.comma()
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+	}
+	if len(records) == 0 {
+		return getArrayFormat(0), nil
+	}
+	header, records := records[0], records[1:]
+	ans := getArrayFormat(len(records))
+	for _, record := range records {
+		ans = append(ans, getMapFormat(len(header))...)
+		for i, field := range record {
+			ans = append(ans, getStrFormat(header[i])...)
+			if opts.InferTypes {
+				ans = appendInferred(ans, field)
+			} else {
+				ans = append(ans, getStrFormat(field)...)
+			}
+		}
+	}
+	return ans, nil
+}
+
+// appendInferred 寫入 field 推斷出的類型，無法推斷時為 str
+func appendInferred(ans []byte, field string) []byte {
+	switch {
+	case field == "":
+		return append(ans, FirstByte["nil"])
+	case strings.EqualFold(field, "true"), strings.EqualFold(field, "false"):
+		return append(ans, getBoolFormat(strings.EqualFold(field, "true")))
+	case !isCSVNumber(field):
+		return append(ans, getStrFormat(field)...)
+	}
+	if i, err := strconv.ParseInt(field, 10, 64); err == nil {
+		if i < 0 {
+			return append(ans, getNegativeIntFormat(i)...)
+		}
+		return append(ans, getPositiveIntFormat(uint64(i))...)
+	}
+	if u, err := strconv.ParseUint(field, 10, 64); err == nil {
+		return append(ans, getPositiveIntFormat(u)...)
+	}
+	if f, err := strconv.ParseFloat(field, 64); err == nil {
+		return append(ans, getFloatFormat(f)...)
+	}
+	return append(ans, getStrFormat(field)...)
+}
+
+// isCSVNumber 回傳 s 是否為十進位數字且整數部分沒有多餘的前導 0，
+// 排除 strconv 也接受的 Inf, NaN 與 0x 等寫法
+func isCSVNumber(s string) bool {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	if digits == "" || strings.Trim(digits, "0123456789.eE+-") != "" {
+		return false
+	}
+	if digits[0] < '0' || digits[0] > '9' {
+		return false
+	}
+	return !(len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9')
+}
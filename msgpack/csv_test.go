@@ -0,0 +1,111 @@
+package msgpack_test
+
+import (
+	. "msgpackconv/msgpack"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToCSV(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		opts   CSVOptions
+		want   string
+	}{
+		{"array of maps", []string{`[{"a": 1, "b": "x"}, {"b": "y,z", "c": true}, {}]`}, CSVOptions{},
+			"a,b,c\n1,x,\n,\"y,z\",true\n,,\n"},
+		{"sequence of maps", []string{`{"id": -1, "v": 1.0}`, `{"id": 2, "v": 2.5f32}`}, CSVOptions{},
+			"id,v\n-1,1.0\n2,2.5\n"},
+		{"tsv", []string{`[{"a": "x y", "b": nil}]`}, CSVOptions{Comma: '\t'},
+			"a\tb\nx y\t\n"},
+		{"int keys", []string{`[{1: "one"}]`}, CSVOptions{}, "1\none\n"},
+		{"bin and timestamp", []string{`[{"b": h'ff', "t": ext(-1, h'00000001')}]`}, CSVOptions{},
+			"b,t\n/w==,1970-01-01T00:00:01Z\n"},
+		{"nested values", []string{`[{"tags": ["a", 1], "meta": {"k": nil}}]`}, CSVOptions{},
+			"tags,meta\n\"[\"\"a\"\",1]\",\"{\"\"k\"\":null}\"\n"},
+		{"empty array", []string{`[]`}, CSVOptions{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgpackconv := []byte{}
+			for _, text := range tt.values {
+				b, err := FromDiagnostic(text)
+				assert.NoError(t, err)
+				msgpackconv = append(msgpackconv, b...)
+			}
+			got, err := ToCSV(msgpackconv, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestToCSVFail(t *testing.T) {
+	tests := []struct {
+		name string
+		diag string
+	}{
+		{"row is not a map", `[{"a": 1}, 2]`},
+		{"scalar", `1`},
+		{"array key", `[{["a"]: 1}]`},
+		{"ext", `[{"a": ext(5, h'01')}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgpackconv, err := FromDiagnostic(tt.diag)
+			assert.NoError(t, err)
+			_, err = ToCSV(msgpackconv, CSVOptions{})
+			assert.ErrorIs(t, err, ErrNotCSVCompatible)
+		})
+	}
+
+	_, err := ToCSV([]byte{0x91, 0xc1}, CSVOptions{})
+	assert.Error(t, err)
+}
+
+func TestFromCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		opts CSVOptions
+		want string
+	}{
+		{"strings", "a,b\n1,x\n,\"y,z\"\n", CSVOptions{},
+			`[{"a": "1", "b": "x"}, {"a": "", "b": "y,z"}]`},
+		{"tsv", "a\tb\n1\tx\n", CSVOptions{Comma: '\t'},
+			`[{"a": "1", "b": "x"}]`},
+		{"header only", "a,b\n", CSVOptions{}, `[]`},
+		{"empty", "", CSVOptions{}, `[]`},
+		{"inferred", "i,n,u,f,e,b,z,s,empty\n1,-2,18446744073709551615,1.5,1e3,TRUE,007,1-2,\n", CSVOptions{InferTypes: true},
+			`[{"i": 1, "n": -2, "u": 18446744073709551615, "f": 1.5, "e": 1000.0, "b": true, "z": "007", "s": "1-2", "empty": nil}]`},
+		{"not inferred", "x\ninf\nNaN\n0x10\n1_000\n.5\n", CSVOptions{InferTypes: true},
+			`[{"x": "inf"}, {"x": "NaN"}, {"x": "0x10"}, {"x": "1_000"}, {"x": ".5"}]`},
+		{"int beyond 64 bits", "x\n18446744073709551616\n", CSVOptions{InferTypes: true},
+			`[{"x": 18446744073709552000.0}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromCSV([]byte(tt.csv), tt.opts)
+			assert.NoError(t, err)
+			diag, err := ToDiagnostic(got)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, diag)
+		})
+	}
+
+	_, err := FromCSV([]byte("a,b\n1\n"), CSVOptions{})
+	assert.ErrorIs(t, err, ErrInvalidCSV)
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	msgpackconv, err := FromDiagnostic(`[{"id": 1, "name": "a", "score": 2.0, "ok": false, "note": nil}]`)
+	assert.NoError(t, err)
+	opts := CSVOptions{Comma: '\t', InferTypes: true}
+	tsv, err := ToCSV(msgpackconv, opts)
+	assert.NoError(t, err)
+	back, err := FromCSV(tsv, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, msgpackconv, back)
+}
@@ -6,6 +6,7 @@ import (
 	"math"
 	"reflect"
 	"slices"
+	"strings"
 )
 
 var LastByte = map[string]byte{
@@ -16,22 +17,39 @@ var LastByte = map[string]byte{
 	"fixmap":         0x8f,
 }
 
+// formatName 為 FirstByte 的反查表
+var formatName = func() map[byte]string {
+	m := make(map[byte]string, len(FirstByte))
+	for format, b := range FirstByte {
+		m[b] = format
+	}
+	return m
+}()
+
 func ToJSON(msgpackconv []byte) []byte {
-	if len(msgpackconv) == 0 {
+	ans, err := toJSON(msgpackconv)
+	if err != nil {
+		// 包含 json.Marshal 無法處理的 NaN 與 Inf
 		return []byte{}
 	}
-	obj, _, err := decode(msgpackconv)
-	if err == ErrInvalidMsgPack {
-		return []byte{}
+	return ans
+}
+
+func toJSON(msgpackconv []byte) ([]byte, error) {
+	if len(msgpackconv) == 0 {
+		return []byte{}, ErrInvalidMsgPack
 	}
-	ans, err := json.Marshal(obj)
-	if err == ErrInvalidMsgPack {
-		return []byte{}
+	obj, _, err := decode(msgpackconv)
+	if err != nil {
+		return nil, err
 	}
-	return ans
+	return json.Marshal(obj)
 }
 
 func decode(msgpackconv []byte) (interface{}, int, error) {
+	if len(msgpackconv) == 0 {
+		return nil, 0, ErrInvalidMsgPack
+	}
 	var obj interface{}
 	v := reflect.ValueOf(&obj).Elem()
 	// 已讀取的 byte index
@@ -170,48 +188,60 @@ func decode(msgpackconv []byte) (interface{}, int, error) {
 	} else if msgpackconv[0] >= FirstByte["fixarray"] && msgpackconv[0] <= LastByte["fixarray"] {
 		// fixarray
 		l := getLength([]byte{msgpackconv[0] ^ FirstByte["fixarray"]})
-		s := make([]interface{}, l)
-		var err error
-		for j := range l {
-			s[j], _, err = decode(msgpackconv[1+j : 2+j])
+		s := make([]interface{}, 0, min(l, len(msgpackconv)))
+		j := 1
+		for range l {
+			elem, tmp, err := decode(msgpackconv[j:])
 			if err != nil {
 				return nil, 0, ErrInvalidMsgPack
 			}
+			j += tmp
+			s = append(s, elem)
 		}
 		v.Set(reflect.ValueOf(s))
-		idxOfEnd = l + 1
+		idxOfEnd = j
 	} else if msgpackconv[0] == FirstByte["array16"] {
 		// array16
+		if len(msgpackconv) < 3 {
+			return nil, 0, ErrInvalidMsgPack
+		}
 		l := getLength(msgpackconv[1:3])
-		s := make([]interface{}, l)
-		var err error
-		for j := range l {
-			s[j], _, err = decode(msgpackconv[3+j : 4+j])
+		s := make([]interface{}, 0, min(l, len(msgpackconv)))
+		j := 3
+		for range l {
+			elem, tmp, err := decode(msgpackconv[j:])
 			if err != nil {
 				return nil, 0, ErrInvalidMsgPack
 			}
+			j += tmp
+			s = append(s, elem)
 		}
 		v.Set(reflect.ValueOf(s))
-		idxOfEnd = l + 3
+		idxOfEnd = j
 	} else if msgpackconv[0] == FirstByte["array32"] {
 		// array32
+		if len(msgpackconv) < 5 {
+			return nil, 0, ErrInvalidMsgPack
+		}
 		l := getLength(msgpackconv[1:5])
-		s := make([]interface{}, l)
-		var err error
-		for j := range l {
-			s[j], _, err = decode(msgpackconv[5+j : 6+j])
+		s := make([]interface{}, 0, min(l, len(msgpackconv)))
+		j := 5
+		for range l {
+			elem, tmp, err := decode(msgpackconv[j:])
 			if err != nil {
 				return nil, 0, ErrInvalidMsgPack
 			}
+			j += tmp
+			s = append(s, elem)
 		}
 		v.Set(reflect.ValueOf(s))
-		idxOfEnd = l + 5
+		idxOfEnd = j
 	} else if msgpackconv[0] >= FirstByte["fixmap"] && msgpackconv[0] <= LastByte["fixmap"] {
 		// fixmap
 		m := make(map[string]interface{})
 		l := getLength([]byte{msgpackconv[0] ^ FirstByte["fixmap"]})
 		j := 0
-		for len(m) < l {
+		for range l {
 			key, tmp, err := decode(msgpackconv[j+1:])
 			if err != nil {
 				return nil, 0, ErrInvalidMsgPack
@@ -222,16 +252,23 @@ func decode(msgpackconv []byte) (interface{}, int, error) {
 				return nil, 0, ErrInvalidMsgPack
 			}
 			j += tmp
-			m[key.(string)] = value
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, ErrInvalidMsgPack
+			}
+			m[k] = value
 		}
 		v.Set(reflect.ValueOf(m))
-		idxOfEnd = j
+		idxOfEnd = j + 1
 	} else if msgpackconv[0] == FirstByte["map16"] {
 		// map16
 		m := make(map[string]interface{})
+		if len(msgpackconv) < 3 {
+			return nil, 0, ErrInvalidMsgPack
+		}
 		l := getLength(msgpackconv[1:3])
 		j := 2
-		for len(m) < l {
+		for range l {
 			key, tmp, err := decode(msgpackconv[j+1:])
 			if err != nil {
 				return nil, 0, ErrInvalidMsgPack
@@ -242,16 +279,23 @@ func decode(msgpackconv []byte) (interface{}, int, error) {
 				return nil, 0, ErrInvalidMsgPack
 			}
 			j += tmp
-			m[key.(string)] = value
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, ErrInvalidMsgPack
+			}
+			m[k] = value
 		}
 		v.Set(reflect.ValueOf(m))
-		idxOfEnd = j
+		idxOfEnd = j + 1
 	} else if msgpackconv[0] == FirstByte["map32"] {
 		// map32
 		m := make(map[string]interface{})
+		if len(msgpackconv) < 5 {
+			return nil, 0, ErrInvalidMsgPack
+		}
 		l := getLength(msgpackconv[1:5])
 		j := 4
-		for len(m) < l {
+		for range l {
 			key, tmp, err := decode(msgpackconv[j+1:])
 			if err != nil {
 				return nil, 0, ErrInvalidMsgPack
@@ -262,20 +306,142 @@ func decode(msgpackconv []byte) (interface{}, int, error) {
 				return nil, 0, ErrInvalidMsgPack
 			}
 			j += tmp
-			m[key.(string)] = value
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, ErrInvalidMsgPack
+			}
+			m[k] = value
 		}
 		v.Set(reflect.ValueOf(m))
-		idxOfEnd = j
+		idxOfEnd = j + 1
+	} else if val, n, err := decodeValue(msgpackconv); err == nil && val.kind == ExtKind {
+		// 只有大數的 ext 能轉為 JSON
+		text, err := bigNumberText(val.extType, val.bytes)
+		if err != nil {
+			return nil, 0, ErrInvalidMsgPack
+		}
+		v.Set(reflect.ValueOf(json.Number(text)))
+		idxOfEnd = n
+	} else {
+		// bin 與 0xc1 無法轉為 JSON
+		return nil, 0, ErrInvalidMsgPack
 	}
 	return obj, idxOfEnd, nil
 }
 
+// header 描述一個 msgpack 值開頭的格式資訊
+type header struct {
+	// FirstByte 裡的格式名稱，例如 "str8"
+	format string
+	// header 本身佔用的 byte 數
+	size int
+	// str, bin, ext 與數字為資料的 byte 數；array 為元素數；map 為 key-value pair 數
+	length int
+	// ext 的 type
+	extType int8
+}
+
+func readHeader(msgpackconv []byte) (header, error) {
+	if len(msgpackconv) == 0 {
+		return header{}, ErrInvalidMsgPack
+	}
+	b := msgpackconv[0]
+	switch {
+	case b <= LastByte["positiveFixint"]:
+		return header{format: "positiveFixint", size: 1}, nil
+	case b >= FirstByte["fixmap"] && b <= LastByte["fixmap"]:
+		return header{format: "fixmap", size: 1, length: int(b ^ FirstByte["fixmap"])}, nil
+	case b >= FirstByte["fixarray"] && b <= LastByte["fixarray"]:
+		return header{format: "fixarray", size: 1, length: int(b ^ FirstByte["fixarray"])}, nil
+	case b >= FirstByte["fixstr"] && b <= LastByte["fixstr"]:
+		return header{format: "fixstr", size: 1, length: int(b ^ FirstByte["fixstr"])}, nil
+	case b >= FirstByte["negativeFixint"]:
+		return header{format: "negativeFixint", size: 1}, nil
+	}
+
+	h := header{format: formatName[b], size: 1}
+	// 長度欄位佔用的 byte 數
+	lengthSize := 0
+	switch h.format {
+	case "nil", "false", "true":
+	case "uint8", "int8":
+		h.length = 1
+	case "uint16", "int16":
+		h.length = 2
+	case "uint32", "int32", "float32":
+		h.length = 4
+	case "uint64", "int64", "float64":
+		h.length = 8
+	case "str8", "bin8", "ext8":
+		lengthSize = 1
+	case "str16", "bin16", "ext16", "array16", "map16":
+		lengthSize = 2
+	case "str32", "bin32", "ext32", "array32", "map32":
+		lengthSize = 4
+	case "fixext1", "fixext2", "fixext4", "fixext8", "fixext16":
+		for l, format := range fixextFormat {
+			if format == h.format {
+				h.length = l
+			}
+		}
+	default:
+		// 0xc1 沒有被使用
+		return header{}, ErrInvalidMsgPack
+	}
+	h.size += lengthSize
+	isExt := strings.Contains(h.format, "ext")
+	if isExt {
+		// ext 的 type 欄位
+		h.size++
+	}
+	if len(msgpackconv) < h.size {
+		return header{}, ErrInvalidMsgPack
+	}
+	if lengthSize > 0 {
+		h.length = getLength(msgpackconv[1 : 1+lengthSize])
+	}
+	if isExt {
+		h.extType = int8(msgpackconv[h.size-1])
+	}
+	return h, nil
+}
+
+// skip 回傳第一個 msgpack 值佔用的 byte 數
+func skip(msgpackconv []byte) (int, error) {
+	idxOfEnd := 0
+	// 尚未讀取的值的數量
+	remaining := 1
+	for remaining > 0 {
+		h, err := readHeader(msgpackconv[idxOfEnd:])
+		if err != nil {
+			return 0, err
+		}
+		remaining--
+		idxOfEnd += h.size
+		switch h.format {
+		case "fixarray", "array16", "array32":
+			remaining += h.length
+		case "fixmap", "map16", "map32":
+			remaining += 2 * h.length
+		default:
+			idxOfEnd += h.length
+		}
+		if idxOfEnd > len(msgpackconv) {
+			return 0, ErrInvalidMsgPack
+		}
+	}
+	return idxOfEnd, nil
+}
+
 func getLength(bytes []byte) int {
 	return int(bytesToUint64(bytes, true))
 }
 
 func bytesToFloat64(bytes []byte, positive bool) float64 {
-	return float64(int64(bytesToUint64(bytes, positive)))
+	if positive {
+		return float64(bytesToUint64(bytes, true))
+	}
+	return float64(bytesToInt64(bytes))
 }
 
 func bytesToUint64(bytes []byte, positive bool) uint64 {
@@ -289,6 +455,10 @@ func bytesToUint64(bytes []byte, positive bool) uint64 {
 	return binary.BigEndian.Uint64(copyBytes)
 }
 
+func bytesToInt64(bytes []byte) int64 {
+	return int64(bytesToUint64(bytes, bytes[0] < 0x80))
+}
+
 func bitsToFloat64(bytes []byte) float64 {
 	return math.Float64frombits(binary.BigEndian.Uint64(bytes))
 }
@@ -216,3 +216,47 @@ func TestToJSONFail(t *testing.T) {
 		})
 	}
 }
+
+func TestToJSONNested(t *testing.T) {
+	type args struct {
+		msgpackconv []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{
+			"array of str",
+			args{[]byte{0x92, 0xa1, 0x61, 0xa2, 0x62, 0x63}},
+			[]byte(`["a","bc"]`),
+		},
+		{
+			"map in array",
+			args{[]byte{0x92, 0x81, 0xa1, 0x61, 0x01, 0xc3}},
+			[]byte(`[{"a":1},true]`),
+		},
+		{
+			"map with duplicate keys in array",
+			args{[]byte{0x92, 0x82, 0xa1, 0x61, 0x01, 0xa1, 0x61, 0x02, 0xc3}},
+			[]byte(`[{"a":2},true]`),
+		},
+		{
+			"positive int8",
+			args{[]byte{0x91, 0xd0, 0x05}},
+			[]byte(`[5]`),
+		},
+		{
+			"truncated array",
+			args{[]byte{0x92, 0x01}},
+			[]byte{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToJSON(tt.args.msgpackconv); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ToJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
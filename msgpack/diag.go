@@ -0,0 +1,523 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// diagSuffix 為指定 format 時，寫在值後面的後綴
+var diagSuffix = map[string]string{
+	"uint8":   "u8",
+	"uint16":  "u16",
+	"uint32":  "u32",
+	"uint64":  "u64",
+	"int8":    "i8",
+	"int16":   "i16",
+	"int32":   "i32",
+	"int64":   "i64",
+	"float32": "f32",
+	"float64": "f64",
+	"str8":    "s8",
+	"str16":   "s16",
+	"str32":   "s32",
+	"bin8":    "b8",
+	"bin16":   "b16",
+	"bin32":   "b32",
+	"array16": "a16",
+	"array32": "a32",
+	"map16":   "m16",
+	"map32":   "m32",
+}
+
+// diagFormat 為 diagSuffix 的反查表
+var diagFormat = func() map[string]string {
+	m := make(map[string]string, len(diagSuffix))
+	for format, suffix := range diagSuffix {
+		m[suffix] = format
+	}
+	return m
+}()
+
+// ToDiagnostic prints a single message pack value in diagnostic notation, a
+// text form for writing exact encodings, such as test fixtures, that
+// FromDiagnostic parses back to the same bytes.
+//
+// The notation looks like JSON with a few additions:
+//
+//	nil, true, false
+//	1, -1, 1u8, -1i16           ints; a suffix gives the format
+//	1.5, 1.5f32, Infinity       float64 and float32
+//	f64(0x7ff8000000000001)     a float from its bits, used for NaN
+//	"a", "\xff", "a"s16         str as a Go string literal
+//	h'00ff', h''b16             bin in hex
+//	ext(5, h'01'), ext8(5, h'') ext with its type
+//	[1, 2], []a16               arrays
+//	{"a": 1, 2: nil}, {}m16     maps with keys of any type
+//
+// Without a suffix a value uses the format that Append functions such as
+// AppendInt and AppendString choose, and a number with a decimal point or
+// exponent is a float64. The suffixes are u8 to u64 and i8 to i64 for
+// ints, f32 and f64 for floats, s8 to s32 for str, b8 to b32 for bin, a16
+// and a32 for arrays and m16 and m32 for maps; ext8 to ext32 and fixext1
+// to fixext16 select the format of an ext.
+func ToDiagnostic(msgpackconv []byte) (string, error) {
+	ans, n, err := appendDiagnostic(nil, msgpackconv)
+	if err != nil {
+		return "", err
+	}
+	if n != len(msgpackconv) {
+		return "", ErrInvalidMsgPack
+	}
+	return string(ans), nil
+}
+
+// appendDiagnostic 將 msgpackconv 的第一個值以 diagnostic notation 寫出，
+// 回傳讀取的 byte 數
+func appendDiagnostic(ans, msgpackconv []byte) ([]byte, int, error) {
+	h, err := readHeader(msgpackconv)
+	if err != nil {
+		return nil, 0, err
+	}
+	family := formatFamily(h.format)
+	minimal := h.format == minimalFormat(family, h.length)
+	switch family {
+	case "array", "map":
+		open, sep, end := "[", "", "]"
+		count := h.length
+		if family == "map" {
+			open, sep, end = "{", ": ", "}"
+			count *= 2
+		}
+		ans = append(ans, open...)
+		idxOfEnd := h.size
+		for i := range count {
+			switch {
+			case i == 0:
+			case i%2 == 1 && family == "map":
+				ans = append(ans, sep...)
+			default:
+				ans = append(ans, ", "...)
+			}
+			var n int
+			if ans, n, err = appendDiagnostic(ans, msgpackconv[idxOfEnd:]); err != nil {
+				return nil, 0, err
+			}
+			idxOfEnd += n
+		}
+		ans = append(ans, end...)
+		if !minimal {
+			ans = append(ans, diagSuffix[h.format]...)
+		}
+		return ans, idxOfEnd, nil
+	}
+
+	n := h.size + h.length
+	if len(msgpackconv) < n {
+		return nil, 0, ErrInvalidMsgPack
+	}
+	data := msgpackconv[h.size:n]
+	switch family {
+	case "nil", "true", "false":
+		ans = append(ans, h.format...)
+	case "positiveFixint", "negativeFixint", "uint", "int":
+		text, canonical, err := intLiteral(msgpackconv[:n])
+		if err != nil {
+			return nil, 0, err
+		}
+		ans = append(ans, text...)
+		if !canonical {
+			ans = append(ans, diagSuffix[h.format]...)
+		}
+	case "float":
+		ans = appendDiagnosticFloat(ans, data)
+	case "str":
+		ans = strconv.AppendQuote(ans, string(data))
+		if !minimal {
+			ans = append(ans, diagSuffix[h.format]...)
+		}
+	case "bin":
+		ans = appendHexLiteral(ans, data)
+		if !minimal {
+			ans = append(ans, diagSuffix[h.format]...)
+		}
+	case "ext":
+		name := h.format
+		if minimal {
+			name = "ext"
+		}
+		ans = fmt.Appendf(ans, "%s(%d, ", name, h.extType)
+		ans = append(appendHexLiteral(ans, data), ')')
+	default:
+		return nil, 0, ErrInvalidMsgPack
+	}
+	return ans, n, nil
+}
+
+// appendDiagnosticFloat 寫出 float32 或 float64，NaN 以 bits 寫出以保留 payload
+func appendDiagnosticFloat(ans, data []byte) []byte {
+	if len(data) == 4 {
+		bits := binary.BigEndian.Uint32(data)
+		f := math.Float32frombits(bits)
+		switch {
+		case math.IsNaN(float64(f)):
+			return fmt.Appendf(ans, "f32(0x%08x)", bits)
+		case math.IsInf(float64(f), 0):
+			ans = appendInfinity(ans, float64(f))
+		default:
+			ans, _ = appendFloatJSON(ans, float64(f), 32, NonFiniteError)
+		}
+		return append(ans, "f32"...)
+	}
+	bits := binary.BigEndian.Uint64(data)
+	f := math.Float64frombits(bits)
+	switch {
+	case math.IsNaN(f):
+		return fmt.Appendf(ans, "f64(0x%016x)", bits)
+	case math.IsInf(f, 0):
+		return appendInfinity(ans, f)
+	}
+	return appendFloat64Literal(ans, f)
+}
+
+func appendInfinity(ans []byte, f float64) []byte {
+	if f < 0 {
+		ans = append(ans, '-')
+	}
+	return append(ans, "Infinity"...)
+}
+
+func appendHexLiteral(ans, data []byte) []byte {
+	ans = append(ans, "h'"...)
+	ans = hex.AppendEncode(ans, data)
+	return append(ans, '\'')
+}
+
+// FromDiagnostic parses a single value in the diagnostic notation of
+// ToDiagnostic and returns its message pack encoding. Whitespace between
+// tokens is ignored.
+func FromDiagnostic(text string) ([]byte, error) {
+	p := diagParser{s: text}
+	ans, err := p.value(nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.space(); p.i != len(p.s) {
+		return nil, p.errorf("unexpected %q after the value", p.s[p.i:])
+	}
+	return ans, nil
+}
+
+// diagParser 讀取 diagnostic notation，i 為目前讀取的位置
+type diagParser struct {
+	s string
+	i int
+}
+
+func (p *diagParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrDiagnostic, p.i, fmt.Sprintf(format, args...))
+}
+
+func (p *diagParser) space() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// consume 略過空白後讀取 c，回傳下一個字元是否為 c
+func (p *diagParser) consume(c byte) bool {
+	p.space()
+	if p.i < len(p.s) && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *diagParser) expect(c byte) error {
+	if !p.consume(c) {
+		return p.errorf("expected %q", c)
+	}
+	return nil
+}
+
+// word 讀取連續的英文字母與數字
+func (p *diagParser) word() string {
+	start := p.i
+	for p.i < len(p.s) && (isLetter(p.s[p.i]) || isDigit(p.s[p.i])) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// suffix 讀取值後面的後綴，回傳對應的 format，family 必須相同
+func (p *diagParser) suffix(family string) (string, error) {
+	start := p.i
+	suffix := p.word()
+	if suffix == "" {
+		return "", nil
+	}
+	format, ok := diagFormat[suffix]
+	if !ok || formatFamily(format) != family {
+		p.i = start
+		return "", p.errorf("suffix %q on %s", suffix, family)
+	}
+	return format, nil
+}
+
+func (p *diagParser) value(ans []byte) ([]byte, error) {
+	p.space()
+	if p.i == len(p.s) {
+		return nil, p.errorf("unexpected end")
+	}
+	switch c := p.s[p.i]; {
+	case c == '[' || c == '{':
+		return p.container(ans)
+	case c == '"':
+		return p.str(ans)
+	case c == 'h' && strings.HasPrefix(p.s[p.i:], "h'"):
+		data, err := p.hexLiteral()
+		if err != nil {
+			return nil, err
+		}
+		format, err := p.suffix("bin")
+		if err != nil {
+			return nil, err
+		}
+		if format == "" {
+			format = minimalFormat("bin", len(data))
+		}
+		if ans, err = appendFormatHeader(ans, format, len(data)); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return append(ans, data...), nil
+	case c == '-' || isDigit(c) || strings.HasPrefix(p.s[p.i:], "Infinity"):
+		return p.number(ans)
+	}
+
+	start := p.i
+	switch word := p.word(); word {
+	case "nil":
+		return AppendNil(ans), nil
+	case "true", "false":
+		return AppendBool(ans, word == "true"), nil
+	case "f32", "f64":
+		return p.floatBits(ans, word)
+	case "ext", "ext8", "ext16", "ext32", "fixext1", "fixext2", "fixext4", "fixext8", "fixext16":
+		return p.ext(ans, word)
+	}
+	p.i = start
+	return nil, p.errorf("unexpected %q", p.s[p.i:min(p.i+10, len(p.s))])
+}
+
+// container 讀取 array 或 map 與它的後綴
+func (p *diagParser) container(ans []byte) ([]byte, error) {
+	family, end := "array", byte(']')
+	if p.s[p.i] == '{' {
+		family, end = "map", '}'
+	}
+	p.i++
+	var items []byte
+	n := 0
+	for !p.consume(end) {
+		if n > 0 {
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+		}
+		var err error
+		if items, err = p.value(items); err != nil {
+			return nil, err
+		}
+		if family == "map" {
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			if items, err = p.value(items); err != nil {
+				return nil, err
+			}
+		}
+		n++
+	}
+	format, err := p.suffix(family)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = minimalFormat(family, n)
+	}
+	if ans, err = appendFormatHeader(ans, format, n); err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	return append(ans, items...), nil
+}
+
+func (p *diagParser) str(ans []byte) ([]byte, error) {
+	quoted, err := strconv.QuotedPrefix(p.s[p.i:])
+	if err != nil {
+		return nil, p.errorf("invalid string")
+	}
+	s, _ := strconv.Unquote(quoted)
+	p.i += len(quoted)
+	format, err := p.suffix("str")
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = minimalFormat("str", len(s))
+	}
+	if ans, err = appendFormatHeader(ans, format, len(s)); err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	return append(ans, s...), nil
+}
+
+// hexLiteral 讀取 h'...'
+func (p *diagParser) hexLiteral() ([]byte, error) {
+	p.i += len("h'")
+	end := strings.IndexByte(p.s[p.i:], '\'')
+	if end < 0 {
+		return nil, p.errorf("unterminated hex literal")
+	}
+	data, err := hex.DecodeString(p.s[p.i : p.i+end])
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.i += end + 1
+	return data, nil
+}
+
+// number 讀取 int 或 float 與它的後綴
+func (p *diagParser) number(ans []byte) ([]byte, error) {
+	start := p.i
+	if p.s[p.i] == '-' {
+		p.i++
+	}
+	if strings.HasPrefix(p.s[p.i:], "Infinity") {
+		p.i += len("Infinity")
+	} else {
+		for p.i < len(p.s) && (isDigit(p.s[p.i]) || strings.IndexByte(".eE+-", p.s[p.i]) >= 0) {
+			p.i++
+		}
+	}
+	text := p.s[start:p.i]
+	suffixStart := p.i
+	suffix := p.word()
+	format, ok := diagFormat[suffix]
+	switch family := formatFamily(format); {
+	case suffix == "":
+		if strings.ContainsAny(text, ".eEI") {
+			format = "float64"
+		}
+	case !ok || family != "uint" && family != "int" && family != "float":
+		p.i = suffixStart
+		return nil, p.errorf("suffix %q on a number", suffix)
+	}
+
+	var bits uint64
+	var err error
+	switch formatFamily(format) {
+	case "":
+		if strings.HasPrefix(text, "-") {
+			var i int64
+			if i, err = strconv.ParseInt(text, 10, 64); err == nil {
+				return AppendInt(ans, i), nil
+			}
+		} else {
+			var u uint64
+			if u, err = strconv.ParseUint(text, 10, 64); err == nil {
+				return AppendUint(ans, u), nil
+			}
+		}
+	case "uint":
+		bits, err = strconv.ParseUint(text, 10, 8*formatWidth(format))
+	case "int":
+		var i int64
+		i, err = strconv.ParseInt(text, 10, 8*formatWidth(format))
+		bits = uint64(i)
+	case "float":
+		var f float64
+		f, err = strconv.ParseFloat(text, 8*formatWidth(format))
+		bits = math.Float64bits(f)
+		if format == "float32" {
+			bits = uint64(math.Float32bits(float32(f)))
+		}
+	}
+	if err != nil {
+		p.i = start
+		return nil, p.errorf("number %s%s out of range or invalid", text, suffix)
+	}
+	return appendBigEndian(append(ans, FirstByte[format]), bits, formatWidth(format)), nil
+}
+
+// floatBits 讀取 f32(0x...) 或 f64(0x...)
+func (p *diagParser) floatBits(ans []byte, name string) ([]byte, error) {
+	format := diagFormat[name]
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	p.space()
+	start := p.i
+	if !strings.HasPrefix(p.s[p.i:], "0x") {
+		return nil, p.errorf("expected hex bits")
+	}
+	p.i += len("0x")
+	bits, err := strconv.ParseUint(p.word(), 16, 8*formatWidth(format))
+	if err != nil {
+		p.i = start
+		return nil, p.errorf("invalid %s bits", name)
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return appendBigEndian(append(ans, FirstByte[format]), bits, formatWidth(format)), nil
+}
+
+// ext 讀取 ext(type, h'...')
+func (p *diagParser) ext(ans []byte, format string) ([]byte, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	p.space()
+	start := p.i
+	if p.i < len(p.s) && p.s[p.i] == '-' {
+		p.i++
+	}
+	p.word()
+	typ, err := strconv.ParseInt(p.s[start:p.i], 10, 8)
+	if err != nil {
+		p.i = start
+		return nil, p.errorf("invalid ext type")
+	}
+	if err := p.expect(','); err != nil {
+		return nil, err
+	}
+	if p.space(); !strings.HasPrefix(p.s[p.i:], "h'") {
+		return nil, p.errorf("expected hex literal")
+	}
+	data, err := p.hexLiteral()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	if format == "ext" {
+		format = minimalFormat("ext", len(data))
+	}
+	if ans, err = appendFormatHeader(ans, format, len(data)); err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	return append(append(ans, byte(typ)), data...), nil
+}
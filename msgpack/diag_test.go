@@ -0,0 +1,111 @@
+package msgpack_test
+
+import (
+	. "msgpackconv/msgpack"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnostic(t *testing.T) {
+	tests := []struct {
+		name        string
+		msgpackconv []byte
+		want        string
+	}{
+		{"nil", []byte{0xc0}, `nil`},
+		{"bool", []byte{0x92, 0xc3, 0xc2}, `[true, false]`},
+		{"fixint", []byte{0x92, 0x05, 0xff}, `[5, -1]`},
+		{"uint8", []byte{0xcc, 0xc8}, `200`},
+		{"uint8 for fixint", []byte{0xcc, 0x01}, `1u8`},
+		{"int16 for fixint", []byte{0xd1, 0xff, 0xff}, `-1i16`},
+		{"uint64", []byte{0xcf, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07}, `7u64`},
+		{"float64", []byte{0xcb, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, `1.5`},
+		{"integral float64", []byte{0xcb, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, `-2.0`},
+		{"float32", []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, `1.5f32`},
+		{"float32 inf", []byte{0xca, 0xff, 0x80, 0x00, 0x00}, `-Infinityf32`},
+		{"float64 inf", []byte{0xcb, 0x7f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, `Infinity`},
+		{"NaN", []byte{0xcb, 0x7f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, `f64(0x7ff8000000000001)`},
+		{"float32 NaN", []byte{0xca, 0x7f, 0xc0, 0x00, 0x00}, `f32(0x7fc00000)`},
+		{"str", []byte{0xa2, 0x68, 0x69}, `"hi"`},
+		{"str16", []byte{0xda, 0x00, 0x01, 0x61}, `"a"s16`},
+		{"escaped str", []byte{0xa3, 0x22, 0x0a, 0xff}, `"\"\n\xff"`},
+		{"bin", []byte{0xc4, 0x02, 0x00, 0xff}, `h'00ff'`},
+		{"empty bin16", []byte{0xc5, 0x00, 0x00}, `h''b16`},
+		{"fixext1", []byte{0xd4, 0x05, 0x01}, `ext(5, h'01')`},
+		{"ext8", []byte{0xc7, 0x00, 0xff}, `ext(-1, h'')`},
+		{"ext16 for fixext", []byte{0xc8, 0x00, 0x01, 0x05, 0x01}, `ext16(5, h'01')`},
+		{"array16", []byte{0xdc, 0x00, 0x01, 0xc0}, `[nil]a16`},
+		{"map", []byte{0x82, 0xa1, 0x61, 0x01, 0x02, 0x90}, `{"a": 1, 2: []}`},
+		{"map32", []byte{0xdf, 0x00, 0x00, 0x00, 0x00}, `{}m32`},
+		{"request example", []byte{0x95, 0xcc, 0x01, 0xda, 0x00, 0x01, 0x61, 0xc4, 0x02, 0x00, 0xff, 0xd4, 0x05, 0x01, 0xca, 0x3f, 0xc0, 0x00, 0x00}, `[1u8, "a"s16, h'00ff', ext(5, h'01'), 1.5f32]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToDiagnostic(tt.msgpackconv)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			back, err := FromDiagnostic(got)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.msgpackconv, back)
+		})
+	}
+}
+
+func TestFromDiagnostic(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []byte
+	}{
+		{"whitespace", " [ 1 ,\n\t{ \"a\" : nil } ] ", []byte{0x92, 0x01, 0x81, 0xa1, 0x61, 0xc0}},
+		{"exponent", `1e3`, []byte{0xcb, 0x40, 0x8f, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"f64 suffix", `1f64`, []byte{0xcb, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"f32 bits", `f32( 0x3fc00000 )`, []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}},
+		{"s8", `"a"s8`, []byte{0xd9, 0x01, 0x61}},
+		{"unicode", `"é"`, []byte{0xa2, 0xc3, 0xa9}},
+		{"fixext4", `fixext4(1, h'01020304')`, []byte{0xd6, 0x01, 0x01, 0x02, 0x03, 0x04}},
+		{"map16", `{1: 2}m16`, []byte{0xde, 0x00, 0x01, 0x01, 0x02}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromDiagnostic(tt.text)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFromDiagnosticFail(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"empty", ``, "invalid diagnostic notation at offset 0: unexpected end"},
+		{"out of range", `256u8`, "invalid diagnostic notation at offset 0: number 256u8 out of range or invalid"},
+		{"negative uint", `-1u16`, "invalid diagnostic notation at offset 0: number -1u16 out of range or invalid"},
+		{"big int", `18446744073709551616`, "invalid diagnostic notation at offset 0: number 18446744073709551616 out of range or invalid"},
+		{"wrong suffix", `"a"u8`, `invalid diagnostic notation at offset 3: suffix "u8" on str`},
+		{"array suffix", `[]m16`, `invalid diagnostic notation at offset 2: suffix "m16" on array`},
+		{"unknown word", `null`, `invalid diagnostic notation at offset 0: unexpected "null"`},
+		{"missing comma", `[1 2]`, `invalid diagnostic notation at offset 3: expected ','`},
+		{"missing colon", `{1}`, `invalid diagnostic notation at offset 2: expected ':'`},
+		{"bad hex", `h'0'`, "invalid diagnostic notation at offset 2: encoding/hex: odd length hex string"},
+		{"unterminated hex", `h'00`, "invalid diagnostic notation at offset 2: unterminated hex literal"},
+		{"fixext length", `fixext2(1, h'01')`, "invalid diagnostic notation at offset 17: 1 bytes in fixext2"},
+		{"ext type", `ext(128, h'')`, "invalid diagnostic notation at offset 4: invalid ext type"},
+		{"trailing", `1 2`, `invalid diagnostic notation at offset 2: unexpected "2" after the value`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromDiagnostic(tt.text)
+			assert.ErrorIs(t, err, ErrDiagnostic)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+
+	_, err := ToDiagnostic([]byte{0x91})
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+}
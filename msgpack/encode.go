@@ -32,6 +32,26 @@ var FirstByte = map[string]byte{
 	"fixmap":         0x80,
 	"map16":          0xde,
 	"map32":          0xdf,
+	"bin8":           0xc4,
+	"bin16":          0xc5,
+	"bin32":          0xc6,
+	"ext8":           0xc7,
+	"ext16":          0xc8,
+	"ext32":          0xc9,
+	"fixext1":        0xd4,
+	"fixext2":        0xd5,
+	"fixext4":        0xd6,
+	"fixext8":        0xd7,
+	"fixext16":       0xd8,
+}
+
+// fixext 的資料長度固定為 1, 2, 4, 8 或 16
+var fixextFormat = map[int]string{
+	1:  "fixext1",
+	2:  "fixext2",
+	4:  "fixext4",
+	8:  "fixext8",
+	16: "fixext16",
 }
 
 func FromJSON(bytes []byte) []byte {
@@ -58,13 +78,13 @@ func encode(obj interface{}) []byte {
 	case float64:
 		ans = append(ans, getNumberFormat(v)...)
 	case map[string]interface{}:
-		ans = append(ans, getMapFormat(v)...)
+		ans = append(ans, getMapFormat(len(v))...)
 		for k, vvv := range v {
 			ans = append(ans, getStrFormat(k)...)
 			ans = append(ans, encode(vvv)...)
 		}
 	case []interface{}:
-		ans = append(ans, getArrayFormat(v)...)
+		ans = append(ans, getArrayFormat(len(v))...)
 		for i := range v {
 			ans = append(ans, encode(v[i])...)
 		}
@@ -112,16 +132,30 @@ func getBoolFormat(v bool) byte {
 }
 
 func getNumberFormat(v float64) []byte {
-	if v == float64(int(v)) {
+	// -0 以 float 編碼，保留負號
+	if v == float64(int(v)) && !isNegativeZero(v) {
 		if v >= 0 {
-			return getPositiveIntFormat(int(v))
+			return getPositiveIntFormat(uint64(v))
 		}
-		return getNegativeIntFormat(int(v))
+		return getNegativeIntFormat(int64(v))
 	}
 	return getFloatFormat(v)
 }
 
-func getPositiveIntFormat(v int) []byte {
+func isNegativeZero(v float64) bool {
+	return v == 0 && math.Signbit(v)
+}
+
+// getLosslessNumberFormat 與 getNumberFormat 相同，但能無損轉為 float32 的
+// float 以 float32 編碼
+func getLosslessNumberFormat(v float64) []byte {
+	if (v != float64(int(v)) || isNegativeZero(v)) && float64(float32(v)) == v {
+		return getFloat32Format(float32(v))
+	}
+	return getNumberFormat(v)
+}
+
+func getPositiveIntFormat(v uint64) []byte {
 	switch {
 	case v < 128:
 		return []byte{FirstByte["positiveFixint"] | byte(v)}
@@ -147,28 +181,28 @@ func getPositiveIntFormat(v int) []byte {
 		// uint64
 		ans := make([]byte, 9)
 		ans[0] = FirstByte["uint64"]
-		binary.BigEndian.PutUint64(ans[1:9], uint64(v))
+		binary.BigEndian.PutUint64(ans[1:9], v)
 		return ans
 	}
 }
 
-func getNegativeIntFormat(v int) []byte {
+func getNegativeIntFormat(v int64) []byte {
 	switch {
-	case -v <= 32:
+	case v >= -32:
 		return []byte{FirstByte["negativeFixint"] | byte(32+v)}
-	case float64(-v) < math.Pow(2, 7):
+	case float64(v) > -math.Pow(2, 7):
 		// int8
 		ans := make([]byte, 2)
 		ans[0] = FirstByte["int8"]
 		ans[1] = byte(v)
 		return ans
-	case float64(-v) < math.Pow(2, 15):
+	case float64(v) > -math.Pow(2, 15):
 		// int16
 		ans := make([]byte, 3)
 		ans[0] = FirstByte["int16"]
 		binary.BigEndian.PutUint16(ans[1:3], uint16(v))
 		return ans
-	case float64(-v) < math.Pow(2, 31):
+	case float64(v) > -math.Pow(2, 31):
 		// int32
 		ans := make([]byte, 5)
 		ans[0] = FirstByte["int32"]
@@ -191,12 +225,80 @@ func getFloatFormat(v float64) []byte {
 	return ans
 }
 
-func getMapFormat(v map[string]interface{}) []byte {
+func getFloat32Format(v float32) []byte {
+	bits := math.Float32bits(v)
+	ans := make([]byte, 5)
+	ans[0] = FirstByte["float32"]
+	binary.BigEndian.PutUint32(ans[1:5], bits)
+	return ans
+}
+
+func getBinFormat(v []byte) []byte {
 	l := len(v)
+	var ans []byte
+	switch {
+	case float64(l) < math.Pow(2, 8):
+		// bin8
+		ans = make([]byte, 2+l)
+		ans[0] = FirstByte["bin8"]
+		ans[1] = byte(l)
+		copy(ans[2:], v)
+	case float64(l) < math.Pow(2, 16):
+		// bin16
+		ans = make([]byte, 3+l)
+		ans[0] = FirstByte["bin16"]
+		binary.BigEndian.PutUint16(ans[1:3], uint16(l))
+		copy(ans[3:], v)
+	default:
+		// bin32
+		ans = make([]byte, 5+l)
+		ans[0] = FirstByte["bin32"]
+		binary.BigEndian.PutUint32(ans[1:5], uint32(l))
+		copy(ans[5:], v)
+	}
+	return ans
+}
+
+func getExtFormat(typ int8, data []byte) []byte {
+	l := len(data)
+	var ans []byte
+	switch {
+	case fixextFormat[l] != "":
+		// fixext 1 / 2 / 4 / 8 / 16
+		ans = make([]byte, 2+l)
+		ans[0] = FirstByte[fixextFormat[l]]
+		ans[1] = byte(typ)
+		copy(ans[2:], data)
+	case float64(l) < math.Pow(2, 8):
+		// ext8
+		ans = make([]byte, 3+l)
+		ans[0] = FirstByte["ext8"]
+		ans[1] = byte(l)
+		ans[2] = byte(typ)
+		copy(ans[3:], data)
+	case float64(l) < math.Pow(2, 16):
+		// ext16
+		ans = make([]byte, 4+l)
+		ans[0] = FirstByte["ext16"]
+		binary.BigEndian.PutUint16(ans[1:3], uint16(l))
+		ans[3] = byte(typ)
+		copy(ans[4:], data)
+	default:
+		// ext32
+		ans = make([]byte, 6+l)
+		ans[0] = FirstByte["ext32"]
+		binary.BigEndian.PutUint32(ans[1:5], uint32(l))
+		ans[5] = byte(typ)
+		copy(ans[6:], data)
+	}
+	return ans
+}
+
+func getMapFormat(l int) []byte {
 	switch {
 	case l < 16:
 		// fixmap
-		return []byte{(FirstByte["fixmap"] | byte(len(v)))}
+		return []byte{(FirstByte["fixmap"] | byte(l))}
 	case l < int(math.Pow(2, 16)):
 		// map16
 		ans := make([]byte, 3)
@@ -212,12 +314,11 @@ func getMapFormat(v map[string]interface{}) []byte {
 	}
 }
 
-func getArrayFormat(v []interface{}) []byte {
-	l := len(v)
+func getArrayFormat(l int) []byte {
 	switch {
 	case l < 16:
 		// fixarray
-		return []byte{(FirstByte["fixarray"] | byte(len(v)))}
+		return []byte{(FirstByte["fixarray"] | byte(l))}
 	case l < int(math.Pow(2, 16)):
 		// array16
 		ans := make([]byte, 3)
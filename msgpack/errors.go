@@ -3,5 +3,21 @@ package msgpack
 import "errors"
 
 var (
-	ErrInvalidMsgPack = errors.New("invalid message pack")
+	ErrInvalidMsgPack    = errors.New("invalid message pack")
+	ErrKindMismatch      = errors.New("value kind mismatch")
+	ErrNotJSONCompatible = errors.New("value has no JSON representation")
+	ErrUnsupportedType   = errors.New("unsupported type")
+	ErrInvalidUnmarshal  = errors.New("unmarshal target must be a non-nil pointer")
+	ErrUnmarshalType     = errors.New("cannot unmarshal message pack value")
+	ErrArrayLength       = errors.New("array length does not match struct fields")
+	ErrBigNumber         = errors.New("number does not fit 64 bits")
+	ErrDuplicateKey      = errors.New("duplicate map key")
+	ErrExtendedJSON      = errors.New("invalid extended JSON")
+	ErrDiagnostic        = errors.New("invalid diagnostic notation")
+	ErrInvalidYAML       = errors.New("invalid YAML")
+	ErrNotYAMLCompatible = errors.New("value has no YAML representation")
+	ErrInvalidCSV        = errors.New("invalid CSV")
+	ErrNotCSVCompatible  = errors.New("value has no CSV representation")
+	ErrInvalidXML        = errors.New("invalid XML")
+	ErrNotXMLCompatible  = errors.New("value has no XML representation")
 )
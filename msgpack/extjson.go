@@ -0,0 +1,748 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ToExtendedJSON converts a single message pack value to extended JSON, a
+// form of JSON that FromExtendedJSON converts back to exactly the same
+// bytes.
+//
+// A value is written as plain JSON when FromExtendedJSON encodes that JSON
+// to the same bytes: nil, bool, ints in their smallest format, float64,
+// which always has a decimal point or exponent, UTF-8 str and arrays in
+// their smallest format, and maps in their smallest format whose keys are
+// such strs and whose first key does not start with "$". Any other value
+// is wrapped in an object with a single key:
+//
+//	{"$int16":"5"}                    uint8 to int64 formats, as a decimal string
+//	{"$f32":1.5}                      float32
+//	{"$f64":"Infinity"}               non-finite float64
+//	{"$str16":"abc"}                  str in a larger format than needed
+//	{"$str":{"$base64":"/w=="}}       str that is not valid UTF-8
+//	{"$bin":"AQI="}                   bin
+//	{"$ext":{"type":5,"data":"AQ=="}} ext
+//	{"$array16":[1,2]}                array in a larger format than needed
+//	{"$map":[[1,"a"],[2,"b"]]}        map, as key and value pairs
+//
+// "$str", "$bin", "$ext" and "$map" use the smallest format, and a format
+// name such as "$bin16" or "$fixext4" selects that format. Non-finite
+// floats are "Infinity", "-Infinity" or the hex bits of the NaN, such as
+// "0x7ff8000000000001".
+func ToExtendedJSON(msgpackconv []byte) ([]byte, error) {
+	ans, n, err := appendExtendedJSON(nil, msgpackconv)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(msgpackconv) {
+		return nil, ErrInvalidMsgPack
+	}
+	return ans, nil
+}
+
+// appendExtendedJSON 將 msgpackconv 的第一個值以 extended JSON 寫出，回傳
+// 讀取的 byte 數
+func appendExtendedJSON(ans, msgpackconv []byte) ([]byte, int, error) {
+	h, err := readHeader(msgpackconv)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch h.format {
+	case "fixarray", "array16", "array32":
+		return appendExtendedArray(ans, msgpackconv, h)
+	case "fixmap", "map16", "map32":
+		return appendExtendedMap(ans, msgpackconv, h)
+	}
+
+	n := h.size + h.length
+	if len(msgpackconv) < n {
+		return nil, 0, ErrInvalidMsgPack
+	}
+	data := msgpackconv[h.size:n]
+	minimal := h.format == minimalFormat(formatFamily(h.format), h.length)
+	switch formatFamily(h.format) {
+	case "nil":
+		ans = append(ans, "null"...)
+	case "true", "false":
+		ans = append(ans, h.format...)
+	case "positiveFixint", "negativeFixint", "uint", "int":
+		text, canonical, err := intLiteral(msgpackconv[:n])
+		if err != nil {
+			return nil, 0, err
+		}
+		if canonical {
+			ans = append(ans, text...)
+		} else {
+			ans = fmt.Appendf(ans, `{"$%s":"%s"}`, h.format, text)
+		}
+	case "float":
+		if h.format == "float32" {
+			bits := binary.BigEndian.Uint32(data)
+			ans = append(ans, `{"$f32":`...)
+			ans = append(appendExtendedFloat(ans, float64(math.Float32frombits(bits)), 32, uint64(bits)), '}')
+			break
+		}
+		bits := binary.BigEndian.Uint64(data)
+		f := math.Float64frombits(bits)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			ans = append(ans, `{"$f64":`...)
+			ans = append(appendExtendedFloat(ans, f, 64, bits), '}')
+			break
+		}
+		ans = appendFloat64Literal(ans, f)
+	case "str":
+		valid := utf8.Valid(data)
+		if minimal && valid {
+			ans = appendStringJSON(ans, string(data), ToJSONOptions{})
+			break
+		}
+		ans = appendWrapperKey(ans, "str", h.format, minimal)
+		if valid {
+			ans = appendStringJSON(ans, string(data), ToJSONOptions{})
+		} else {
+			ans = append(ans, `{"$base64":"`...)
+			ans = append(base64.StdEncoding.AppendEncode(ans, data), `"}`...)
+		}
+		ans = append(ans, '}')
+	case "bin":
+		ans = append(appendWrapperKey(ans, "bin", h.format, minimal), '"')
+		ans = append(base64.StdEncoding.AppendEncode(ans, data), `"}`...)
+	case "ext":
+		ans = appendWrapperKey(ans, "ext", h.format, minimal)
+		ans = fmt.Appendf(ans, `{"type":%d,"data":"`, h.extType)
+		ans = append(base64.StdEncoding.AppendEncode(ans, data), `"}}`...)
+	default:
+		return nil, 0, ErrInvalidMsgPack
+	}
+	return ans, n, nil
+}
+
+// appendWrapperKey 寫出 wrapper 的開頭，最小的 format 以 family 為 key
+func appendWrapperKey(ans []byte, family, format string, minimal bool) []byte {
+	if minimal {
+		format = family
+	}
+	return fmt.Appendf(ans, `{"$%s":`, format)
+}
+
+// appendExtendedFloat 寫出 float 的 JSON 數字，±Inf 寫為 "Infinity" 與
+// "-Infinity"，NaN 寫為 bits 的十六進位字串以保留 payload
+func appendExtendedFloat(ans []byte, f float64, bitSize int, bits uint64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return fmt.Appendf(ans, `"0x%0*x"`, bitSize/4, bits)
+	case math.IsInf(f, 1):
+		return append(ans, `"Infinity"`...)
+	case math.IsInf(f, -1):
+		return append(ans, `"-Infinity"`...)
+	}
+	ans, _ = appendFloatJSON(ans, f, bitSize, NonFiniteError)
+	return ans
+}
+
+// intLiteral 回傳 int 的十進位文字，以及 msgpackconv 是否為 AppendInt 或
+// AppendUint 對這個值的編碼
+func intLiteral(msgpackconv []byte) (string, bool, error) {
+	val, _, err := decodeValue(msgpackconv)
+	if err != nil {
+		return "", false, err
+	}
+	if val.kind == IntKind {
+		return strconv.FormatInt(int64(val.num), 10), bytes.Equal(AppendInt(nil, int64(val.num)), msgpackconv), nil
+	}
+	return strconv.FormatUint(val.num, 10), bytes.Equal(AppendUint(nil, val.num), msgpackconv), nil
+}
+
+// appendFloat64Literal 寫出有限的 float64。沒有小數點與指數的數字會被讀取
+// 為 int，因此補上 ".0"
+func appendFloat64Literal(ans []byte, f float64) []byte {
+	start := len(ans)
+	ans, _ = appendFloatJSON(ans, f, 64, NonFiniteError)
+	if !bytes.ContainsAny(ans[start:], ".e") {
+		ans = append(ans, ".0"...)
+	}
+	return ans
+}
+
+func appendExtendedArray(ans, msgpackconv []byte, h header) ([]byte, int, error) {
+	wrapped := h.format != minimalFormat("array", h.length)
+	if wrapped {
+		ans = fmt.Appendf(ans, `{"$%s":`, h.format)
+	}
+	ans = append(ans, '[')
+	idxOfEnd := h.size
+	for i := range h.length {
+		if i > 0 {
+			ans = append(ans, ',')
+		}
+		var n int
+		var err error
+		if ans, n, err = appendExtendedJSON(ans, msgpackconv[idxOfEnd:]); err != nil {
+			return nil, 0, err
+		}
+		idxOfEnd += n
+	}
+	ans = append(ans, ']')
+	if wrapped {
+		ans = append(ans, '}')
+	}
+	return ans, idxOfEnd, nil
+}
+
+func appendExtendedMap(ans, msgpackconv []byte, h header) ([]byte, int, error) {
+	minimal := h.format == minimalFormat("map", h.length)
+	plain, err := hasPlainKeys(msgpackconv, h)
+	if err != nil {
+		return nil, 0, err
+	}
+	plain = plain && minimal
+
+	if plain {
+		ans = append(ans, '{')
+	} else {
+		ans = append(appendWrapperKey(ans, "map", h.format, minimal), '[')
+	}
+	idxOfEnd := h.size
+	for i := range h.length {
+		if i > 0 {
+			ans = append(ans, ',')
+		}
+		if !plain {
+			ans = append(ans, '[')
+		}
+		var n int
+		if ans, n, err = appendExtendedJSON(ans, msgpackconv[idxOfEnd:]); err != nil {
+			return nil, 0, err
+		}
+		idxOfEnd += n
+		if plain {
+			ans = append(ans, ':')
+		} else {
+			ans = append(ans, ',')
+		}
+		if ans, n, err = appendExtendedJSON(ans, msgpackconv[idxOfEnd:]); err != nil {
+			return nil, 0, err
+		}
+		idxOfEnd += n
+		if !plain {
+			ans = append(ans, ']')
+		}
+	}
+	if plain {
+		ans = append(ans, '}')
+	} else {
+		ans = append(ans, "]}"...)
+	}
+	return ans, idxOfEnd, nil
+}
+
+// hasPlainKeys 回傳 map 的 key 是否都能寫為 JSON object 的 key：最小 format
+// 的 UTF-8 str，且第一個 key 不會被當作 wrapper
+func hasPlainKeys(msgpackconv []byte, h header) (bool, error) {
+	idxOfEnd := h.size
+	for i := range h.length {
+		k, err := readHeader(msgpackconv[idxOfEnd:])
+		if err != nil {
+			return false, err
+		}
+		if formatFamily(k.format) != "str" || k.format != minimalFormat("str", k.length) {
+			return false, nil
+		}
+		idxOfEnd += k.size + k.length
+		if idxOfEnd > len(msgpackconv) {
+			return false, ErrInvalidMsgPack
+		}
+		key := msgpackconv[idxOfEnd-k.length : idxOfEnd]
+		if !utf8.Valid(key) || i == 0 && bytes.HasPrefix(key, []byte("$")) {
+			return false, nil
+		}
+		n, err := skip(msgpackconv[idxOfEnd:])
+		if err != nil {
+			return false, err
+		}
+		idxOfEnd += n
+	}
+	return true, nil
+}
+
+// formatFamily 回傳 format 去掉 fix 與位數後的名稱，例如 "fixext4" 為
+// "ext"，"uint16" 為 "uint"
+func formatFamily(format string) string {
+	if format == "positiveFixint" || format == "negativeFixint" {
+		return format
+	}
+	return strings.TrimRight(strings.TrimPrefix(format, "fix"), "0123456789")
+}
+
+// formatWidth 回傳 format 名稱中的位數換算的 byte 數，例如 "uint16" 為 2
+func formatWidth(format string) int {
+	bits, _ := strconv.Atoi(strings.TrimLeft(format, "abcdefghijklmnopqrstuvwxyz"))
+	return bits / 8
+}
+
+// minimalFormat 回傳 family 為 "str"、"bin"、"ext"、"array" 或 "map" 時，
+// 長度 n 最小的 format。其他 family 回傳空字串
+func minimalFormat(family string, n int) string {
+	switch family {
+	case "str", "bin", "ext", "array", "map":
+	default:
+		return ""
+	}
+	switch {
+	case family == "ext" && fixextFormat[n] != "":
+		return fixextFormat[n]
+	case family == "str" && n < 32:
+		return "fixstr"
+	case (family == "array" || family == "map") && n < 16:
+		return "fix" + family
+	case family != "array" && family != "map" && n < 1<<8:
+		return family + "8"
+	case n < 1<<16:
+		return family + "16"
+	}
+	return family + "32"
+}
+
+// appendFormatHeader 以指定的 format 寫出長度為 n 的 header，ext 的 type
+// 由呼叫者寫出。錯誤不包含 sentinel，由呼叫者包裝
+func appendFormatHeader(ans []byte, format string, n int) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(format, "fixext"):
+		if fixextFormat[n] != format {
+			return nil, fmt.Errorf("%d bytes in %s", n, format)
+		}
+		return append(ans, FirstByte[format]), nil
+	case strings.HasPrefix(format, "fix"):
+		limit := 16
+		if format == "fixstr" {
+			limit = 32
+		}
+		if n >= limit {
+			return nil, fmt.Errorf("length %d in %s", n, format)
+		}
+		return append(ans, FirstByte[format]|byte(n)), nil
+	}
+	width := formatWidth(format)
+	if uint64(n) >= 1<<(8*width) {
+		return nil, fmt.Errorf("length %d in %s", n, format)
+	}
+	return appendBigEndian(append(ans, FirstByte[format]), uint64(n), width), nil
+}
+
+// appendBigEndian 以 width 個 byte 的 big-endian 寫出 v
+func appendBigEndian(ans []byte, v uint64, width int) []byte {
+	for i := width - 1; i >= 0; i-- {
+		ans = append(ans, byte(v>>(8*i)))
+	}
+	return ans
+}
+
+// FromExtendedJSON converts a single extended JSON value, as written by
+// ToExtendedJSON, to message pack. Plain JSON is encoded like FromJSON
+// does, except that a number with a decimal point or exponent is always a
+// float64, integers that do not fit 64 bits are an error and object keys
+// keep their order.
+func FromExtendedJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	p := extendedParser{dec: dec}
+	ans, err := p.value(nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: data after the value", ErrExtendedJSON)
+	}
+	return ans, nil
+}
+
+// extendedParser 逐一讀取 JSON token 並寫出 msgpack
+type extendedParser struct {
+	dec *json.Decoder
+}
+
+func (p *extendedParser) value(ans []byte) ([]byte, error) {
+	tok, err := p.token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case nil:
+		return AppendNil(ans), nil
+	case bool:
+		return AppendBool(ans, v), nil
+	case string:
+		return AppendString(ans, v), nil
+	case json.Number:
+		return appendNumberLiteral(ans, v)
+	case json.Delim:
+		if v == '[' {
+			items, n, err := p.elements()
+			if err != nil {
+				return nil, err
+			}
+			return append(AppendArrayHeader(ans, n), items...), nil
+		}
+		return p.object(ans)
+	}
+	return nil, fmt.Errorf("%w: unexpected %v", ErrExtendedJSON, tok)
+}
+
+func (p *extendedParser) token() (json.Token, error) {
+	tok, err := p.dec.Token()
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return tok, err
+}
+
+func (p *extendedParser) string() (string, error) {
+	tok, err := p.token()
+	if err != nil {
+		return "", err
+	}
+	s, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: expected a string, got %v", ErrExtendedJSON, tok)
+	}
+	return s, nil
+}
+
+func (p *extendedParser) delim(d json.Delim) error {
+	tok, err := p.token()
+	if err != nil {
+		return err
+	}
+	if tok != d {
+		return fmt.Errorf("%w: expected %v, got %v", ErrExtendedJSON, d, tok)
+	}
+	return nil
+}
+
+// elements 讀取 array 剩餘的元素與結尾的 ']'，回傳元素的編碼與數量
+func (p *extendedParser) elements() ([]byte, int, error) {
+	var items []byte
+	n := 0
+	for p.dec.More() {
+		var err error
+		if items, err = p.value(items); err != nil {
+			return nil, 0, err
+		}
+		n++
+	}
+	return items, n, p.delim(']')
+}
+
+// object 讀取 '{' 之後的 object，第一個 key 為 wrapper 時依照 wrapper 編碼
+func (p *extendedParser) object(ans []byte) ([]byte, error) {
+	var entries []byte
+	n := 0
+	for p.dec.More() {
+		key, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		if format, ok := wrapperFormat(key); ok && n == 0 {
+			if ans, err = p.wrapper(ans, format); err != nil {
+				return nil, err
+			}
+			if p.dec.More() {
+				return nil, fmt.Errorf("%w: %s wrapper with more than one key", ErrExtendedJSON, key)
+			}
+			return ans, p.delim('}')
+		}
+		entries = AppendString(entries, key)
+		if entries, err = p.value(entries); err != nil {
+			return nil, err
+		}
+		n++
+	}
+	if err := p.delim('}'); err != nil {
+		return nil, err
+	}
+	return append(AppendMapHeader(ans, n), entries...), nil
+}
+
+// wrapperFormat 回傳 wrapper 的 key 對應的 format，最小的 format 以 family
+// 表示，例如 "$str"
+func wrapperFormat(key string) (string, bool) {
+	name, ok := strings.CutPrefix(key, "$")
+	if !ok {
+		return "", false
+	}
+	switch name {
+	case "f32":
+		return "float32", true
+	case "f64":
+		return "float64", true
+	case "str", "bin", "ext", "array", "map":
+		return name, true
+	}
+	switch formatFamily(name) {
+	case "uint", "int", "str", "bin", "ext", "array", "map":
+		_, ok := FirstByte[name]
+		return name, ok
+	}
+	return "", false
+}
+
+func (p *extendedParser) wrapper(ans []byte, format string) ([]byte, error) {
+	family := formatFamily(format)
+	switch family {
+	case "uint", "int":
+		text, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		width := formatWidth(format)
+		var v uint64
+		if family == "uint" {
+			v, err = strconv.ParseUint(text, 10, 8*width)
+		} else {
+			var i int64
+			i, err = strconv.ParseInt(text, 10, 8*width)
+			v = uint64(i)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %q", ErrExtendedJSON, format, text)
+		}
+		return appendBigEndian(append(ans, FirstByte[format]), v, width), nil
+	case "float":
+		return p.float(ans, format)
+	case "str":
+		data, err := p.strData()
+		if err != nil {
+			return nil, err
+		}
+		return appendWithHeader(ans, format, data)
+	case "bin":
+		text, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		data, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrExtendedJSON, format, err)
+		}
+		return appendWithHeader(ans, format, data)
+	case "ext":
+		return p.ext(ans, format)
+	case "array":
+		if err := p.delim('['); err != nil {
+			return nil, err
+		}
+		items, n, err := p.elements()
+		if err != nil {
+			return nil, err
+		}
+		if format == "array" {
+			format = minimalFormat("array", n)
+		}
+		if ans, err = appendFormatHeader(ans, format, n); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrExtendedJSON, err)
+		}
+		return append(ans, items...), nil
+	}
+	return p.pairs(ans, format)
+}
+
+// appendWithHeader 以 format 寫出 str 或 bin，format 為 family 時使用最小的 format
+func appendWithHeader(ans []byte, format string, data []byte) ([]byte, error) {
+	if format == "str" || format == "bin" {
+		format = minimalFormat(format, len(data))
+	}
+	ans, err := appendFormatHeader(ans, format, len(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExtendedJSON, err)
+	}
+	return append(ans, data...), nil
+}
+
+func (p *extendedParser) float(ans []byte, format string) ([]byte, error) {
+	bitSize := 8 * formatWidth(format)
+	tok, err := p.token()
+	if err != nil {
+		return nil, err
+	}
+	var bits uint64
+	switch v := tok.(type) {
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), bitSize)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %s", ErrExtendedJSON, format, v)
+		}
+		bits = math.Float64bits(f)
+		if bitSize == 32 {
+			bits = uint64(math.Float32bits(float32(f)))
+		}
+	case string:
+		inf := math.Inf(1)
+		switch {
+		case v == "Infinity":
+		case v == "-Infinity":
+			inf = math.Inf(-1)
+		case strings.HasPrefix(v, "0x"):
+			if bits, err = strconv.ParseUint(v[2:], 16, bitSize); err != nil {
+				return nil, fmt.Errorf("%w: %s %q", ErrExtendedJSON, format, v)
+			}
+			return appendBigEndian(append(ans, FirstByte[format]), bits, bitSize/8), nil
+		default:
+			return nil, fmt.Errorf("%w: %s %q", ErrExtendedJSON, format, v)
+		}
+		bits = math.Float64bits(inf)
+		if bitSize == 32 {
+			bits = uint64(math.Float32bits(float32(inf)))
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s %v", ErrExtendedJSON, format, tok)
+	}
+	return appendBigEndian(append(ans, FirstByte[format]), bits, bitSize/8), nil
+}
+
+// strData 讀取 str wrapper 的值，為字串或 {"$base64": "..."}
+func (p *extendedParser) strData() ([]byte, error) {
+	tok, err := p.token()
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := tok.(string); ok {
+		return []byte(s), nil
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("%w: str %v", ErrExtendedJSON, tok)
+	}
+	if key, err := p.string(); err != nil || key != "$base64" {
+		return nil, fmt.Errorf("%w: str must be a string or {\"$base64\": ...}", ErrExtendedJSON)
+	}
+	text, err := p.string()
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: str: %v", ErrExtendedJSON, err)
+	}
+	return data, p.delim('}')
+}
+
+// ext 讀取 {"type": n, "data": "..."}
+func (p *extendedParser) ext(ans []byte, format string) ([]byte, error) {
+	if err := p.delim('{'); err != nil {
+		return nil, err
+	}
+	var typ int8
+	var data []byte
+	seen := map[string]bool{}
+	for p.dec.More() {
+		key, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		tok, err := p.token()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "type":
+			n, ok := tok.(json.Number)
+			i, err := strconv.ParseInt(string(n), 10, 8)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("%w: ext type %v", ErrExtendedJSON, tok)
+			}
+			typ = int8(i)
+		case "data":
+			text, ok := tok.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: ext data %v", ErrExtendedJSON, tok)
+			}
+			if data, err = base64.StdEncoding.DecodeString(text); err != nil {
+				return nil, fmt.Errorf("%w: ext data: %v", ErrExtendedJSON, err)
+			}
+		default:
+			return nil, fmt.Errorf("%w: ext key %q", ErrExtendedJSON, key)
+		}
+		seen[key] = true
+	}
+	if !seen["type"] || !seen["data"] {
+		return nil, fmt.Errorf("%w: ext needs type and data", ErrExtendedJSON)
+	}
+	if err := p.delim('}'); err != nil {
+		return nil, err
+	}
+	if format == "ext" {
+		format = minimalFormat("ext", len(data))
+	}
+	ans, err := appendFormatHeader(ans, format, len(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExtendedJSON, err)
+	}
+	return append(append(ans, byte(typ)), data...), nil
+}
+
+// pairs 讀取 map wrapper 的 [[key, value], ...]
+func (p *extendedParser) pairs(ans []byte, format string) ([]byte, error) {
+	if err := p.delim('['); err != nil {
+		return nil, err
+	}
+	var entries []byte
+	n := 0
+	for p.dec.More() {
+		if err := p.delim('['); err != nil {
+			return nil, err
+		}
+		pair, count, err := p.elements()
+		if err != nil {
+			return nil, err
+		}
+		if count != 2 {
+			return nil, fmt.Errorf("%w: map entry with %d elements", ErrExtendedJSON, count)
+		}
+		entries = append(entries, pair...)
+		n++
+	}
+	if err := p.delim(']'); err != nil {
+		return nil, err
+	}
+	if format == "map" {
+		format = minimalFormat("map", n)
+	}
+	ans, err := appendFormatHeader(ans, format, n)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExtendedJSON, err)
+	}
+	return append(ans, entries...), nil
+}
+
+// appendNumberLiteral 將沒有小數點與指數的數字寫為最小的 int，其他寫為
+// float64
+func appendNumberLiteral(ans []byte, n json.Number) ([]byte, error) {
+	s := string(n)
+	if strings.ContainsAny(s, ".eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrExtendedJSON, s)
+		}
+		return AppendFloat64(ans, f), nil
+	}
+	if strings.HasPrefix(s, "-") {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrBigNumber, s)
+		}
+		return AppendInt(ans, i), nil
+	}
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBigNumber, s)
+	}
+	return AppendUint(ans, u), nil
+}
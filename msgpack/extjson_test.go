@@ -0,0 +1,158 @@
+package msgpack_test
+
+import (
+	"bytes"
+	. "msgpackconv/msgpack"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendedJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		msgpackconv []byte
+		want        string
+	}{
+		{"nil", []byte{0xc0}, `null`},
+		{"bool", []byte{0x92, 0xc3, 0xc2}, `[true,false]`},
+		{"fixint", []byte{0x92, 0x05, 0xff}, `[5,-1]`},
+		{"uint8", []byte{0xcc, 0xc8}, `200`},
+		{"uint8 for fixint", []byte{0xcc, 0x05}, `{"$uint8":"5"}`},
+		{"int8", []byte{0xd0, 0x9c}, `-100`},
+		{"int16 for fixint", []byte{0xd1, 0xff, 0xff}, `{"$int16":"-1"}`},
+		{"int64 positive", []byte{0xd3, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, `{"$int64":"1"}`},
+		{"uint64", []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, `18446744073709551615`},
+		{"float64", []byte{0xcb, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, `1.5`},
+		{"integral float64", []byte{0xcb, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, `2.0`},
+		{"negative zero", []byte{0xcb, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, `-0.0`},
+		{"large float64", []byte{0xcb, 0x44, 0x4b, 0x1a, 0xe4, 0xd6, 0xe2, 0xef, 0x50}, `1e+21`},
+		{"float32", []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, `{"$f32":1.5}`},
+		{"float32 inf", []byte{0xca, 0xff, 0x80, 0x00, 0x00}, `{"$f32":"-Infinity"}`},
+		{"float64 inf", []byte{0xcb, 0x7f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, `{"$f64":"Infinity"}`},
+		{"NaN payload", []byte{0xcb, 0x7f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a}, `{"$f64":"0x7ff800000000002a"}`},
+		{"float32 NaN", []byte{0xca, 0x7f, 0xc0, 0x00, 0x01}, `{"$f32":"0x7fc00001"}`},
+		{"fixstr", []byte{0xa2, 0x68, 0x69}, `"hi"`},
+		{"str8 for fixstr", []byte{0xd9, 0x02, 0x68, 0x69}, `{"$str8":"hi"}`},
+		{"str32 empty", []byte{0xdb, 0x00, 0x00, 0x00, 0x00}, `{"$str32":""}`},
+		{"invalid UTF-8", []byte{0xa2, 0xff, 0xfe}, `{"$str":{"$base64":"//4="}}`},
+		{"invalid UTF-8 str16", []byte{0xda, 0x00, 0x01, 0xff}, `{"$str16":{"$base64":"/w=="}}`},
+		{"bin8", []byte{0xc4, 0x02, 0x01, 0x02}, `{"$bin":"AQI="}`},
+		{"bin16 for bin8", []byte{0xc5, 0x00, 0x01, 0x01}, `{"$bin16":"AQ=="}`},
+		{"fixext1", []byte{0xd4, 0x05, 0x01}, `{"$ext":{"type":5,"data":"AQ=="}}`},
+		{"ext8 for fixext1", []byte{0xc7, 0x01, 0xff, 0x01}, `{"$ext8":{"type":-1,"data":"AQ=="}}`},
+		{"ext8 empty", []byte{0xc7, 0x00, 0x05}, `{"$ext":{"type":5,"data":""}}`},
+		{"array16", []byte{0xdc, 0x00, 0x02, 0x01, 0x02}, `{"$array16":[1,2]}`},
+		{"empty map", []byte{0x80}, `{}`},
+		{"map", []byte{0x82, 0xa1, 0x62, 0x01, 0xa1, 0x61, 0x90}, `{"b":1,"a":[]}`},
+		{"duplicate keys", []byte{0x82, 0xa1, 0x61, 0x01, 0xa1, 0x61, 0x02}, `{"a":1,"a":2}`},
+		{"int keys", []byte{0x82, 0x01, 0xa1, 0x61, 0xa1, 0x62, 0xc0}, `{"$map":[[1,"a"],["b",null]]}`},
+		{"dollar key", []byte{0x81, 0xa4, 0x24, 0x62, 0x69, 0x6e, 0xa0}, `{"$map":[["$bin",""]]}`},
+		{"later dollar key", []byte{0x82, 0xa1, 0x61, 0x01, 0xa2, 0x24, 0x78, 0x02}, `{"a":1,"$x":2}`},
+		{"map16", []byte{0xde, 0x00, 0x01, 0xa1, 0x61, 0x01}, `{"$map16":[["a",1]]}`},
+		{"str8 key", []byte{0x81, 0xd9, 0x01, 0x61, 0x01}, `{"$map":[[{"$str8":"a"},1]]}`},
+		{"nested", []byte{0x91, 0x81, 0xa1, 0x61, 0xcd, 0x00, 0x01}, `[{"a":{"$uint16":"1"}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToExtendedJSON(tt.msgpackconv)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+
+			back, err := FromExtendedJSON(got)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.msgpackconv, back)
+		})
+	}
+}
+
+func TestExtendedJSONLargeFormats(t *testing.T) {
+	// 'x' 同時是 positive fixint，可作為 array 的元素
+	long := bytes.Repeat([]byte{'x'}, 300)
+	inputs := [][]byte{
+		append([]byte{0xda, 0x01, 0x2c}, long...),
+		append([]byte{0xdb, 0x00, 0x00, 0x01, 0x2c}, long...),
+		append([]byte{0xc6, 0x00, 0x00, 0x01, 0x2c}, long...),
+		append([]byte{0xc9, 0x00, 0x00, 0x01, 0x2c, 0x07}, long...),
+		append([]byte{0xdd, 0x00, 0x00, 0x01, 0x2c}, long...),
+		append([]byte{0xdc, 0x01, 0x2c}, long...),
+	}
+	for _, in := range inputs {
+		got, err := ToExtendedJSON(in)
+		assert.NoError(t, err)
+		back, err := FromExtendedJSON(got)
+		assert.NoError(t, err)
+		assert.Equal(t, in, back)
+	}
+}
+
+func TestExtendedJSONMarshal(t *testing.T) {
+	v := map[string]interface{}{
+		"int":    []int64{-1 << 40, 0, 1 << 40},
+		"float":  []float32{0.1, -2},
+		"bytes":  []byte("raw"),
+		"nested": map[int]string{1: "a", -2: "b"},
+		"ext":    Ext(3, []byte{1, 2, 3}),
+	}
+	msg, err := Marshal(v)
+	assert.NoError(t, err)
+	got, err := ToExtendedJSON(msg)
+	assert.NoError(t, err)
+	back, err := FromExtendedJSON(got)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, back)
+}
+
+func TestFromExtendedJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want []byte
+	}{
+		{"plain", `{"a": [1, -1, 1.0, "x"]}`, []byte{0x81, 0xa1, 0x61, 0x94, 0x01, 0xff, 0xcb, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xa1, 0x78}},
+		{"fixstr wrapper", `{"$fixstr": "a"}`, []byte{0xa1, 0x61}},
+		{"fixext4", `{"$fixext4": {"data": "AQIDBA==", "type": 1}}`, []byte{0xd6, 0x01, 0x01, 0x02, 0x03, 0x04}},
+		{"array wrapper", `{"$array": [true]}`, []byte{0x91, 0xc3}},
+		{"unknown dollar key", `{"$other": 1}`, []byte{0x81, 0xa6, 0x24, 0x6f, 0x74, 0x68, 0x65, 0x72, 0x01}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromExtendedJSON([]byte(tt.json))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFromExtendedJSONFail(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want error
+	}{
+		{"out of range", `{"$uint8": "256"}`, ErrExtendedJSON},
+		{"int not a string", `{"$int16": 1}`, ErrExtendedJSON},
+		{"extra key", `{"$bin": "", "x": 1}`, ErrExtendedJSON},
+		{"bad base64", `{"$bin": "!"}`, ErrExtendedJSON},
+		{"fixstr too long", `{"$fixstr": "` + string(bytes.Repeat([]byte{'a'}, 32)) + `"}`, ErrExtendedJSON},
+		{"fixext length", `{"$fixext2": {"type": 1, "data": "AQ=="}}`, ErrExtendedJSON},
+		{"ext without type", `{"$ext": {"data": ""}}`, ErrExtendedJSON},
+		{"map entry", `{"$map": [[1]]}`, ErrExtendedJSON},
+		{"float", `{"$f64": "NaN"}`, ErrExtendedJSON},
+		{"big int", `18446744073709551616`, ErrBigNumber},
+		{"trailing", `1 2`, ErrExtendedJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromExtendedJSON([]byte(tt.json))
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+
+	_, err := FromExtendedJSON([]byte(`[1,`))
+	assert.Error(t, err)
+	_, err = ToExtendedJSON([]byte{0x01, 0x02})
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+	_, err = ToExtendedJSON([]byte{0x92, 0x01})
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+}
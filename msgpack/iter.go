@@ -0,0 +1,97 @@
+package msgpack
+
+import (
+	"fmt"
+	"iter"
+)
+
+// Elements iterates over the raw encoded elements of the array in data
+// without decoding them. It stops early when data is not an array or is
+// malformed; use an Iterator to learn why.
+func Elements(data []byte) iter.Seq2[int, []byte] {
+	return NewIterator(data).Elements()
+}
+
+// Entries iterates over the raw encoded keys and values of the map in data
+// in wire order. It stops early when data is not a map or is malformed; use
+// an Iterator to learn why.
+func Entries(data []byte) iter.Seq2[[]byte, []byte] {
+	return NewIterator(data).Entries()
+}
+
+// An Iterator scans an encoded array or map and records the first error,
+// which is reported by Err once the loop is over:
+//
+//	it := msgpack.NewIterator(data)
+//	for i, elem := range it.Elements() {
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// The yielded slices alias data.
+type Iterator struct {
+	data []byte
+	err  error
+}
+
+func NewIterator(data []byte) *Iterator {
+	return &Iterator{data: data}
+}
+
+// Err returns the error that stopped the last iteration, or nil.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+func (it *Iterator) Elements() iter.Seq2[int, []byte] {
+	return func(yield func(int, []byte) bool) {
+		l, rest, err := ReadArrayHeader(it.data)
+		if it.err = err; err != nil {
+			return
+		}
+		for i := range l {
+			var elem []byte
+			if elem, rest, it.err = split(rest); it.err != nil || !yield(i, elem) {
+				return
+			}
+		}
+		it.err = trailing(rest)
+	}
+}
+
+func (it *Iterator) Entries() iter.Seq2[[]byte, []byte] {
+	return func(yield func([]byte, []byte) bool) {
+		l, rest, err := ReadMapHeader(it.data)
+		if it.err = err; err != nil {
+			return
+		}
+		for range l {
+			var key, value []byte
+			if key, rest, it.err = split(rest); it.err != nil {
+				return
+			}
+			if value, rest, it.err = split(rest); it.err != nil || !yield(key, value) {
+				return
+			}
+		}
+		it.err = trailing(rest)
+	}
+}
+
+// split 將 b 切為第一個值與其後的 bytes
+func split(b []byte) ([]byte, []byte, error) {
+	n, err := skip(b)
+	if err != nil {
+		return nil, b, err
+	}
+	return b[:n:n], b[n:], nil
+}
+
+func trailing(rest []byte) error {
+	if len(rest) != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidMsgPack, len(rest))
+	}
+	return nil
+}
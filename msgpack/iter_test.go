@@ -0,0 +1,67 @@
+package msgpack_test
+
+import (
+	. "msgpackconv/msgpack"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElements(t *testing.T) {
+	data := []byte{0x93, 0x01, 0xa1, 0x61, 0x91, 0xc0}
+	got := [][]byte{}
+	for i, elem := range Elements(data) {
+		assert.Equal(t, len(got), i)
+		got = append(got, elem)
+	}
+	assert.Equal(t, [][]byte{{0x01}, {0xa1, 0x61}, {0x91, 0xc0}}, got)
+
+	// 提早離開迴圈不是錯誤
+	it := NewIterator(data)
+	for range it.Elements() {
+		break
+	}
+	assert.NoError(t, it.Err())
+}
+
+func TestEntries(t *testing.T) {
+	data := []byte{0x82, 0xa1, 0x62, 0x02, 0xa1, 0x61, 0x01}
+	keys, values := [][]byte{}, [][]byte{}
+	for k, v := range Entries(data) {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	assert.Equal(t, [][]byte{{0xa1, 0x62}, {0xa1, 0x61}}, keys)
+	assert.Equal(t, [][]byte{{0x02}, {0x01}}, values)
+}
+
+func TestIteratorErr(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		count int
+		want  error
+	}{
+		{"not an array", []byte{0x01}, 0, ErrUnmarshalType},
+		{"truncated element", []byte{0x92, 0x01, 0xa2, 0x61}, 1, ErrInvalidMsgPack},
+		{"trailing bytes", []byte{0x91, 0x01, 0x02}, 1, ErrInvalidMsgPack},
+		{"empty", []byte{}, 0, ErrInvalidMsgPack},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := NewIterator(tt.data)
+			count := 0
+			for range it.Elements() {
+				count++
+			}
+			assert.Equal(t, tt.count, count)
+			assert.ErrorIs(t, it.Err(), tt.want)
+		})
+	}
+
+	it := NewIterator([]byte{0x81, 0xa1, 0x61})
+	for range it.Entries() {
+		t.Fatal("yielded an incomplete entry")
+	}
+	assert.ErrorIs(t, it.Err(), ErrInvalidMsgPack)
+}
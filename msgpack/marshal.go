@@ -0,0 +1,371 @@
+package msgpack
+
+import (
+	"bytes"
+	"cmp"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Marshaler is implemented by types that encode themselves into valid
+// message pack.
+type Marshaler interface {
+	MarshalMsgpack() ([]byte, error)
+}
+
+// Marshal returns the message pack encoding of v.
+//
+// Integers are written with the smallest int or uint format, []byte as bin,
+// maps with their keys sorted and structs as maps keyed by field name. The
+// field name can be changed with a `msgpack:"name"` tag; "omitempty" skips
+// empty fields and "-" skips the field entirely. A struct with a field
+// named _msgpack tagged `msgpack:",asarray"` is written as an array of its
+// fields in declaration order instead.
+//
+// At any depth, a value implementing Marshaler is encoded by its
+// MarshalMsgpack method. Otherwise an encoding.BinaryMarshaler is written as
+// bin and an encoding.TextMarshaler as str.
+func Marshal(v interface{}) ([]byte, error) {
+	var e encodeState
+	return e.appendReflect(nil, reflect.ValueOf(v))
+}
+
+// An Encoder writes message pack values to an output stream.
+type Encoder struct {
+	w io.Writer
+	encodeState
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetStructAsArray makes the encoder write every struct as an array of its
+// fields, as if each had the asarray tag.
+func (enc *Encoder) SetStructAsArray(on bool) {
+	enc.structAsArray = on
+}
+
+// SetLosslessFloat32 makes the encoder write a float64 as float32 when
+// converting it to float32 and back gives the same value.
+func (enc *Encoder) SetLosslessFloat32(on bool) {
+	enc.losslessFloat32 = on
+}
+
+// Encode writes the message pack encoding of v to the stream.
+func (enc *Encoder) Encode(v interface{}) error {
+	b, err := enc.appendReflect(nil, reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	_, err = enc.w.Write(b)
+	return err
+}
+
+// encodeState 保存編碼時的設定
+type encodeState struct {
+	structAsArray   bool
+	losslessFloat32 bool
+}
+
+// encoderFunc 將 rv 的編碼加到 ans 之後，rv 的類型固定為編譯時的類型
+type encoderFunc func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error)
+
+func (e *encodeState) appendReflect(ans []byte, rv reflect.Value) ([]byte, error) {
+	if !rv.IsValid() {
+		return append(ans, FirstByte["nil"]), nil
+	}
+	return cachedCodec(rv.Type()).encode(e, ans, rv)
+}
+
+var (
+	marshalerType       = reflect.TypeFor[Marshaler]()
+	binaryMarshalerType = reflect.TypeFor[encoding.BinaryMarshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// mayImplement 回傳 t 或 *t 是否實作 interface u
+func mayImplement(t, u reflect.Type) bool {
+	return t.Implements(u) || t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(u)
+}
+
+func newEncoder(t reflect.Type) encoderFunc {
+	enc := kindEncoder(t)
+	if t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface {
+		elem := enc
+		enc = func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			if rv.IsNil() {
+				return append(ans, FirstByte["nil"]), nil
+			}
+			return elem(e, ans, rv)
+		}
+	}
+	if !mayImplement(t, marshalerType) && !mayImplement(t, binaryMarshalerType) && !mayImplement(t, textMarshalerType) {
+		return enc
+	}
+
+	// 是否實作 interface 可能取決於 rv 是否 addressable，因此在編碼時判斷
+	return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+		if (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && rv.IsNil() {
+			return append(ans, FirstByte["nil"]), nil
+		}
+		if m, ok := implementer[Marshaler](rv); ok {
+			b, err := m.MarshalMsgpack()
+			if err != nil {
+				return nil, err
+			}
+			if n, err := skip(b); err != nil || n != len(b) {
+				return nil, fmt.Errorf("%w: MarshalMsgpack of %s", ErrInvalidMsgPack, rv.Type())
+			}
+			return append(ans, b...), nil
+		}
+		if m, ok := implementer[encoding.BinaryMarshaler](rv); ok {
+			b, err := m.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			return append(ans, getBinFormat(b)...), nil
+		}
+		if m, ok := implementer[encoding.TextMarshaler](rv); ok {
+			b, err := m.MarshalText()
+			if err != nil {
+				return nil, err
+			}
+			return append(ans, getStrFormat(string(b))...), nil
+		}
+		return enc(e, ans, rv)
+	}
+}
+
+func kindEncoder(t reflect.Type) encoderFunc {
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			return append(ans, getBoolFormat(rv.Bool())), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			return AppendInt(ans, rv.Int()), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			return append(ans, getPositiveIntFormat(rv.Uint())...), nil
+		}
+	case reflect.Float32:
+		return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			return append(ans, getFloat32Format(float32(rv.Float()))...), nil
+		}
+	case reflect.Float64:
+		return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			f := rv.Float()
+			if e.losslessFloat32 && float64(float32(f)) == f {
+				return append(ans, getFloat32Format(float32(f))...), nil
+			}
+			return append(ans, getFloatFormat(f)...), nil
+		}
+	case reflect.String:
+		return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			return append(ans, getStrFormat(rv.String())...), nil
+		}
+	case reflect.Pointer:
+		elem := cachedCodec(t.Elem())
+		return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			return elem.encode(e, ans, rv.Elem())
+		}
+	case reflect.Interface:
+		// 實際的類型在編碼時才知道
+		return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			return e.appendReflect(ans, rv.Elem())
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+				if rv.IsNil() {
+					return append(ans, FirstByte["nil"]), nil
+				}
+				return append(ans, getBinFormat(rv.Bytes())...), nil
+			}
+		}
+		enc := arrayEncoder(t)
+		return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+			if rv.IsNil() {
+				return append(ans, FirstByte["nil"]), nil
+			}
+			return enc(e, ans, rv)
+		}
+	case reflect.Array:
+		return arrayEncoder(t)
+	case reflect.Map:
+		return mapEncoder(t)
+	case reflect.Struct:
+		return structEncoder(t)
+	}
+	return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, rv.Type())
+	}
+}
+
+func arrayEncoder(t reflect.Type) encoderFunc {
+	elem := cachedCodec(t.Elem())
+	return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+		var err error
+		ans = append(ans, getArrayFormat(rv.Len())...)
+		for i := range rv.Len() {
+			if ans, err = elem.encode(e, ans, rv.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return ans, nil
+	}
+}
+
+func mapEncoder(t reflect.Type) encoderFunc {
+	key, elem := cachedCodec(t.Key()), cachedCodec(t.Elem())
+	strKeys := t.Key().Kind() == reflect.String
+	type entry struct {
+		key   reflect.Value
+		value reflect.Value
+		// 已編碼的 key，用來排序非 string 的 key
+		encoded []byte
+	}
+	return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+		if rv.IsNil() {
+			return append(ans, FirstByte["nil"]), nil
+		}
+		entries := make([]entry, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			encoded, err := key.encode(e, nil, iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{iter.Key(), iter.Value(), encoded})
+		}
+		// 排序讓同一個 map 的編碼結果固定
+		if strKeys {
+			slices.SortFunc(entries, func(a, b entry) int {
+				return cmp.Compare(a.key.String(), b.key.String())
+			})
+		} else {
+			slices.SortFunc(entries, func(a, b entry) int {
+				return bytes.Compare(a.encoded, b.encoded)
+			})
+		}
+
+		var err error
+		ans = append(ans, getMapFormat(len(entries))...)
+		for _, entry := range entries {
+			ans = append(ans, entry.encoded...)
+			if ans, err = elem.encode(e, ans, entry.value); err != nil {
+				return nil, err
+			}
+		}
+		return ans, nil
+	}
+}
+
+func structEncoder(t reflect.Type) encoderFunc {
+	info := cachedStruct(t)
+	codecs := make([]*codec, len(info.fields))
+	for i, f := range info.fields {
+		codecs[i] = cachedCodec(t.Field(f.index).Type)
+	}
+	return func(e *encodeState, ans []byte, rv reflect.Value) ([]byte, error) {
+		var err error
+		if info.asArray || e.structAsArray {
+			ans = append(ans, getArrayFormat(len(info.fields))...)
+			for i, f := range info.fields {
+				if ans, err = codecs[i].encode(e, ans, rv.Field(f.index)); err != nil {
+					return nil, err
+				}
+			}
+			return ans, nil
+		}
+
+		l := len(info.fields)
+		for _, f := range info.fields {
+			if f.omitEmpty && rv.Field(f.index).IsZero() {
+				l--
+			}
+		}
+		ans = append(ans, getMapFormat(l)...)
+		for i, f := range info.fields {
+			fv := rv.Field(f.index)
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			ans = append(ans, getStrFormat(f.name)...)
+			if ans, err = codecs[i].encode(e, ans, fv); err != nil {
+				return nil, err
+			}
+		}
+		return ans, nil
+	}
+}
+
+// implementer 回傳 rv 或 rv 的指標所實作的 interface T
+func implementer[T any](rv reflect.Value) (T, bool) {
+	var zero T
+	t := reflect.TypeFor[T]()
+	if !rv.CanInterface() {
+		return zero, false
+	}
+	if rv.Type().Implements(t) {
+		return rv.Interface().(T), true
+	}
+	if rv.Kind() != reflect.Pointer && rv.CanAddr() && reflect.PointerTo(rv.Type()).Implements(t) {
+		return rv.Addr().Interface().(T), true
+	}
+	return zero, false
+}
+
+// field 為 struct 中會被編碼的欄位
+type field struct {
+	name      string
+	index     int
+	omitEmpty bool
+}
+
+// structInfo 為 struct 的編碼方式
+type structInfo struct {
+	fields []field
+	// 以 array 編碼，欄位依宣告順序排列
+	asArray bool
+}
+
+var structCache sync.Map // map[reflect.Type]*structInfo
+
+func cachedStruct(t reflect.Type) *structInfo {
+	if info, ok := structCache.Load(t); ok {
+		return info.(*structInfo)
+	}
+	info := &structInfo{fields: []field{}}
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("msgpack")
+		name, opts, _ := strings.Cut(tag, ",")
+		options := strings.Split(opts, ",")
+		if sf.Name == "_msgpack" {
+			info.asArray = slices.Contains(options, "asarray")
+			continue
+		}
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		info.fields = append(info.fields, field{
+			name:      name,
+			index:     i,
+			omitEmpty: slices.Contains(options, "omitempty"),
+		})
+	}
+	actual, _ := structCache.LoadOrStore(t, info)
+	return actual.(*structInfo)
+}
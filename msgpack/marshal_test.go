@@ -0,0 +1,319 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"io"
+	. "msgpackconv/msgpack"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type point struct {
+	X     int     `msgpack:"x"`
+	Y     float64 `msgpack:"y,omitempty"`
+	Label string
+	skip  bool
+	Skip  bool `msgpack:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	type args struct {
+		v interface{}
+	}
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{"nil", args{nil}, []byte{0xc0}},
+		{"int", args{-33}, []byte{0xd0, 0xdf}},
+		{"positive int8", args{int8(5)}, []byte{0x05}},
+		{"uint16", args{uint16(256)}, []byte{0xcd, 0x01, 0x00}},
+		{"float32", args{float32(1.5)}, []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}},
+		{"integral float64", args{1.0}, []byte{0xcb, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"bytes", args{[]byte{0x01, 0x02}}, []byte{0xc4, 0x02, 0x01, 0x02}},
+		{"nil slice", args{[]string(nil)}, []byte{0xc0}},
+		{"array", args{[2]bool{true, false}}, []byte{0x92, 0xc3, 0xc2}},
+		{"sorted map", args{map[string]int{"b": 2, "a": 1}}, []byte{0x82, 0xa1, 0x61, 0x01, 0xa1, 0x62, 0x02}},
+		{"int key map", args{map[int]string{-1: "", 1: ""}}, []byte{0x82, 0x01, 0xa0, 0xff, 0xa0}},
+		{
+			"struct",
+			args{point{X: 1, Label: "p", skip: true, Skip: true}},
+			[]byte{0x82, 0xa1, 0x78, 0x01, 0xa5, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0xa1, 0x70},
+		},
+		{"pointer", args{&point{X: 1, Y: 0.5}}, []byte{0x83, 0xa1, 0x78, 0x01, 0xa1, 0x79, 0xcb, 0x3f, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xa5, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0xa0}},
+		{"value", args{Array(Str("a"))}, []byte{0x91, 0xa1, 0x61}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.args.v)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := Marshal(make(chan int))
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
+
+func TestUnmarshal(t *testing.T) {
+	var p point
+	assert.NoError(t, Unmarshal(FromJSON([]byte(`{"x": 3, "y": 2, "label": "a", "other": [1, {"z": null}]}`)), &p))
+	assert.Equal(t, point{X: 3, Y: 2, Label: "a"}, p)
+
+	var m map[string][]int16
+	assert.NoError(t, Unmarshal(FromJSON([]byte(`{"a": [1, -300], "b": null}`)), &m))
+	assert.Equal(t, map[string][]int16{"a": {1, -300}, "b": nil}, m)
+
+	var arr [3]uint8
+	assert.NoError(t, Unmarshal([]byte{0x94, 0x01, 0x02, 0x03, 0x04}, &arr))
+	assert.Equal(t, [3]uint8{1, 2, 3}, arr)
+
+	var ptr *string
+	assert.NoError(t, Unmarshal([]byte{0xa1, 0x61}, &ptr))
+	assert.Equal(t, "a", *ptr)
+
+	var b []byte
+	assert.NoError(t, Unmarshal([]byte{0xc4, 0x01, 0xff}, &b))
+	assert.Equal(t, []byte{0xff}, b)
+
+	var out interface{}
+	assert.NoError(t, Unmarshal([]byte{0x82, 0xa1, 0x61, 0x92, 0xff, 0x01, 0xa1, 0x62, 0xca, 0x3f, 0xc0, 0x00, 0x00}, &out))
+	assert.Equal(t, map[string]interface{}{"a": []interface{}{int64(-1), uint64(1)}, "b": float32(1.5)}, out)
+	assert.NoError(t, Unmarshal([]byte{0x81, 0x01, 0xc3}, &out))
+	assert.Equal(t, map[interface{}]interface{}{uint64(1): true}, out)
+}
+
+func TestUnmarshalFail(t *testing.T) {
+	var i int8
+	assert.ErrorIs(t, Unmarshal([]byte{0xcc, 0xff}, &i), ErrUnmarshalType)
+	var s string
+	assert.ErrorIs(t, Unmarshal([]byte{0x01}, &s), ErrUnmarshalType)
+	assert.ErrorIs(t, Unmarshal([]byte{0x01}, s), ErrInvalidUnmarshal)
+	assert.ErrorIs(t, Unmarshal([]byte{0x01, 0x02}, &i), ErrInvalidMsgPack)
+	assert.ErrorIs(t, Unmarshal([]byte{0x92, 0x01}, &[]int{}), ErrInvalidMsgPack)
+	// {[1]: true} 的 key 無法存入 Go map
+	var m map[interface{}]interface{}
+	err := Unmarshal([]byte{0x81, 0x91, 0x01, 0xc3}, &m)
+	assert.ErrorIs(t, err, ErrUnmarshalType)
+	assert.EqualError(t, err, "cannot unmarshal message pack value: slice map key into map[interface {}]interface {}")
+}
+
+// money 以 [units, cents] 的 array 編碼
+type money struct {
+	units int64
+	cents int8
+}
+
+func (m money) MarshalMsgpack() ([]byte, error) {
+	return Marshal([]int64{m.units, int64(m.cents)})
+}
+
+func (m *money) UnmarshalMsgpack(msgpackconv []byte) error {
+	var parts [2]int64
+	if err := Unmarshal(msgpackconv, &parts); err != nil {
+		return err
+	}
+	m.units, m.cents = parts[0], int8(parts[1])
+	return nil
+}
+
+// level 只實作 encoding.TextMarshaler
+type level int
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte([]string{"low", "high"}[l]), nil
+}
+
+func (l *level) UnmarshalText(text []byte) error {
+	if string(text) == "high" {
+		*l = 1
+	} else {
+		*l = 0
+	}
+	return nil
+}
+
+// id 同時實作 encoding.BinaryMarshaler 與 encoding.TextMarshaler，bin 優先
+type id [2]byte
+
+func (i id) MarshalBinary() ([]byte, error) {
+	return i[:], nil
+}
+
+func (i *id) UnmarshalBinary(data []byte) error {
+	copy(i[:], data)
+	return nil
+}
+
+func (i id) MarshalText() ([]byte, error) {
+	return []byte("unused"), nil
+}
+
+type order struct {
+	ID     id
+	Price  money
+	Prices []money
+	Tip    *money
+	Level  level
+	Levels map[level]bool
+}
+
+func TestMarshaler(t *testing.T) {
+	o := order{
+		ID:     id{0xab, 0xcd},
+		Price:  money{1, 50},
+		Prices: []money{{2, 0}},
+		Tip:    &money{0, 5},
+		Level:  1,
+		Levels: map[level]bool{0: true},
+	}
+	got, err := Marshal(o)
+	assert.NoError(t, err)
+
+	v, err := DecodeValue(got)
+	assert.NoError(t, err)
+	assert.Equal(t, Bin([]byte{0xab, 0xcd}), v.Get("ID"))
+	assert.Equal(t, Array(Uint(1), Uint(50)), v.Get("Price"))
+	assert.Equal(t, Array(Array(Uint(2), Uint(0))), v.Get("Prices"))
+	assert.Equal(t, Array(Uint(0), Uint(5)), v.Get("Tip"))
+	assert.Equal(t, Str("high"), v.Get("Level"))
+	assert.Equal(t, Map(Pair("low", Bool(true))), v.Get("Levels"))
+
+	var decoded order
+	assert.NoError(t, Unmarshal(got, &decoded))
+	assert.Equal(t, o, decoded)
+}
+
+type badMarshaler struct{}
+
+func (badMarshaler) MarshalMsgpack() ([]byte, error) {
+	return []byte{0x92}, nil
+}
+
+func TestMarshalerFail(t *testing.T) {
+	_, err := Marshal([]badMarshaler{{}})
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+
+	var m money
+	assert.ErrorIs(t, Unmarshal([]byte{0xa1, 0x61}, &m), ErrUnmarshalType)
+}
+
+type vector struct {
+	_msgpack struct{} `msgpack:",asarray"`
+	X        int8
+	Y        int8
+	Name     string `msgpack:"name,omitempty"`
+}
+
+func TestMarshalStructAsArray(t *testing.T) {
+	got, err := Marshal(vector{X: 1, Y: -1})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x93, 0x01, 0xff, 0xa0}, got)
+
+	var v vector
+	assert.NoError(t, Unmarshal(got, &v))
+	assert.Equal(t, vector{X: 1, Y: -1}, v)
+
+	// 沒有 asarray tag 的 struct 也接受 array
+	var p point
+	assert.NoError(t, Unmarshal([]byte{0x93, 0x02, 0xcb, 0x3f, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xa1, 0x61}, &p))
+	assert.Equal(t, point{X: 2, Y: 0.5, Label: "a"}, p)
+
+	// asarray 的 struct 也接受 map
+	assert.NoError(t, Unmarshal(FromJSON([]byte(`{"X": 3}`)), &v))
+	assert.Equal(t, int8(3), v.X)
+}
+
+func TestEncoderStructAsArray(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetStructAsArray(true)
+	assert.NoError(t, enc.Encode([]point{{X: 1, Label: "a"}}))
+	assert.NoError(t, enc.Encode(map[string]point{"p": {}}))
+	assert.Equal(t, []byte{
+		0x91, 0x93, 0x01, 0xcb, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xa1, 0x61,
+		0x81, 0xa1, 0x70, 0x93, 0x00, 0xcb, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xa0,
+	}, buf.Bytes())
+}
+
+func TestUnmarshalStructArrayLength(t *testing.T) {
+	var v vector
+	err := Unmarshal([]byte{0x92, 0x01, 0x02}, &v)
+	assert.ErrorIs(t, err, ErrArrayLength)
+	assert.EqualError(t, err, "array length does not match struct fields: array of 2 elements into msgpack_test.vector with 3 fields")
+}
+
+func TestDecoderDuplicateKeys(t *testing.T) {
+	// {"x": 1, "x": 2}
+	msg := []byte{0x82, 0xa1, 0x78, 0x01, 0xa1, 0x78, 0x02}
+	tests := []struct {
+		name   string
+		policy DuplicateKeyPolicy
+		want   int
+	}{
+		{"default", DuplicateKeyPolicy(0), 2},
+		{"last", DuplicateKeysLast, 2},
+		{"first", DuplicateKeysFirst, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDecoder(bytes.NewReader(bytes.Repeat(msg, 3)))
+			dec.SetDuplicateKeys(tt.policy)
+			var m map[string]int
+			assert.NoError(t, dec.Decode(&m))
+			assert.Equal(t, map[string]int{"x": tt.want}, m)
+			var p point
+			assert.NoError(t, dec.Decode(&p))
+			assert.Equal(t, tt.want, p.X)
+			var v interface{}
+			assert.NoError(t, dec.Decode(&v))
+			assert.Equal(t, map[string]interface{}{"x": uint64(tt.want)}, v)
+			assert.Equal(t, io.EOF, dec.Decode(&v))
+		})
+	}
+
+	dec := NewDecoder(bytes.NewReader(msg))
+	dec.SetDuplicateKeys(DuplicateKeysError)
+	var m map[string]int
+	assert.ErrorIs(t, dec.Decode(&m), ErrDuplicateKey)
+	// map[interface{}]interface{} 的 key
+	dec = NewDecoder(bytes.NewReader([]byte{0x82, 0x01, 0xc0, 0x01, 0xc3}))
+	dec.SetDuplicateKeys(DuplicateKeysError)
+	var v interface{}
+	assert.ErrorIs(t, dec.Decode(&v), ErrDuplicateKey)
+}
+
+func TestUnmarshalWithOptionsDuplicateKeys(t *testing.T) {
+	// {"x": 1, "x": 2}
+	msg := []byte{0x82, 0xa1, 0x78, 0x01, 0xa1, 0x78, 0x02}
+	var m map[string]int
+	assert.NoError(t, Unmarshal(msg, &m))
+	assert.Equal(t, map[string]int{"x": 2}, m)
+	var p point
+	assert.NoError(t, UnmarshalWithOptions(msg, &p, UnmarshalOptions{DuplicateKeys: DuplicateKeysFirst}))
+	assert.Equal(t, 1, p.X)
+	var v interface{}
+	err := UnmarshalWithOptions(msg, &v, UnmarshalOptions{DuplicateKeys: DuplicateKeysError})
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+}
+
+func TestDecoderPartialStream(t *testing.T) {
+	// 值比最長的 header 短時，不等待之後的 byte
+	r, w := io.Pipe()
+	defer w.Close()
+	go w.Write([]byte{0x92, 0x01, 0xc3})
+	dec := NewDecoder(r)
+	var v interface{}
+	assert.NoError(t, dec.Decode(&v))
+	assert.Equal(t, []interface{}{uint64(1), true}, v)
+}
+
+func TestDecoderFail(t *testing.T) {
+	// 不完整的 array
+	dec := NewDecoder(bytes.NewReader([]byte{0x92, 0x01}))
+	var v interface{}
+	assert.ErrorIs(t, dec.Decode(&v), io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, NewDecoder(bytes.NewReader(nil)).Decode(v), ErrInvalidUnmarshal)
+}
@@ -0,0 +1,176 @@
+package msgpackrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"msgpackconv/msgpack"
+)
+
+// Client calls methods on the other side of a connection. Its methods may
+// be called from several goroutines, and calls in flight are matched to
+// their responses by msgid. Requests and notifications sent to the client
+// are ignored.
+type Client struct {
+	conn io.ReadWriteCloser
+	w    *writer
+
+	mu      sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan response
+	// err 不為 nil 時連線已中斷
+	err  error
+	done chan struct{}
+}
+
+type response struct {
+	err    msgpack.Value
+	result msgpack.Value
+}
+
+// NewClient returns a client on conn and starts reading its responses.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	c := &Client{
+		conn:    conn,
+		w:       &writer{w: conn},
+		pending: map[uint32]chan response{},
+		done:    make(chan struct{}),
+	}
+	go c.read()
+	return c
+}
+
+func (c *Client) read() {
+	dec := msgpack.NewDecoder(c.conn)
+	for {
+		typ, items, err := readMessage(dec)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if typ != typeResponse {
+			continue
+		}
+		msgid, ok := items[0].Uint()
+		if !ok {
+			c.fail(fmt.Errorf("%w: msgid of kind %s", ErrInvalidMessage, items[0].Kind()))
+			return
+		}
+		// 已取消的 call 沒有等待中的 channel，超過 uint32 的 msgid 也不會有
+		c.mu.Lock()
+		ch, ok := c.pending[uint32(msgid)]
+		ok = ok && msgid <= math.MaxUint32
+		if ok {
+			delete(c.pending, uint32(msgid))
+		}
+		c.mu.Unlock()
+		if ok {
+			ch <- response{err: items[1], result: items[2]}
+		}
+	}
+}
+
+// fail 記錄連線中斷的原因，讓等待中與之後的 call 回傳 ErrClosed
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if err == io.EOF || errors.Is(err, ErrClosed) {
+		c.err = ErrClosed
+	} else {
+		c.err = fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	close(c.done)
+}
+
+// Call sends a request for method with params, each encoded with
+// msgpack.Marshal, and waits for its response. The result is decoded into
+// result with msgpack.Unmarshal unless result is nil; a *msgpack.Value
+// keeps it as is. An error response is returned as an *Error. When ctx is
+// done first, Call returns its error and the response is discarded.
+func (c *Client) Call(ctx context.Context, result any, method string, params ...any) error {
+	if params == nil {
+		params = []any{}
+	}
+	ch := make(chan response, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	msgid := c.nextID
+	c.nextID++
+	c.pending[msgid] = ch
+	c.mu.Unlock()
+	cancel := func() {
+		c.mu.Lock()
+		delete(c.pending, msgid)
+		c.mu.Unlock()
+	}
+
+	b, err := encode(typeRequest, msgid, method, params)
+	if err != nil {
+		cancel()
+		return err
+	}
+	if err := c.w.write(b); err != nil {
+		cancel()
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+
+	select {
+	case resp := <-ch:
+		if !resp.err.IsNil() {
+			return &Error{Value: resp.err}
+		}
+		if result == nil {
+			return nil
+		}
+		b, err := resp.result.MarshalMsgpack()
+		if err != nil {
+			return err
+		}
+		return msgpack.Unmarshal(b, result)
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	case <-c.done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err
+	}
+}
+
+// Notify sends a notification for method with params, each encoded with
+// msgpack.Marshal, without waiting for the other side.
+func (c *Client) Notify(method string, params ...any) error {
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if params == nil {
+		params = []any{}
+	}
+	b, err := encode(typeNotification, method, params)
+	if err != nil {
+		return err
+	}
+	if err := c.w.write(b); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	return nil
+}
+
+// Close closes the connection; calls in flight return ErrClosed.
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	return c.conn.Close()
+}
@@ -0,0 +1,110 @@
+// Package msgpackrpc implements MessagePack-RPC over any
+// io.ReadWriteCloser. A request is [0, msgid, method, params], a response
+// [1, msgid, error, result] and a notification [2, method, params]; the
+// client matches responses to concurrent calls by msgid.
+package msgpackrpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"msgpackconv/msgpack"
+)
+
+var (
+	ErrInvalidMessage = errors.New("invalid MessagePack-RPC message")
+	ErrClosed         = errors.New("connection closed")
+)
+
+// message types
+const (
+	typeRequest      = 0
+	typeResponse     = 1
+	typeNotification = 2
+)
+
+// Error is the error of a response, as sent by the other side. Handlers
+// return an *Error to send a value other than the str of their error.
+type Error struct {
+	Value msgpack.Value
+}
+
+func (e *Error) Error() string {
+	if s, ok := e.Value.Str(); ok {
+		return s
+	}
+	b, err := e.Value.MarshalMsgpack()
+	if err != nil {
+		return fmt.Sprintf("error of kind %s", e.Value.Kind())
+	}
+	diag, err := msgpack.ToDiagnostic(b)
+	if err != nil {
+		return fmt.Sprintf("error of kind %s", e.Value.Kind())
+	}
+	return diag
+}
+
+// writer 讓多個 goroutine 寫入完整的 message
+type writer struct {
+	sync.Mutex
+	w io.Writer
+}
+
+func (w *writer) write(message []byte) error {
+	w.Lock()
+	defer w.Unlock()
+	_, err := w.w.Write(message)
+	return err
+}
+
+func encode(message ...any) ([]byte, error) {
+	return msgpack.Marshal(message)
+}
+
+// readMessage 讀取下一個 message，回傳其類型與其餘的元素
+func readMessage(dec *msgpack.Decoder) (int64, []msgpack.Value, error) {
+	var v msgpack.Value
+	if err := dec.Decode(&v); err != nil {
+		if errors.Is(err, msgpack.ErrInvalidMsgPack) {
+			return 0, nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+		return 0, nil, err
+	}
+	if v.Kind() != msgpack.ArrayKind || v.Len() == 0 {
+		return 0, nil, fmt.Errorf("%w: message is not an array with a type", ErrInvalidMessage)
+	}
+	items := make([]msgpack.Value, 0, v.Len())
+	for _, item := range v.Elements() {
+		items = append(items, item)
+	}
+	typ, ok := items[0].Int()
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: message type of kind %s", ErrInvalidMessage, items[0].Kind())
+	}
+	want := 4
+	switch typ {
+	case typeRequest, typeResponse:
+	case typeNotification:
+		want = 3
+	default:
+		return 0, nil, fmt.Errorf("%w: message type %d", ErrInvalidMessage, typ)
+	}
+	if len(items) != want {
+		return 0, nil, fmt.Errorf("%w: message of type %d has %d elements", ErrInvalidMessage, typ, len(items))
+	}
+	return typ, items[1:], nil
+}
+
+// params 回傳 message 中 params 的元素
+func params(v msgpack.Value) ([]msgpack.Value, error) {
+	if v.Kind() != msgpack.ArrayKind {
+		return nil, fmt.Errorf("%w: params of kind %s", ErrInvalidMessage, v.Kind())
+	}
+	ans := make([]msgpack.Value, 0, v.Len())
+	for _, item := range v.Elements() {
+		ans = append(ans, item)
+	}
+	return ans, nil
+}
@@ -0,0 +1,203 @@
+package msgpackrpc_test
+
+import (
+	"context"
+	"errors"
+	"msgpackconv/msgpack"
+	. "msgpackconv/msgpack/msgpackrpc"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pipe 回傳連到 server 的 client，以及 ServeConn 的結果
+func pipe(t *testing.T, s *Server) (*Client, <-chan error) {
+	clientConn, serverConn := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- s.ServeConn(serverConn) }()
+	c := NewClient(clientConn)
+	t.Cleanup(func() { c.Close() })
+	return c, done
+}
+
+func sum(params []msgpack.Value) (any, error) {
+	total := int64(0)
+	for _, p := range params {
+		i, ok := p.Int()
+		if !ok {
+			return nil, errors.New("params must be ints")
+		}
+		total += i
+	}
+	return total, nil
+}
+
+func TestCall(t *testing.T) {
+	s := NewServer()
+	s.Register("sum", sum)
+	s.Register("echo", func(params []msgpack.Value) (any, error) {
+		return params, nil
+	})
+	s.Register("fail", func(params []msgpack.Value) (any, error) {
+		return nil, &Error{Value: msgpack.Map(msgpack.Pair("code", msgpack.Int(42)))}
+	})
+	c, _ := pipe(t, s)
+	ctx := context.Background()
+
+	var n int
+	assert.NoError(t, c.Call(ctx, &n, "sum", 1, 2, 3))
+	assert.Equal(t, 6, n)
+	assert.NoError(t, c.Call(ctx, nil, "sum"))
+
+	var v msgpack.Value
+	assert.NoError(t, c.Call(ctx, &v, "echo", "a", []byte{1}, nil, msgpack.Uint(7)))
+	b, err := v.MarshalMsgpack()
+	assert.NoError(t, err)
+	diag, err := msgpack.ToDiagnostic(b)
+	assert.NoError(t, err)
+	assert.Equal(t, `["a", h'01', nil, 7]`, diag)
+
+	err = c.Call(ctx, &n, "sum", "x")
+	var rpcErr *Error
+	assert.ErrorAs(t, err, &rpcErr)
+	assert.EqualError(t, err, "params must be ints")
+
+	err = c.Call(ctx, nil, "fail")
+	assert.ErrorAs(t, err, &rpcErr)
+	code, _ := rpcErr.Value.Get("code").Int()
+	assert.Equal(t, int64(42), code)
+	assert.EqualError(t, err, `{"code": 42}`)
+
+	assert.EqualError(t, c.Call(ctx, nil, "missing"), `unknown method "missing"`)
+	assert.Error(t, c.Call(ctx, nil, "sum", make(chan int)))
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	// 每個 request 等到下一個 request 回應後才回應，response 的順序與
+	// request 相反
+	const calls = 5
+	release := make([]chan struct{}, calls+1)
+	for i := range release {
+		release[i] = make(chan struct{})
+	}
+	close(release[calls])
+	s := NewServer()
+	s.Register("wait", func(params []msgpack.Value) (any, error) {
+		i, _ := params[0].Int()
+		<-release[i+1]
+		defer close(release[i])
+		return i * 10, nil
+	})
+	c, _ := pipe(t, s)
+
+	var wg sync.WaitGroup
+	results := make([]int, calls)
+	for i := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, c.Call(context.Background(), &results[i], "wait", i))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []int{0, 10, 20, 30, 40}, results)
+}
+
+func TestNotify(t *testing.T) {
+	got := make(chan []msgpack.Value, 1)
+	s := NewServer()
+	s.Register("log", func(params []msgpack.Value) (any, error) {
+		got <- params
+		return nil, errors.New("ignored")
+	})
+	c, _ := pipe(t, s)
+
+	assert.NoError(t, c.Notify("unknown", 1))
+	assert.NoError(t, c.Notify("log", "hello"))
+	params := <-got
+	assert.Len(t, params, 1)
+	msg, _ := params[0].Str()
+	assert.Equal(t, "hello", msg)
+}
+
+func TestCancelAndClose(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	s := NewServer()
+	s.Register("block", func(params []msgpack.Value) (any, error) {
+		<-block
+		return nil, nil
+	})
+	s.Register("sum", sum)
+	c, _ := pipe(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.Call(ctx, nil, "block"), context.DeadlineExceeded)
+	// 取消的 call 不影響之後的 call
+	var n int
+	assert.NoError(t, c.Call(context.Background(), &n, "sum", 2))
+	assert.Equal(t, 2, n)
+
+	errs := make(chan error)
+	go func() { errs <- c.Call(context.Background(), nil, "block") }()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, c.Close())
+	assert.ErrorIs(t, <-errs, ErrClosed)
+	assert.ErrorIs(t, c.Call(context.Background(), nil, "sum"), ErrClosed)
+	assert.ErrorIs(t, c.Notify("sum"), ErrClosed)
+}
+
+func TestServeConnInvalidMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"not an array", `1`},
+		{"unknown type", `[3, 1, 2, 3]`},
+		{"request length", `[0, 1, "sum"]`},
+		{"method", `[0, 1, 2, []]`},
+		{"params", `[2, "sum", 1]`},
+		{"msgid", `[0, -1, "sum", []]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			done := make(chan error, 1)
+			go func() { done <- NewServer().ServeConn(serverConn) }()
+			b, err := msgpack.FromDiagnostic(tt.message)
+			assert.NoError(t, err)
+			_, err = clientConn.Write(b)
+			assert.NoError(t, err)
+			assert.ErrorIs(t, <-done, ErrInvalidMessage)
+			clientConn.Close()
+		})
+	}
+
+	// client 關閉連線時 ServeConn 正常結束
+	c, done := pipe(t, NewServer())
+	c.Close()
+	assert.NoError(t, <-done)
+}
+
+func TestServe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	s := NewServer()
+	s.Register("sum", sum)
+	go s.Serve(l)
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	c := NewClient(conn)
+	defer c.Close()
+	var n int
+	assert.NoError(t, c.Call(context.Background(), &n, "sum", 40, 2))
+	assert.Equal(t, 42, n)
+}
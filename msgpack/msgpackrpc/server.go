@@ -0,0 +1,139 @@
+package msgpackrpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"msgpackconv/msgpack"
+)
+
+// Handler handles the requests and notifications of a method. The result
+// is encoded with msgpack.Marshal and ignored for notifications.
+type Handler func(params []msgpack.Value) (any, error)
+
+// Server dispatches requests and notifications to registered handlers.
+type Server struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewServer() *Server {
+	return &Server{handlers: map[string]Handler{}}
+}
+
+// Register makes h handle method, replacing the previous handler.
+func (s *Server) Register(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = h
+}
+
+func (s *Server) handler(method string) (Handler, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h, ok := s.handlers[method]
+	return h, ok
+}
+
+// Serve accepts connections on l and serves each in its own goroutine. It
+// returns the error of Accept.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves conn until the other side closes it, running each
+// request and notification in its own goroutine, then waits for running
+// handlers and closes conn. Requests for unknown methods get an error
+// response and such notifications are dropped, as are responses sent to
+// the server. An invalid message ends the connection with
+// ErrInvalidMessage.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) error {
+	var wg sync.WaitGroup
+	defer conn.Close()
+	defer wg.Wait()
+	dec := msgpack.NewDecoder(conn)
+	w := &writer{w: conn}
+	for {
+		typ, items, err := readMessage(dec)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case typeRequest:
+			msgid, ok := items[0].Uint()
+			if !ok {
+				return fmt.Errorf("%w: msgid of kind %s", ErrInvalidMessage, items[0].Kind())
+			}
+			method, ps, err := call(items[1], items[2])
+			if err != nil {
+				return err
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.respond(w, msgid, method, ps)
+			}()
+		case typeNotification:
+			method, ps, err := call(items[0], items[1])
+			if err != nil {
+				return err
+			}
+			if h, ok := s.handler(method); ok {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					h(ps)
+				}()
+			}
+		}
+	}
+}
+
+// call 回傳 request 或 notification 的 method 與 params
+func call(method, ps msgpack.Value) (string, []msgpack.Value, error) {
+	name, ok := method.Str()
+	if !ok {
+		return "", nil, fmt.Errorf("%w: method of kind %s", ErrInvalidMessage, method.Kind())
+	}
+	items, err := params(ps)
+	return name, items, err
+}
+
+// respond 執行 handler 並寫入 response，寫入失敗時 client 會因連線中斷而
+// 得知，這裡不另外處理
+func (s *Server) respond(w *writer, msgid uint64, method string, ps []msgpack.Value) {
+	b, err := s.response(msgid, method, ps)
+	if err != nil {
+		b, _ = encode(typeResponse, msgid, err.Error(), nil)
+	}
+	w.write(b)
+}
+
+func (s *Server) response(msgid uint64, method string, ps []msgpack.Value) ([]byte, error) {
+	h, ok := s.handler(method)
+	if !ok {
+		return nil, fmt.Errorf("unknown method %q", method)
+	}
+	result, err := h(ps)
+	var rpcErr *Error
+	switch {
+	case errors.As(err, &rpcErr):
+		return encode(typeResponse, msgid, rpcErr.Value, nil)
+	case err != nil:
+		return nil, err
+	}
+	// result 無法編碼時回傳 Marshal 的錯誤
+	return encode(typeResponse, msgid, nil, result)
+}
@@ -0,0 +1,250 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// FromJSONOptions changes how FromJSONWithOptions encodes JSON. The zero
+// value gives the formats of FromJSON.
+type FromJSONOptions struct {
+	// LosslessFloat32 writes a non-integral number as float32 when
+	// converting it to float32 and back gives the same value, such as 0.5
+	// or 1.25, instead of always using float64.
+	LosslessFloat32 bool
+	// UseNumberLiterals picks the format from the JSON number literal: a
+	// literal with a decimal point or exponent, such as 1.0 or 1e3, is
+	// written as a float and any other as an int. By default every
+	// integral value is written as an int.
+	UseNumberLiterals bool
+	// BigNumbers selects how numbers that no int64, uint64 or float64
+	// holds exactly are written. With any policy other than
+	// BigNumbersFloat, integers that fit int64 or uint64 are written as
+	// exact ints.
+	BigNumbers BigNumberPolicy
+}
+
+// FromJSONWithOptions converts a single JSON value to message pack like
+// FromJSON, but reports invalid JSON as an error and keeps object keys in
+// their input order.
+func FromJSONWithOptions(data []byte, opts FromJSONOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	sink := &singleSink{bufferedSink{dst: &buf}}
+	if err := opts.stream(opts.decoder(bytes.NewReader(data)), sink); err != nil {
+		return nil, err
+	}
+	if sink.values == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
+}
+
+// singleSink 只接受一個 JSON 值
+type singleSink struct {
+	bufferedSink
+}
+
+func (s *singleSink) open(isMap bool) error {
+	if len(s.bufs) == 0 && s.values > 0 {
+		return errMultipleJSONValues
+	}
+	return s.bufferedSink.open(isMap)
+}
+
+func (s *singleSink) value(b []byte) error {
+	if len(s.bufs) == 0 && s.values > 0 {
+		return errMultipleJSONValues
+	}
+	return s.bufferedSink.value(b)
+}
+
+var errMultipleJSONValues = errors.New("invalid JSON: more than one top-level value")
+
+func (opts FromJSONOptions) numberFormat(v float64) []byte {
+	if opts.LosslessFloat32 {
+		return getLosslessNumberFormat(v)
+	}
+	return getNumberFormat(v)
+}
+
+func (opts FromJSONOptions) decoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	if opts.UseNumberLiterals || opts.BigNumbers != BigNumbersFloat {
+		dec.UseNumber()
+	}
+	return dec
+}
+
+func (opts FromJSONOptions) numberTokenFormat(n json.Number) ([]byte, error) {
+	if b, ok, err := bigNumberFormat(n, opts.BigNumbers); ok {
+		return b, err
+	}
+	if opts.UseNumberLiterals || opts.BigNumbers != BigNumbersFloat && isIntegerLiteral(n) {
+		return opts.literalFormat(n)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return opts.numberFormat(f), nil
+}
+
+// literalFormat 依照 JSON 的數字寫法選擇 int 或 float
+func (opts FromJSONOptions) literalFormat(n json.Number) ([]byte, error) {
+	if isIntegerLiteral(n) {
+		if i, err := n.Int64(); err == nil {
+			return AppendInt(nil, i), nil
+		}
+		if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+			return AppendUint(nil, u), nil
+		}
+	}
+	// 超過 int64 與 uint64 範圍的整數也以 float 編碼
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	if opts.LosslessFloat32 && float64(float32(f)) == f {
+		return getFloat32Format(float32(f)), nil
+	}
+	return getFloatFormat(f), nil
+}
+
+// NonFinitePolicy selects how NaN and ±Inf floats, which JSON cannot
+// represent, are written.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteError fails the conversion with ErrNotJSONCompatible.
+	NonFiniteError NonFinitePolicy = iota
+	// NonFiniteNull writes null.
+	NonFiniteNull
+	// NonFiniteString writes the strings "NaN", "Infinity" and "-Infinity".
+	NonFiniteString
+	// NonFiniteJSON5 writes the JSON5 literals NaN, Infinity and -Infinity.
+	// The output is not valid JSON.
+	NonFiniteJSON5
+)
+
+// DuplicateKeyPolicy selects what happens when a map repeats a key.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeysLast keeps the value of the last entry, as a Go map
+	// does. Streamed JSON output writes every entry in wire order, which
+	// JSON readers such as encoding/json read as the last value; with
+	// SortKeys only the last value is written.
+	DuplicateKeysLast DuplicateKeyPolicy = iota
+	// DuplicateKeysError fails with ErrDuplicateKey.
+	DuplicateKeysError
+	// DuplicateKeysFirst keeps the value of the first entry and skips the
+	// others.
+	DuplicateKeysFirst
+)
+
+// keyTracker 依照 DuplicateKeyPolicy 記錄 map 中出現過的 key
+type keyTracker[K comparable] struct {
+	policy DuplicateKeyPolicy
+	seen   map[K]struct{}
+}
+
+// ignore 記錄 key，回傳是否應略過這個 entry，只有保留第一個值時略過重複
+// 的 key
+func (kt *keyTracker[K]) ignore(key K) (bool, error) {
+	// 後面的值本來就會覆蓋前面的值
+	if kt.policy == DuplicateKeysLast {
+		return false, nil
+	}
+	if kt.seen == nil {
+		kt.seen = map[K]struct{}{}
+	}
+	if _, ok := kt.seen[key]; !ok {
+		kt.seen[key] = struct{}{}
+		return false, nil
+	}
+	switch kt.policy {
+	case DuplicateKeysError:
+		return false, fmt.Errorf("%w: %v", ErrDuplicateKey, key)
+	case DuplicateKeysFirst:
+		return true, nil
+	}
+	return false, nil
+}
+
+// ToJSONOptions changes how ToJSONWithOptions writes JSON. The zero value
+// gives the output of ToJSONStream.
+type ToJSONOptions struct {
+	NonFinite NonFinitePolicy
+	// Prefix and Indent indent the output like json.MarshalIndent: when
+	// either is set, each array element and map entry begins on a new line
+	// starting with Prefix followed by one Indent per level of nesting.
+	Prefix string
+	Indent string
+	// DisableHTMLEscape writes <, > and & in strings as is instead of as
+	// \u003c, \u003e and \u0026.
+	DisableHTMLEscape bool
+	// SortKeys writes map entries sorted by key instead of in wire order.
+	// Int and uint keys are sorted by their decimal text. The entries of a
+	// map are buffered until the map ends.
+	SortKeys bool
+	// ASCII escapes every non-ASCII character in strings as \uXXXX, using
+	// a surrogate pair above U+FFFF, so the output is pure ASCII.
+	ASCII bool
+	// TrailingNewline ends the output with a newline.
+	TrailingNewline bool
+	// DuplicateKeys selects what happens when a map repeats a key. Int and
+	// uint keys are compared by their decimal text, so 1 and "1" are the
+	// same key. DuplicateKeysError and DuplicateKeysFirst keep the keys of
+	// a map, but not its values, until the map ends.
+	DuplicateKeys DuplicateKeyPolicy
+}
+
+func (opts ToJSONOptions) indented() bool {
+	return opts.Prefix != "" || opts.Indent != ""
+}
+
+// ToJSONWithOptions converts a single message pack value to JSON like
+// ToJSONStream, and reports why a value cannot be converted.
+func ToJSONWithOptions(msgpackconv []byte, opts ToJSONOptions) ([]byte, error) {
+	n, err := skip(msgpackconv)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(msgpackconv) {
+		return nil, ErrInvalidMsgPack
+	}
+	var buf bytes.Buffer
+	if err := opts.stream(&buf, bytes.NewReader(msgpackconv)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ToJSONStreamWithOptions converts message pack values from src to JSON
+// like ToJSONStream, formatted with opts.
+func ToJSONStreamWithOptions(dst io.Writer, src io.Reader, opts ToJSONOptions) error {
+	return opts.stream(dst, src)
+}
+
+func appendNonFinite(ans []byte, f float64, policy NonFinitePolicy) ([]byte, error) {
+	name := "NaN"
+	if math.IsInf(f, 1) {
+		name = "Infinity"
+	} else if math.IsInf(f, -1) {
+		name = "-Infinity"
+	}
+	switch policy {
+	case NonFiniteNull:
+		return append(ans, "null"...), nil
+	case NonFiniteString:
+		return append(append(append(ans, '"'), name...), '"'), nil
+	case NonFiniteJSON5:
+		return append(ans, name...), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotJSONCompatible, name)
+}
@@ -0,0 +1,220 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"encoding/json"
+	. "msgpackconv/msgpack"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromJSONWithOptions(t *testing.T) {
+	type args struct {
+		json string
+		opts FromJSONOptions
+	}
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{"float64 by default", args{`0.5`, FromJSONOptions{}}, []byte{0xcb, 0x3f, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"lossless float32", args{`0.5`, FromJSONOptions{LosslessFloat32: true}}, []byte{0xca, 0x3f, 0x00, 0x00, 0x00}},
+		{"negative float32", args{`-1.25`, FromJSONOptions{LosslessFloat32: true}}, []byte{0xca, 0xbf, 0xa0, 0x00, 0x00}},
+		{"lossy float32", args{`0.1`, FromJSONOptions{LosslessFloat32: true}}, []byte{0xcb, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{"int", args{`2`, FromJSONOptions{LosslessFloat32: true}}, []byte{0x02}},
+		{"integral float", args{`1.0`, FromJSONOptions{UseNumberLiterals: true}}, []byte{0xcb, 0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"exponent", args{`1e2`, FromJSONOptions{UseNumberLiterals: true, LosslessFloat32: true}}, []byte{0xca, 0x42, 0xc8, 0x00, 0x00}},
+		{"integer literal", args{`-200`, FromJSONOptions{UseNumberLiterals: true}}, []byte{0xd1, 0xff, 0x38}},
+		{"uint64 literal", args{`18446744073709551615`, FromJSONOptions{UseNumberLiterals: true}}, []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"integral float without option", args{`[1.0, 0.0]`, FromJSONOptions{}}, []byte{0x92, 0x01, 0x00}},
+		{"negative zero", args{`-0.0`, FromJSONOptions{}}, []byte{0xcb, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"negative zero float32", args{`-0`, FromJSONOptions{LosslessFloat32: true}}, []byte{0xca, 0x80, 0x00, 0x00, 0x00}},
+		{"key order", args{`{"b": [0.75], "a": 1}`, FromJSONOptions{LosslessFloat32: true}}, []byte{0x82, 0xa1, 0x62, 0x91, 0xca, 0x3f, 0x40, 0x00, 0x00, 0xa1, 0x61, 0x01}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromJSONWithOptions([]byte(tt.args.json), tt.args.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := FromJSONWithOptions([]byte(`[1,`), FromJSONOptions{})
+	assert.Error(t, err)
+	_, err = FromJSONWithOptions([]byte(`1 2`), FromJSONOptions{})
+	assert.Error(t, err)
+	_, err = FromJSONWithOptions([]byte(`[1] {}`), FromJSONOptions{})
+	assert.Error(t, err)
+	_, err = FromJSONWithOptions([]byte(" \n"), FromJSONOptions{})
+	assert.Error(t, err)
+}
+
+func TestEncoderLosslessFloat32(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetLosslessFloat32(true)
+	assert.NoError(t, enc.Encode([]float64{1, 0.1}))
+	assert.Equal(t, []byte{0x92, 0xca, 0x3f, 0x80, 0x00, 0x00, 0xcb, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}, buf.Bytes())
+}
+
+func TestFloat32JSON(t *testing.T) {
+	// float32 的 0.1 以 float32 最短的表示法輸出，而不是 0.10000000149011612
+	msg := []byte{0x91, 0xca, 0x3d, 0xcc, 0xcc, 0xcd}
+	assert.Equal(t, `[0.1]`, string(ToJSON(msg)))
+
+	var buf bytes.Buffer
+	assert.NoError(t, ToJSONStream(&buf, bytes.NewReader(msg)))
+	assert.Equal(t, `[0.1]`, buf.String())
+
+	v, err := DecodeValue(msg)
+	assert.NoError(t, err)
+	got, err := v.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `[0.1]`, string(got))
+}
+
+func TestToJSONWithOptionsNonFinite(t *testing.T) {
+	// [NaN, +Inf, -Inf(float32)]
+	msg := []byte{0x93,
+		0xcb, 0x7f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0xcb, 0x7f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xca, 0xff, 0x80, 0x00, 0x00,
+	}
+	tests := []struct {
+		name   string
+		policy NonFinitePolicy
+		want   string
+	}{
+		{"null", NonFiniteNull, `[null,null,null]`},
+		{"string", NonFiniteString, `["NaN","Infinity","-Infinity"]`},
+		{"JSON5", NonFiniteJSON5, `[NaN,Infinity,-Infinity]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToJSONWithOptions(msg, ToJSONOptions{NonFinite: tt.policy})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+
+	_, err := ToJSONWithOptions(msg, ToJSONOptions{})
+	assert.ErrorIs(t, err, ErrNotJSONCompatible)
+	assert.Equal(t, []byte{}, ToJSON(msg))
+}
+
+func TestToJSONWithOptions(t *testing.T) {
+	got, err := ToJSONWithOptions([]byte{0x82, 0xa1, 0x62, 0x01, 0xa1, 0x61, 0xc0}, ToJSONOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"b":1,"a":null}`, string(got))
+
+	_, err = ToJSONWithOptions([]byte{0x01, 0x02}, ToJSONOptions{})
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+	_, err = ToJSONWithOptions([]byte{}, ToJSONOptions{})
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+}
+
+func TestNegativeZero(t *testing.T) {
+	msg := FromJSON([]byte(`-0`))
+	assert.Equal(t, []byte{0xcb, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, msg)
+	assert.Equal(t, `-0`, string(ToJSON(msg)))
+	got, err := ToJSONWithOptions(msg, ToJSONOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, `-0`, string(got))
+}
+
+func TestToJSONWithOptionsFormatting(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		opts ToJSONOptions
+		want string
+	}{
+		{"indent", `{"b":[1,{}],"a":[]}`, ToJSONOptions{Indent: "  "}, "{\n  \"b\": [\n    1,\n    {}\n  ],\n  \"a\": []\n}"},
+		{"prefix", `[1,[2]]`, ToJSONOptions{Prefix: ">", Indent: "\t"}, "[\n>\t1,\n>\t[\n>\t\t2\n>\t]\n>]"},
+		{"sort keys", `{"b":1,"a":{"d":[{"f":1,"e":2}],"c":2}}`, ToJSONOptions{SortKeys: true}, `{"a":{"c":2,"d":[{"e":2,"f":1}]},"b":1}`},
+		{"sort keys indent", `{"b":{},"a":{"d":1,"c":2}}`, ToJSONOptions{SortKeys: true, Indent: " "}, "{\n \"a\": {\n  \"c\": 2,\n  \"d\": 1\n },\n \"b\": {}\n}"},
+		{"html escape", `"<&>"`, ToJSONOptions{}, `"\u003c\u0026\u003e"`},
+		{"no html escape", `"<&>"`, ToJSONOptions{DisableHTMLEscape: true}, `"<&>"`},
+		{"ascii", "{\"é\":\"\U0001f600\u2028\"}", ToJSONOptions{ASCII: true}, `{"\u00e9":"\ud83d\ude00\u2028"}`},
+		{"trailing newline", `[1]`, ToJSONOptions{TrailingNewline: true}, "[1]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := FromJSONWithOptions([]byte(tt.json), FromJSONOptions{})
+			assert.NoError(t, err)
+			got, err := ToJSONWithOptions(msg, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestToJSONWithOptionsMatchesEncodingJSON(t *testing.T) {
+	data := benchmarkJSON()
+	msg, err := FromJSONWithOptions(data, FromJSONOptions{})
+	assert.NoError(t, err)
+
+	var indented bytes.Buffer
+	assert.NoError(t, json.Indent(&indented, data, "//", "\t"))
+	got, err := ToJSONWithOptions(msg, ToJSONOptions{Prefix: "//", Indent: "\t"})
+	assert.NoError(t, err)
+	assert.Equal(t, indented.String(), string(got))
+
+	// json.Marshal 依 key 排序 map
+	var v any
+	assert.NoError(t, json.Unmarshal(data, &v))
+	want, err := json.Marshal(v)
+	assert.NoError(t, err)
+	got, err = ToJSONWithOptions(msg, ToJSONOptions{SortKeys: true})
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestToJSONWithOptionsSortIntKeys(t *testing.T) {
+	// {2: "a", 10: "b", 2: "c"}，int key 依十進位字串排序，重複的 key 保留最後的值
+	msg := []byte{0x83, 0x02, 0xa1, 0x61, 0x0a, 0xa1, 0x62, 0x02, 0xa1, 0x63}
+	got, err := ToJSONWithOptions(msg, ToJSONOptions{SortKeys: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"10":"b","2":"c"}`, string(got))
+
+	// {[]: 1}
+	_, err = ToJSONWithOptions([]byte{0x81, 0x90, 0x01}, ToJSONOptions{SortKeys: true})
+	assert.ErrorIs(t, err, ErrNotJSONCompatible)
+	// {true: 1}
+	_, err = ToJSONWithOptions([]byte{0x81, 0xc3, 0x01}, ToJSONOptions{SortKeys: true})
+	assert.ErrorIs(t, err, ErrNotJSONCompatible)
+}
+
+func TestToJSONStreamTrailingNewline(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, ToJSONStreamWithOptions(&buf, bytes.NewReader([]byte{0x01, 0x02}), ToJSONOptions{TrailingNewline: true}))
+	assert.Equal(t, "1\n2\n", buf.String())
+}
+
+func TestToJSONWithOptionsDuplicateKeys(t *testing.T) {
+	// [{"b": 1, "a": 2, "b": 3, 1: 4, "1": 5}, 6]
+	msg := []byte{0x92, 0x85, 0xa1, 0x62, 0x01, 0xa1, 0x61, 0x02, 0xa1, 0x62, 0x03, 0x01, 0x04, 0xa1, 0x31, 0x05, 0x06}
+	tests := []struct {
+		name string
+		opts ToJSONOptions
+		want string
+	}{
+		{"default", ToJSONOptions{}, `[{"b":1,"a":2,"b":3,"1":4,"1":5},6]`},
+		{"last sorted", ToJSONOptions{DuplicateKeys: DuplicateKeysLast, SortKeys: true}, `[{"1":5,"a":2,"b":3},6]`},
+		{"first", ToJSONOptions{DuplicateKeys: DuplicateKeysFirst}, `[{"b":1,"a":2,"1":4},6]`},
+		{"first sorted", ToJSONOptions{DuplicateKeys: DuplicateKeysFirst, SortKeys: true}, `[{"1":4,"a":2,"b":1},6]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToJSONWithOptions(msg, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+
+	_, err := ToJSONWithOptions(msg, ToJSONOptions{DuplicateKeys: DuplicateKeysError})
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.EqualError(t, err, `duplicate map key: "b"`)
+}
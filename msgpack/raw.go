@@ -0,0 +1,31 @@
+package msgpack
+
+import "errors"
+
+// RawMessage is a raw encoded message pack value. Unmarshal stores the
+// exact bytes of the subtree in it without decoding them, and Marshal
+// writes them back verbatim, so part of a message can be decoded later.
+type RawMessage []byte
+
+func (m RawMessage) MarshalMsgpack() ([]byte, error) {
+	if m == nil {
+		return []byte{FirstByte["nil"]}, nil
+	}
+	return m, nil
+}
+
+func (m *RawMessage) UnmarshalMsgpack(msgpackconv []byte) error {
+	if m == nil {
+		return errors.New("msgpack.RawMessage: UnmarshalMsgpack on nil pointer")
+	}
+	*m = append((*m)[0:0], msgpackconv...)
+	return nil
+}
+
+// MarshalJSON converts the raw message to JSON the same way as ToJSON.
+func (m RawMessage) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	return toJSON(m)
+}
@@ -0,0 +1,48 @@
+package msgpack_test
+
+import (
+	"encoding/json"
+	. "msgpackconv/msgpack"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type envelope struct {
+	Type    string     `msgpack:"type" json:"type"`
+	Payload RawMessage `msgpack:"payload" json:"payload"`
+}
+
+func TestRawMessage(t *testing.T) {
+	// payload 以非最小的 uint16 編碼，確認原始 bytes 被保留
+	msg := []byte{0x82, 0xa4, 0x74, 0x79, 0x70, 0x65, 0xa1, 0x70, 0xa7, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x92, 0xcd, 0x00, 0x01, 0xa1, 0x61}
+	var env envelope
+	assert.NoError(t, Unmarshal(msg, &env))
+	assert.Equal(t, "p", env.Type)
+	assert.Equal(t, RawMessage{0x92, 0xcd, 0x00, 0x01, 0xa1, 0x61}, env.Payload)
+
+	got, err := Marshal(env)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, got)
+
+	var payload []interface{}
+	assert.NoError(t, Unmarshal(env.Payload, &payload))
+	assert.Equal(t, []interface{}{uint64(1), "a"}, payload)
+
+	j, err := json.Marshal(env)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"p","payload":[1,"a"]}`, string(j))
+}
+
+func TestRawMessageNil(t *testing.T) {
+	got, err := Marshal(envelope{Type: "p"})
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0xc0), got[len(got)-1])
+
+	j, err := json.Marshal(envelope{Type: "p"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"p","payload":null}`, string(j))
+
+	_, err = Marshal(RawMessage{0x92, 0x01})
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+}
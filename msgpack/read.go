@@ -0,0 +1,201 @@
+package msgpack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The Read functions decode the first value of b and return it with the
+// remaining bytes. They are the counterpart of the Append functions.
+
+// PeekKind returns the kind of the first value of b, or InvalidKind.
+func PeekKind(b []byte) Kind {
+	h, err := readHeader(b)
+	if err != nil {
+		return InvalidKind
+	}
+	switch h.format {
+	case "nil":
+		return NilKind
+	case "false", "true":
+		return BoolKind
+	case "positiveFixint", "uint8", "uint16", "uint32", "uint64":
+		return UintKind
+	case "negativeFixint", "int8", "int16", "int32", "int64":
+		return IntKind
+	case "float32":
+		return Float32Kind
+	case "float64":
+		return Float64Kind
+	case "fixstr", "str8", "str16", "str32":
+		return StrKind
+	case "bin8", "bin16", "bin32":
+		return BinKind
+	case "fixarray", "array16", "array32":
+		return ArrayKind
+	case "fixmap", "map16", "map32":
+		return MapKind
+	}
+	return ExtKind
+}
+
+// ReadNil consumes a nil if b starts with one.
+func ReadNil(b []byte) ([]byte, bool) {
+	if len(b) > 0 && b[0] == FirstByte["nil"] {
+		return b[1:], true
+	}
+	return b, false
+}
+
+func ReadBool(b []byte) (bool, []byte, error) {
+	val, rest, err := readScalar(b)
+	if err != nil {
+		return false, b, err
+	}
+	v, ok := val.Bool()
+	if !ok {
+		return false, b, fmt.Errorf("%w: %s into bool", ErrUnmarshalType, val.Kind())
+	}
+	return v, rest, nil
+}
+
+// ReadInt reads an int or uint that fits in a signed integer of bitSize
+// bits.
+func ReadInt(b []byte, bitSize int) (int64, []byte, error) {
+	val, rest, err := readScalar(b)
+	if err != nil {
+		return 0, b, err
+	}
+	v, ok := val.Int()
+	if !ok || bitSize < 64 && (v < -1<<(bitSize-1) || v >= 1<<(bitSize-1)) {
+		return 0, b, fmt.Errorf("%w: %s into int%d", ErrUnmarshalType, val.Kind(), bitSize)
+	}
+	return v, rest, nil
+}
+
+// ReadUint reads an int or uint that fits in an unsigned integer of
+// bitSize bits.
+func ReadUint(b []byte, bitSize int) (uint64, []byte, error) {
+	val, rest, err := readScalar(b)
+	if err != nil {
+		return 0, b, err
+	}
+	v, ok := val.Uint()
+	if !ok || bitSize < 64 && v >= 1<<bitSize {
+		return 0, b, fmt.Errorf("%w: %s into uint%d", ErrUnmarshalType, val.Kind(), bitSize)
+	}
+	return v, rest, nil
+}
+
+// ReadFloat reads a float, or an int as FromJSON writes integral numbers
+// as ints. With bitSize 32 the result is rounded to float32.
+func ReadFloat(b []byte, bitSize int) (float64, []byte, error) {
+	val, rest, err := readScalar(b)
+	if err != nil {
+		return 0, b, err
+	}
+	v, ok := val.Float()
+	if i, isInt := val.Int(); isInt {
+		v, ok = float64(i), true
+	} else if u, isUint := val.Uint(); isUint {
+		v, ok = float64(u), true
+	}
+	if !ok {
+		return 0, b, fmt.Errorf("%w: %s into float%d", ErrUnmarshalType, val.Kind(), bitSize)
+	}
+	if bitSize == 32 {
+		v = float64(float32(v))
+	}
+	return v, rest, nil
+}
+
+// ReadString reads a str, or a bin as a string.
+func ReadString(b []byte) (string, []byte, error) {
+	h, err := readHeader(b)
+	if err != nil {
+		return "", b, err
+	}
+	switch h.format {
+	case "fixstr", "str8", "str16", "str32", "bin8", "bin16", "bin32":
+	default:
+		return "", b, fmt.Errorf("%w: %s into string", ErrUnmarshalType, PeekKind(b))
+	}
+	idxOfEnd := h.size + h.length
+	if len(b) < idxOfEnd {
+		return "", b, ErrInvalidMsgPack
+	}
+	return string(b[h.size:idxOfEnd]), b[idxOfEnd:], nil
+}
+
+// ReadBytes reads a copy of a bin, or a str as bytes.
+func ReadBytes(b []byte) ([]byte, []byte, error) {
+	s, rest, err := ReadString(b)
+	if err != nil {
+		return nil, b, err
+	}
+	return []byte(s), rest, nil
+}
+
+// ReadArrayHeader reads the element count of an array.
+func ReadArrayHeader(b []byte) (int, []byte, error) {
+	return readContainerHeader(b, ArrayKind)
+}
+
+// ReadMapHeader reads the key-value pair count of a map.
+func ReadMapHeader(b []byte) (int, []byte, error) {
+	return readContainerHeader(b, MapKind)
+}
+
+func readContainerHeader(b []byte, kind Kind) (int, []byte, error) {
+	h, err := readHeader(b)
+	if err != nil {
+		return 0, b, err
+	}
+	if got := PeekKind(b); got != kind {
+		return 0, b, fmt.Errorf("%w: %s into %s", ErrUnmarshalType, got, kind)
+	}
+	// 每個元素至少佔用一個 byte，避免依照錯誤的長度配置記憶體
+	least := h.length
+	if kind == MapKind {
+		least *= 2
+	}
+	if least > len(b)-h.size {
+		return 0, b, ErrInvalidMsgPack
+	}
+	return h.length, b[h.size:], nil
+}
+
+// Skip returns b without its first value.
+func Skip(b []byte) ([]byte, error) {
+	n, err := skip(b)
+	if err != nil {
+		return b, err
+	}
+	return b[n:], nil
+}
+
+// ReadAny decodes the first value of b into v as Unmarshal does.
+func ReadAny(b []byte, v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return b, fmt.Errorf("%w: %T", ErrInvalidUnmarshal, v)
+	}
+	var d decodeState
+	n, err := d.unmarshalValue(b, rv.Elem())
+	if err != nil {
+		return b, err
+	}
+	return b[n:], nil
+}
+
+func readScalar(b []byte) (Value, []byte, error) {
+	switch kind := PeekKind(b); kind {
+	case ArrayKind, MapKind:
+		return Value{}, b, fmt.Errorf("%w: %s", ErrUnmarshalType, kind)
+	}
+	val, n, err := decodeValue(b)
+	if err != nil {
+		return Value{}, b, err
+	}
+	return val, b[n:], nil
+}
@@ -0,0 +1,84 @@
+package msgpack_test
+
+import (
+	. "msgpackconv/msgpack"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSequence(t *testing.T) {
+	// [true, -2, 300, 1.5, "ab", bin(1), {"k": nil}] 之後接著一個 0x01
+	b := []byte{0x97, 0xc3, 0xfe, 0xcd, 0x01, 0x2c, 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0xa2, 0x61, 0x62, 0xc4, 0x01, 0x07, 0x81, 0xa1, 0x6b, 0xc0, 0x01}
+
+	assert.Equal(t, ArrayKind, PeekKind(b))
+	n, b, err := ReadArrayHeader(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, n)
+
+	v, b, err := ReadBool(b)
+	assert.NoError(t, err)
+	assert.True(t, v)
+
+	i, b, err := ReadInt(b, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-2), i)
+
+	_, _, err = ReadUint(b, 8)
+	assert.ErrorIs(t, err, ErrUnmarshalType)
+	u, b, err := ReadUint(b, 16)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(300), u)
+
+	f, b, err := ReadFloat(b, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+
+	s, b, err := ReadString(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", s)
+
+	bin, b, err := ReadBytes(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x07}, bin)
+
+	n, b, err = ReadMapHeader(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	s, b, err = ReadString(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "k", s)
+	b, isNil := ReadNil(b)
+	assert.True(t, isNil)
+
+	assert.Equal(t, []byte{0x01}, b)
+}
+
+func TestReadErrors(t *testing.T) {
+	b := []byte{0xa1, 0x61}
+	_, rest, err := ReadInt(b, 64)
+	assert.ErrorIs(t, err, ErrUnmarshalType)
+	assert.Equal(t, b, rest)
+
+	_, _, err = ReadArrayHeader(b)
+	assert.ErrorIs(t, err, ErrUnmarshalType)
+
+	// array32 宣告的長度超過剩餘的 bytes
+	_, _, err = ReadArrayHeader([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	assert.ErrorIs(t, err, ErrInvalidMsgPack)
+
+	assert.Equal(t, InvalidKind, PeekKind(nil))
+}
+
+func TestSkipAndReadAny(t *testing.T) {
+	b := []byte{0x92, 0x01, 0xa1, 0x61, 0x05}
+	rest, err := Skip(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x05}, rest)
+
+	var out []interface{}
+	rest, err = ReadAny(b, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{uint64(1), "a"}, out)
+	assert.Equal(t, []byte{0x05}, rest)
+}
@@ -0,0 +1,177 @@
+package msgpack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// FromJSONSeeker is FromJSONStream for outputs that can seek, such as files.
+// Every array and object is written with a reserved array32 or map32
+// header whose count is patched once the container ends, so no element is
+// buffered until its container is complete. Use CompactFile afterwards to
+// rewrite the headers to the smallest format.
+func FromJSONSeeker(dst io.WriteSeeker, src io.Reader) error {
+	return FromJSONSeekerWithOptions(dst, src, FromJSONOptions{})
+}
+
+// FromJSONSeekerWithOptions converts JSON values from src to message pack
+// like FromJSONSeeker, with the formats selected by opts.
+func FromJSONSeekerWithOptions(dst io.WriteSeeker, src io.Reader, opts FromJSONOptions) error {
+	start, err := dst.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	sink := &seekSink{w: &patchWriter{dst: dst, base: start}}
+	if err := opts.stream(opts.decoder(src), sink); err != nil {
+		return err
+	}
+	return sink.w.flush()
+}
+
+// seekSink 為每個 container 寫入保留的 array32 或 map32 header，結束時修改
+// 其中的數量
+type seekSink struct {
+	w *patchWriter
+	// 每一層尚未結束的 container 的 header 在輸出中的位置
+	offsets []int64
+}
+
+func (s *seekSink) open(isMap bool) error {
+	s.offsets = append(s.offsets, s.w.offset())
+	if isMap {
+		s.w.write([]byte{FirstByte["map32"], 0, 0, 0, 0})
+	} else {
+		s.w.write([]byte{FirstByte["array32"], 0, 0, 0, 0})
+	}
+	return s.w.err
+}
+
+func (s *seekSink) close(isMap bool, count int) error {
+	offset := s.offsets[len(s.offsets)-1]
+	s.offsets = s.offsets[:len(s.offsets)-1]
+	if count > math.MaxUint32 {
+		return fmt.Errorf("%w: %d elements exceed array32", ErrUnsupportedType, count)
+	}
+	return s.w.patch(offset+1, uint32(count))
+}
+
+func (s *seekSink) value(b []byte) error {
+	s.w.write(b)
+	return s.w.err
+}
+
+// patchWriter 緩衝尚未寫出的 bytes，header 仍在緩衝中時直接修改，
+// 否則 seek 回已寫出的位置修改
+type patchWriter struct {
+	dst io.WriteSeeker
+	buf []byte
+	// buf[0] 在輸出中的位置，也是 dst 目前的位置
+	base int64
+	err  error
+}
+
+func (w *patchWriter) offset() int64 {
+	return w.base + int64(len(w.buf))
+}
+
+func (w *patchWriter) write(b []byte) {
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= 64*1024 {
+		w.err = w.flush()
+	}
+}
+
+func (w *patchWriter) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	n, err := w.dst.Write(w.buf)
+	w.base += int64(n)
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *patchWriter) patch(offset int64, count uint32) error {
+	if offset >= w.base {
+		binary.BigEndian.PutUint32(w.buf[offset-w.base:], count)
+		return nil
+	}
+	if _, err := w.dst.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(w.dst, binary.BigEndian, count); err != nil {
+		return err
+	}
+	_, err := w.dst.Seek(w.base, io.SeekStart)
+	return err
+}
+
+// Compact copies the message pack values of src to dst, rewriting every
+// array and map header to the smallest format for its count. Other values
+// are copied unchanged.
+func Compact(dst io.Writer, src io.Reader) error {
+	r := bufio.NewReader(src)
+	w := bufio.NewWriter(dst)
+	// 尚未讀取的值的數量
+	pending := 0
+	for {
+		if _, err := r.Peek(1); err == io.EOF {
+			if pending > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return w.Flush()
+		}
+		h, err := peekHeader(r)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			pending--
+		}
+
+		switch h.format {
+		case "fixarray", "array16", "array32":
+			pending += h.length
+			w.Write(getArrayFormat(h.length))
+			r.Discard(h.size)
+		case "fixmap", "map16", "map32":
+			pending += 2 * h.length
+			w.Write(getMapFormat(h.length))
+			r.Discard(h.size)
+		default:
+			if _, err := io.CopyN(w, r, int64(h.size+h.length)); err != nil {
+				return unexpectedEOF(err)
+			}
+		}
+	}
+}
+
+// CompactFile runs Compact on the contents of f in place and truncates the
+// file to the new length. Headers only shrink, so every byte is read
+// before it is overwritten.
+func CompactFile(f *os.File) error {
+	w := &countingWriter{w: io.NewOffsetWriter(f, 0)}
+	if err := Compact(w, io.NewSectionReader(f, 0, math.MaxInt64)); err != nil {
+		return err
+	}
+	if err := f.Truncate(w.n); err != nil {
+		return err
+	}
+	_, err := f.Seek(w.n, io.SeekStart)
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	return n, err
+}
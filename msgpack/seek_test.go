@@ -0,0 +1,97 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"io"
+	. "msgpackconv/msgpack"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromJSONSeeker(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"scalar", `"a"`},
+		{"nested", `[1, {"b": [], "a": [true, null]}, "x"]`},
+		// 超過緩衝的大小，外層的 header 需要 seek 回去修改
+		{"large", "[" + strings.Repeat(`{"id": 70000, "tags": ["a", "b"]},`, 20000) + "0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want bytes.Buffer
+			assert.NoError(t, FromJSONStream(&want, strings.NewReader(tt.json)))
+
+			f, err := os.Create(filepath.Join(t.TempDir(), "out.msgpack"))
+			assert.NoError(t, err)
+			defer f.Close()
+			assert.NoError(t, FromJSONSeeker(f, strings.NewReader(tt.json)))
+
+			reserved, err := os.ReadFile(f.Name())
+			assert.NoError(t, err)
+			v, err := DecodeValue(reserved)
+			assert.NoError(t, err)
+			wantValue, err := DecodeValue(want.Bytes())
+			assert.NoError(t, err)
+			assert.Equal(t, wantValue, v)
+
+			assert.NoError(t, CompactFile(f))
+			got, err := os.ReadFile(f.Name())
+			assert.NoError(t, err)
+			assert.Equal(t, want.Bytes(), got)
+		})
+	}
+}
+
+func TestFromJSONSeekerWithOptions(t *testing.T) {
+	const data = `{"f": 0.5, "big": [1e400, -18446744073709551617], "n": 1.0}`
+	opts := FromJSONOptions{LosslessFloat32: true, UseNumberLiterals: true, BigNumbers: BigNumbersExt}
+	want, err := FromJSONWithOptions([]byte(data), opts)
+	assert.NoError(t, err)
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.msgpack"))
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.NoError(t, FromJSONSeekerWithOptions(f, strings.NewReader(data), opts))
+	assert.NoError(t, CompactFile(f))
+	got, err := os.ReadFile(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestFromJSONSeekerHeader(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.msgpack"))
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.NoError(t, FromJSONSeeker(f, strings.NewReader(`{"a": [1]}`)))
+	got, err := os.ReadFile(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xdf, 0, 0, 0, 1, 0xa1, 0x61, 0xdd, 0, 0, 0, 1, 0x01}, got)
+}
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		name        string
+		msgpackconv []byte
+		want        []byte
+	}{
+		{"array32", []byte{0xdd, 0, 0, 0, 2, 0x01, 0xde, 0, 1, 0xa1, 0x61, 0xc0}, []byte{0x92, 0x01, 0x81, 0xa1, 0x61, 0xc0}},
+		{"other values unchanged", []byte{0xcd, 0x00, 0x01, 0xd9, 0x01, 0x61}, []byte{0xcd, 0x00, 0x01, 0xd9, 0x01, 0x61}},
+		{"sequence", []byte{0xdc, 0, 0, 0x90}, []byte{0x90, 0x90}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, Compact(&buf, bytes.NewReader(tt.msgpackconv)))
+			assert.Equal(t, tt.want, buf.Bytes())
+		})
+	}
+
+	assert.ErrorIs(t, Compact(io.Discard, bytes.NewReader([]byte{0x92, 0x01})), io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, Compact(io.Discard, bytes.NewReader([]byte{0xa2, 0x61})), io.ErrUnexpectedEOF)
+}
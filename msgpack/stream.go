@@ -0,0 +1,600 @@
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// FromJSONStream reads JSON values from src and writes their message pack
+// encoding to dst, one after another, with the formats of FromJSON.
+//
+// Unlike FromJSON it does not build the whole document in memory: tokens
+// are encoded as they are read, and only the already encoded elements of
+// the open containers are buffered until their count is known. Object keys
+// keep their order in the input, and duplicate keys are all written.
+func FromJSONStream(dst io.Writer, src io.Reader) error {
+	return FromJSONStreamWithOptions(dst, src, FromJSONOptions{})
+}
+
+// FromJSONStreamWithOptions converts JSON values from src to message pack
+// like FromJSONStream, with the formats selected by opts.
+func FromJSONStreamWithOptions(dst io.Writer, src io.Reader, opts FromJSONOptions) error {
+	return opts.stream(opts.decoder(src), &bufferedSink{dst: dst})
+}
+
+// jsonSink 接收 FromJSONOptions.stream 編碼的結果，container 的 header 在
+// 結束時才知道元素的數量
+type jsonSink interface {
+	open(isMap bool) error
+	close(isMap bool, count int) error
+	value(b []byte) error
+}
+
+// stream 依序編碼 dec 的 token 並交給 sink
+func (opts FromJSONOptions) stream(dec *json.Decoder, sink jsonSink) error {
+	// 每一層尚未結束的 container
+	type frame struct {
+		isMap bool
+		count int
+	}
+	stack := []frame{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			if len(stack) != 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var b []byte
+		switch v := tok.(type) {
+		case json.Delim:
+			if v == '[' || v == '{' {
+				stack = append(stack, frame{isMap: v == '{'})
+				if err := sink.open(v == '{'); err != nil {
+					return err
+				}
+				continue
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if top.isMap {
+				// object 的 key 與 value 各算一個 token
+				top.count /= 2
+			}
+			err = sink.close(top.isMap, top.count)
+		case float64:
+			err = sink.value(opts.numberFormat(v))
+		case json.Number:
+			if b, err = opts.numberTokenFormat(v); err == nil {
+				err = sink.value(b)
+			}
+		default:
+			err = sink.value(encode(v))
+		}
+		if err != nil {
+			return err
+		}
+		if len(stack) > 0 {
+			stack[len(stack)-1].count++
+		}
+	}
+}
+
+// bufferedSink 緩衝尚未結束的 container 中已編碼的元素，結束時加上 header
+// 寫入上一層或 dst
+type bufferedSink struct {
+	dst  io.Writer
+	bufs [][]byte
+	// values 為已寫入 dst 的值的數量
+	values int
+}
+
+func (s *bufferedSink) open(isMap bool) error {
+	s.bufs = append(s.bufs, nil)
+	return nil
+}
+
+func (s *bufferedSink) close(isMap bool, count int) error {
+	buf := s.bufs[len(s.bufs)-1]
+	s.bufs = s.bufs[:len(s.bufs)-1]
+	if isMap {
+		return s.value(append(getMapFormat(count), buf...))
+	}
+	return s.value(append(getArrayFormat(count), buf...))
+}
+
+func (s *bufferedSink) value(b []byte) error {
+	if len(s.bufs) > 0 {
+		s.bufs[len(s.bufs)-1] = append(s.bufs[len(s.bufs)-1], b...)
+		return nil
+	}
+	s.values++
+	_, err := s.dst.Write(b)
+	return err
+}
+
+// ToJSONStream reads message pack values from src and writes them to dst
+// as JSON text, separated by newlines.
+//
+// Values are converted as they are read, so memory use does not depend on
+// the size of the input: strings are escaped in chunks, bin is written as
+// base64 and map entries are written in wire order. Int and uint map keys
+// are quoted; other keys and ext values are not JSON compatible. Numbers
+// are formatted as json.Marshal formats an int64, uint64, float32 or
+// float64, so floats round trip exactly.
+func ToJSONStream(dst io.Writer, src io.Reader) error {
+	return ToJSONOptions{}.stream(dst, src)
+}
+
+func (opts ToJSONOptions) stream(dst io.Writer, src io.Reader) error {
+	t := &jsonTranscoder{r: bufio.NewReader(src), w: bufio.NewWriter(dst), opts: opts}
+	i := 0
+	for ; ; i++ {
+		if _, err := t.r.Peek(1); err == io.EOF {
+			break
+		}
+		if i > 0 {
+			t.w.WriteByte('\n')
+		}
+		if err := t.writeValue(); err != nil {
+			return err
+		}
+	}
+	if i > 0 && opts.TrailingNewline {
+		t.w.WriteByte('\n')
+	}
+	return t.w.Flush()
+}
+
+// jsonTranscoder 逐一讀取 msgpack 的值並寫出 JSON
+type jsonTranscoder struct {
+	r    *bufio.Reader
+	w    *bufio.Writer
+	opts ToJSONOptions
+	// 縮排的起始層數
+	depth int
+	// 重複使用的暫存空間
+	buf []byte
+}
+
+// container 為尚未結束的 array 或 map
+type container struct {
+	isMap bool
+	// 剩餘的元素或 entry 的數量
+	remaining int
+	written   int
+	// 出現過的 key，只在 DuplicateKeysError 與 DuplicateKeysFirst 時記錄
+	seen map[string]struct{}
+}
+
+func (t *jsonTranscoder) writeValue() error {
+	stack := []container{}
+	for {
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.remaining == 0 {
+				// 空的 container 不換行
+				if top.written > 0 {
+					t.newline(len(stack) - 1)
+				}
+				if top.isMap {
+					t.w.WriteByte('}')
+				} else {
+					t.w.WriteByte(']')
+				}
+				stack = stack[:len(stack)-1]
+				if len(stack) == 0 {
+					return nil
+				}
+				continue
+			}
+			top.remaining--
+			if top.isMap {
+				skip, err := t.writeKey(top, len(stack))
+				if err != nil {
+					return err
+				}
+				if skip {
+					if err := discardValue(t.r); err != nil {
+						return err
+					}
+					continue
+				}
+			} else {
+				if top.written > 0 {
+					t.w.WriteByte(',')
+				}
+				t.newline(len(stack))
+			}
+			top.written++
+		}
+
+		h, err := t.readHeader()
+		if err != nil {
+			return err
+		}
+		switch h.format {
+		case "fixarray", "array16", "array32":
+			t.r.Discard(h.size)
+			t.w.WriteByte('[')
+			stack = append(stack, container{remaining: h.length})
+			continue
+		case "fixmap", "map16", "map32":
+			t.r.Discard(h.size)
+			if t.opts.SortKeys {
+				err = t.writeBufferedMap(h.length, len(stack))
+				break
+			}
+			c := container{isMap: true, remaining: h.length}
+			if t.opts.DuplicateKeys != DuplicateKeysLast {
+				c.seen = map[string]struct{}{}
+			}
+			t.w.WriteByte('{')
+			stack = append(stack, c)
+			continue
+		case "fixstr", "str8", "str16", "str32":
+			err = t.writeString(h)
+		case "bin8", "bin16", "bin32":
+			err = t.writeBin(h)
+		default:
+			err = t.writeScalar(h)
+		}
+		if err != nil {
+			return err
+		}
+		if len(stack) == 0 {
+			return nil
+		}
+	}
+}
+
+// writeKey 讀取 c 的下一個 key 並寫出，回傳是否應略過重複的 key 的 value。
+// depth 為 map 中 entry 的層數
+func (t *jsonTranscoder) writeKey(c *container, depth int) (bool, error) {
+	key, err := t.readKey()
+	if err != nil {
+		return false, err
+	}
+	if c.seen != nil {
+		if _, ok := c.seen[key]; ok {
+			if t.opts.DuplicateKeys == DuplicateKeysError {
+				return false, fmt.Errorf("%w: %q", ErrDuplicateKey, key)
+			}
+			return true, nil
+		}
+		c.seen[key] = struct{}{}
+	}
+	if c.written > 0 {
+		t.w.WriteByte(',')
+	}
+	t.newline(depth)
+	t.buf = appendStringJSON(t.buf[:0], key, t.opts)
+	t.w.Write(t.buf)
+	t.writeColon()
+	return false, nil
+}
+
+// discardValue 略過 r 的下一個值，不保留讀取的資料
+func discardValue(r *bufio.Reader) error {
+	for remaining := 1; remaining > 0; remaining-- {
+		h, err := peekHeader(r)
+		if err != nil {
+			return err
+		}
+		n := h.size + h.length
+		switch h.format {
+		case "fixarray", "array16", "array32":
+			remaining += h.length
+			n = h.size
+		case "fixmap", "map16", "map32":
+			remaining += 2 * h.length
+			n = h.size
+		}
+		if _, err := r.Discard(n); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+	return nil
+}
+
+// newline 在縮排模式下換行，並寫出 depth 層的縮排
+func (t *jsonTranscoder) newline(depth int) {
+	if !t.opts.indented() {
+		return
+	}
+	t.w.WriteByte('\n')
+	t.w.WriteString(t.opts.Prefix)
+	for range t.depth + depth {
+		t.w.WriteString(t.opts.Indent)
+	}
+}
+
+func (t *jsonTranscoder) writeColon() {
+	t.w.WriteByte(':')
+	if t.opts.indented() {
+		t.w.WriteByte(' ')
+	}
+}
+
+// writeBufferedMap 在 SortKeys 時讀取 map 的 n 組 entry，依照 opts 處理
+// 重複的 key 並排序後寫出。depth 為 map 本身所在的層數
+func (t *jsonTranscoder) writeBufferedMap(n, depth int) error {
+	type entry struct {
+		key   string
+		value []byte
+	}
+	// n 來自輸入，不以它預先配置
+	entries := []entry{}
+	index := map[string]int{}
+	var buf bytes.Buffer
+	// value 寫入 buf，巢狀的 map 以同樣的方式處理
+	sub := &jsonTranscoder{r: t.r, w: bufio.NewWriter(&buf), opts: t.opts, depth: t.depth + depth + 1}
+	for range n {
+		key, err := t.readKey()
+		if err != nil {
+			return err
+		}
+		i, seen := index[key]
+		if seen && t.opts.DuplicateKeys == DuplicateKeysError {
+			return fmt.Errorf("%w: %q", ErrDuplicateKey, key)
+		}
+		if err := sub.writeValue(); err != nil {
+			return err
+		}
+		sub.w.Flush()
+		value := bytes.Clone(buf.Bytes())
+		buf.Reset()
+		switch {
+		case !seen:
+			index[key] = len(entries)
+			entries = append(entries, entry{key: key, value: value})
+		case t.opts.DuplicateKeys == DuplicateKeysLast:
+			entries[i].value = value
+		}
+	}
+	if t.opts.SortKeys {
+		slices.SortStableFunc(entries, func(a, b entry) int {
+			return strings.Compare(a.key, b.key)
+		})
+	}
+
+	t.w.WriteByte('{')
+	for i, e := range entries {
+		if i > 0 {
+			t.w.WriteByte(',')
+		}
+		t.newline(depth + 1)
+		t.buf = appendStringJSON(t.buf[:0], e.key, t.opts)
+		t.w.Write(t.buf)
+		t.writeColon()
+		t.w.Write(e.value)
+	}
+	if len(entries) > 0 {
+		t.newline(depth)
+	}
+	t.w.WriteByte('}')
+	return nil
+}
+
+// readKey 讀取 map 的 key，int 與 uint 的 key 轉為十進位的字串
+func (t *jsonTranscoder) readKey() (string, error) {
+	h, err := t.readHeader()
+	if err != nil {
+		return "", err
+	}
+	switch h.format {
+	case "fixarray", "array16", "array32", "fixmap", "map16", "map32":
+		return "", fmt.Errorf("%w: %s map key", ErrNotJSONCompatible, PeekKind([]byte{FirstByte[h.format]}))
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, t.r, int64(h.size+h.length)); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	val, _, err := decodeValue(buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	switch val.kind {
+	case StrKind:
+		return val.str, nil
+	case IntKind:
+		return strconv.FormatInt(int64(val.num), 10), nil
+	case UintKind:
+		return strconv.FormatUint(val.num, 10), nil
+	}
+	return "", fmt.Errorf("%w: %s map key", ErrNotJSONCompatible, val.kind)
+}
+
+func (t *jsonTranscoder) readHeader() (header, error) {
+	return peekHeader(t.r)
+}
+
+// peekHeader 讀取但不消耗下一個值的 header
+func peekHeader(r *bufio.Reader) (header, error) {
+	// 先由第一個 byte 得知 header 的長度，在連線上讀取時才不會等待下一個值
+	first, err := r.Peek(1)
+	if err == io.EOF {
+		return header{}, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return header{}, err
+	}
+	// ext32 的 header 最長，為 6 bytes
+	padded := [6]byte{first[0]}
+	h, err := readHeader(padded[:])
+	if err != nil {
+		return header{}, err
+	}
+	b, err := r.Peek(h.size)
+	if err != nil && err != io.EOF {
+		return header{}, err
+	}
+	return readHeader(b)
+}
+
+func (t *jsonTranscoder) writeString(h header) error {
+	if _, err := t.r.Discard(h.size); err != nil {
+		return unexpectedEOF(err)
+	}
+	t.w.WriteByte('"')
+	for remaining := h.length; remaining > 0; {
+		chunk, err := t.r.Peek(min(remaining, t.r.Size()))
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		var n int
+		// 尚未讀完時，不完整的 UTF-8 留到下一次處理
+		t.buf, n = appendEscaped(t.buf[:0], chunk, len(chunk) == remaining, t.opts)
+		t.w.Write(t.buf)
+		t.r.Discard(n)
+		remaining -= n
+	}
+	t.w.WriteByte('"')
+	return nil
+}
+
+func (t *jsonTranscoder) writeBin(h header) error {
+	if _, err := t.r.Discard(h.size); err != nil {
+		return unexpectedEOF(err)
+	}
+	t.w.WriteByte('"')
+	enc := base64.NewEncoder(base64.StdEncoding, t.w)
+	if _, err := io.CopyN(enc, t.r, int64(h.length)); err != nil {
+		return unexpectedEOF(err)
+	}
+	enc.Close()
+	t.w.WriteByte('"')
+	return nil
+}
+
+func (t *jsonTranscoder) writeScalar(h header) error {
+	if strings.Contains(h.format, "ext") && h.extType != BigIntExtType && h.extType != BigFloatExtType {
+		return fmt.Errorf("%w: ext type %d", ErrNotJSONCompatible, h.extType)
+	}
+	// 數字與大數的 ext 需要完整讀取，隨讀取的資料配置記憶體
+	buf := bytes.NewBuffer(t.buf[:0])
+	if _, err := io.CopyN(buf, t.r, int64(h.size+h.length)); err != nil {
+		return unexpectedEOF(err)
+	}
+	val, _, err := decodeValue(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if t.buf, err = appendValueJSON(t.buf[:0], val, t.opts); err != nil {
+		return err
+	}
+	t.w.Write(t.buf)
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// appendFloatJSON 以 json.Marshal 的格式寫出 bitSize 為 32 或 64 的 float，
+// NaN 與 Inf 依照 policy 處理
+func appendFloatJSON(ans []byte, f float64, bitSize int, policy NonFinitePolicy) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return appendNonFinite(ans, f, policy)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bitSize == 64 && (abs < 1e-6 || abs >= 1e21) || bitSize == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	ans = strconv.AppendFloat(ans, f, format, -1, bitSize)
+	if format == 'e' {
+		// 將 e-09 改為 e-9
+		n := len(ans)
+		if n >= 4 && ans[n-4] == 'e' && ans[n-3] == '-' && ans[n-2] == '0' {
+			ans[n-2] = ans[n-1]
+			ans = ans[:n-1]
+		}
+	}
+	return ans, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendEscaped 以 json.Marshal 的規則跳脫 s，不包含前後的引號，並依照 opts
+// 決定是否跳脫 HTML 字元與非 ASCII 字元。final 為 false 時，結尾不完整的
+// UTF-8 不會被處理，n 為已處理的 byte 數
+func appendEscaped(ans []byte, s []byte, final bool, opts ToJSONOptions) ([]byte, int) {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch c {
+			case '"', '\\':
+				ans = append(ans, '\\', c)
+			case '\b':
+				ans = append(ans, '\\', 'b')
+			case '\f':
+				ans = append(ans, '\\', 'f')
+			case '\n':
+				ans = append(ans, '\\', 'n')
+			case '\r':
+				ans = append(ans, '\\', 'r')
+			case '\t':
+				ans = append(ans, '\\', 't')
+			case '<', '>', '&':
+				if opts.DisableHTMLEscape {
+					ans = append(ans, c)
+				} else {
+					ans = appendUnicodeEscape(ans, rune(c))
+				}
+			default:
+				if c < 0x20 {
+					ans = appendUnicodeEscape(ans, rune(c))
+				} else {
+					ans = append(ans, c)
+				}
+			}
+			i++
+			continue
+		}
+		if !final && !utf8.FullRune(s[i:]) {
+			break
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		switch {
+		case opts.ASCII && r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			ans = appendUnicodeEscape(appendUnicodeEscape(ans, r1), r2)
+		case opts.ASCII || r == '\u2028' || r == '\u2029':
+			// 不合法的 UTF-8 也會成為 \ufffd
+			ans = appendUnicodeEscape(ans, r)
+		case r == utf8.RuneError && size == 1:
+			ans = utf8.AppendRune(ans, utf8.RuneError)
+		default:
+			ans = append(ans, s[i:i+size]...)
+		}
+		i += size
+	}
+	return ans, i
+}
+
+// appendUnicodeEscape 以 \uXXXX 寫出不超過 U+FFFF 的 r
+func appendUnicodeEscape(ans []byte, r rune) []byte {
+	return append(ans, '\\', 'u', hexDigits[r>>12&0xf], hexDigits[r>>8&0xf], hexDigits[r>>4&0xf], hexDigits[r&0xf])
+}
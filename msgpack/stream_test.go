@@ -0,0 +1,237 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	. "msgpackconv/msgpack"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromJSONStream(t *testing.T) {
+	type args struct {
+		json string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{"scalar", args{`-1.5`}, []byte{0xcb, 0xbf, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"empty array", args{`[]`}, []byte{0x90}},
+		{"nested", args{`[1, [true, null], "a"]`}, []byte{0x93, 0x01, 0x92, 0xc3, 0xc0, 0xa1, 0x61}},
+		{"key order", args{`{"b": 1, "a": {}}`}, []byte{0x82, 0xa1, 0x62, 0x01, 0xa1, 0x61, 0x80}},
+		{"duplicate keys", args{`{"a": 1, "a": 2}`}, []byte{0x82, 0xa1, 0x61, 0x01, 0xa1, 0x61, 0x02}},
+		{"sequence", args{"1\n[2]\n"}, []byte{0x01, 0x91, 0x02}},
+		{"array16", args{"[" + strings.Repeat("0,", 15) + "0]"}, append([]byte{0xdc, 0x00, 0x10}, make([]byte, 16)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, FromJSONStream(&buf, strings.NewReader(tt.args.json)))
+			assert.Equal(t, tt.want, buf.Bytes())
+		})
+	}
+}
+
+func TestFromJSONStreamWithOptions(t *testing.T) {
+	opts := FromJSONOptions{LosslessFloat32: true, UseNumberLiterals: true, BigNumbers: BigNumbersString}
+	var buf bytes.Buffer
+	assert.NoError(t, FromJSONStreamWithOptions(&buf, strings.NewReader("0.5\n[1.0, 18446744073709551616]\n"), opts))
+	assert.Equal(t, []byte{
+		0xca, 0x3f, 0x00, 0x00, 0x00,
+		0x92, 0xca, 0x3f, 0x80, 0x00, 0x00,
+		0xb4, '1', '8', '4', '4', '6', '7', '4', '4', '0', '7', '3', '7', '0', '9', '5', '5', '1', '6', '1', '6',
+	}, buf.Bytes())
+}
+
+func TestFromJSONStreamMatchesFromJSON(t *testing.T) {
+	// 只有單一 key 的 object，FromJSON 的輸出順序才固定
+	doc := `{"items": [{"id": 1}, {"id": -200}, {"id": 3.25}], "next": null}`
+	var buf bytes.Buffer
+	assert.NoError(t, FromJSONStream(&buf, strings.NewReader(doc)))
+	v, err := DecodeValue(buf.Bytes())
+	assert.NoError(t, err)
+	want, err := DecodeValue(FromJSON([]byte(doc)))
+	assert.NoError(t, err)
+	assert.Equal(t, want.Get("items"), v.Get("items"))
+	assert.Equal(t, want.Len(), v.Len())
+}
+
+func TestFromJSONStreamError(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"unterminated", `[1, 2`},
+		{"syntax", `{"a" 1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, FromJSONStream(io.Discard, strings.NewReader(tt.json)))
+		})
+	}
+}
+
+func benchmarkJSON() []byte {
+	var b strings.Builder
+	b.WriteString("[")
+	for i := range 2000 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "item %d", "price": %d.5, "tags": ["a", "b"], "stock": null}`, i, i, i)
+	}
+	b.WriteString("]")
+	return []byte(b.String())
+}
+
+func BenchmarkFromJSON(b *testing.B) {
+	data := benchmarkJSON()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		FromJSON(data)
+	}
+}
+
+func BenchmarkFromJSONStream(b *testing.B) {
+	data := benchmarkJSON()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := FromJSONStream(io.Discard, bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestToJSONStream(t *testing.T) {
+	type args struct {
+		msgpackconv []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"nested", args{[]byte{0x93, 0x01, 0x92, 0xc3, 0xc0, 0xa1, 0x61}}, `[1,[true,null],"a"]`},
+		{"wire order", args{[]byte{0x82, 0xa1, 0x62, 0x01, 0xa1, 0x61, 0x80}}, `{"b":1,"a":{}}`},
+		{"int key", args{[]byte{0x81, 0xff, 0x90}}, `{"-1":[]}`},
+		{"float32", args{[]byte{0xca, 0x3f, 0x8c, 0xcc, 0xcd}}, `1.1`},
+		{"float64", args{[]byte{0xcb, 0x3e, 0x7a, 0xd7, 0xf2, 0x9a, 0xbc, 0xaf, 0x48}}, `1e-7`},
+		{"uint64", args{[]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}}, `18446744073709551615`},
+		{"bin", args{[]byte{0xc4, 0x03, 0x01, 0x02, 0x03}}, `"AQID"`},
+		{"escape", args{[]byte{0xa6, '<', '"', '\n', 0x01, 0xff, 'a'}}, "\"\\u003c\\\"\\n\\u0001\ufffda\""},
+		{"sequence", args{[]byte{0x01, 0x91, 0x02}}, "1\n[2]"},
+		{"empty", args{[]byte{}}, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, ToJSONStream(&buf, bytes.NewReader(tt.args.msgpackconv)))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestToJSONStreamLongString(t *testing.T) {
+	// 多 byte 的字元會跨越讀取的 chunk，結尾不完整的 UTF-8 以 U+FFFD 取代
+	s := strings.Repeat("a\u00e9\u20ac\U0001d11e\u2028<", 3000) + "\xe2\x82"
+	b, err := Str(s).MarshalMsgpack()
+	assert.NoError(t, err)
+	want := `"` + strings.Repeat("a\u00e9\u20ac\U0001d11e"+`\u2028\u003c`, 3000) + "\ufffd\ufffd" + `"`
+
+	var buf bytes.Buffer
+	assert.NoError(t, ToJSONStream(&buf, bytes.NewReader(b)))
+	assert.Equal(t, want, buf.String())
+}
+
+// firstWriteSignal 在第一次寫入時關閉 started
+type firstWriteSignal struct {
+	bytes.Buffer
+	started chan struct{}
+}
+
+func (w *firstWriteSignal) Write(b []byte) (int, error) {
+	if w.Len() == 0 {
+		close(w.started)
+	}
+	return w.Buffer.Write(b)
+}
+
+func TestToJSONStreamMapIncremental(t *testing.T) {
+	// map 的 entry 依序寫出，value 不需要讀完才開始輸出
+	for _, policy := range []DuplicateKeyPolicy{DuplicateKeysLast, DuplicateKeysError, DuplicateKeysFirst} {
+		r, w := io.Pipe()
+		out := &firstWriteSignal{started: make(chan struct{})}
+		done := make(chan error, 1)
+		go func() {
+			done <- ToJSONStreamWithOptions(out, r, ToJSONOptions{DuplicateKeys: policy})
+		}()
+		// {"a": [0, 0, ...]}，array32 有 65536 個元素
+		w.Write([]byte{0x81, 0xa1, 0x61, 0xdd, 0x00, 0x01, 0x00, 0x00})
+		w.Write(make([]byte, 32768))
+		select {
+		case <-out.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("policy %d: no output before the end of the map", policy)
+		}
+		w.Write(make([]byte, 32768))
+		w.Close()
+		assert.NoError(t, <-done)
+		assert.Equal(t, `{"a":[0`+strings.Repeat(",0", 65535)+"]}", out.String())
+	}
+}
+
+func TestToJSONStreamMatchesToJSON(t *testing.T) {
+	doc := FromJSON([]byte(`{"items": [{"id": 1}, {"id": -200}, {"id": 3.25, "ok": [false, "x"]}]}`))
+	var buf bytes.Buffer
+	assert.NoError(t, ToJSONStream(&buf, bytes.NewReader(doc)))
+	assert.JSONEq(t, string(ToJSON(doc)), buf.String())
+}
+
+func TestToJSONStreamError(t *testing.T) {
+	tests := []struct {
+		name        string
+		msgpackconv []byte
+		want        error
+	}{
+		{"truncated array", []byte{0x92, 0x01}, io.ErrUnexpectedEOF},
+		{"truncated string", []byte{0xa3, 0x61}, io.ErrUnexpectedEOF},
+		{"truncated int", []byte{0xcd, 0x01}, io.ErrUnexpectedEOF},
+		{"ext", []byte{0xd4, 0x01, 0x00}, ErrNotJSONCompatible},
+		{"bool key", []byte{0x81, 0xc3, 0x01}, ErrNotJSONCompatible},
+		{"NaN", []byte{0xcb, 0x7f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, ErrNotJSONCompatible},
+		{"never used", []byte{0xc1}, ErrInvalidMsgPack},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, ToJSONStream(io.Discard, bytes.NewReader(tt.msgpackconv)), tt.want)
+		})
+	}
+}
+
+func BenchmarkToJSON(b *testing.B) {
+	data := FromJSON(benchmarkJSON())
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		ToJSON(data)
+	}
+}
+
+func BenchmarkToJSONStream(b *testing.B) {
+	data := FromJSON(benchmarkJSON())
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := ToJSONStream(io.Discard, bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
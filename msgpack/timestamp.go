@@ -0,0 +1,51 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// TimestampExtType is the ext type message pack reserves for timestamps.
+const TimestampExtType int8 = -1
+
+// Timestamp builds a timestamp ext in the smallest of the 32, 64 and 96-bit
+// formats that holds t.
+func Timestamp(t time.Time) Value {
+	sec, nsec := t.Unix(), t.Nanosecond()
+	var data []byte
+	switch {
+	case sec>>32 == 0 && nsec == 0:
+		data = binary.BigEndian.AppendUint32(nil, uint32(sec))
+	case sec>>34 == 0:
+		// 上 30 bits 為 nanoseconds，下 34 bits 為 seconds
+		data = binary.BigEndian.AppendUint64(nil, uint64(nsec)<<34|uint64(sec))
+	default:
+		data = binary.BigEndian.AppendUint32(nil, uint32(nsec))
+		data = binary.BigEndian.AppendUint64(data, uint64(sec))
+	}
+	return Ext(TimestampExtType, data)
+}
+
+// Time returns the time, in UTC, of a timestamp ext value.
+func (v Value) Time() (time.Time, bool) {
+	if v.kind != ExtKind || v.extType != TimestampExtType {
+		return time.Time{}, false
+	}
+	var sec, nsec int64
+	switch len(v.bytes) {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(v.bytes))
+	case 8:
+		n := binary.BigEndian.Uint64(v.bytes)
+		sec, nsec = int64(n&(1<<34-1)), int64(n>>34)
+	case 12:
+		nsec = int64(binary.BigEndian.Uint32(v.bytes))
+		sec = int64(binary.BigEndian.Uint64(v.bytes[4:]))
+	default:
+		return time.Time{}, false
+	}
+	if nsec >= int64(time.Second) {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, nsec).UTC(), true
+}
@@ -0,0 +1,359 @@
+package ubjson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"msgpackconv/msgpack"
+)
+
+// DecodeValue decodes exactly one UBJSON value. No-op markers around it are
+// skipped.
+func DecodeValue(data []byte) (msgpack.Value, error) {
+	d := &decoder{data: data}
+	d.skipNoOp()
+	v, err := d.value()
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	d.skipNoOp()
+	if d.off != len(data) {
+		return msgpack.Value{}, fmt.Errorf("%w: %d bytes after the value", ErrInvalidUBJSON, len(data)-d.off)
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	off  int
+	// typed array 中不佔 byte 的 Z、T、F 的總數，不超過輸入的長度
+	zeroItems int
+	// 串流時 data 為目前的值已讀取的部分，需要時由 r 補充。base 為 data[0]
+	// 在輸入中的位置
+	r    *bufio.Reader
+	base int
+	err  error
+}
+
+func (d *decoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrInvalidUBJSON, d.base+d.off, fmt.Sprintf(format, args...))
+}
+
+// more 回傳 off 之後是否有 n bytes，串流時先由 r 讀取不足的部分。data 隨
+// 實際讀到的資料成長，不以 n 預先配置
+func (d *decoder) more(n int) bool {
+	if avail := len(d.data) - d.off; avail < n && d.r != nil && d.err == nil {
+		buf := bytes.NewBuffer(d.data)
+		_, d.err = buf.ReadFrom(io.LimitReader(d.r, int64(n-avail)))
+		d.data = buf.Bytes()
+	}
+	return len(d.data)-d.off >= n
+}
+
+// next 捨棄已讀取的值，串流的下一個值由 data[0] 開始
+func (d *decoder) next() {
+	d.base += d.off
+	d.data = d.data[d.off:]
+	d.off = 0
+	d.zeroItems = 0
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if n < 0 || !d.more(n) {
+		if d.err != nil {
+			return nil, d.err
+		}
+		return nil, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+func (d *decoder) marker() (byte, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// peek 回傳下一個 marker 但不前進，資料結束時為 0
+func (d *decoder) peek() byte {
+	if !d.more(1) {
+		return 0
+	}
+	return d.data[d.off]
+}
+
+func (d *decoder) skipNoOp() {
+	for d.peek() == markerNoOp {
+		d.off++
+	}
+}
+
+func (d *decoder) value() (msgpack.Value, error) {
+	m, err := d.marker()
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	return d.typed(m)
+}
+
+// typed 讀取 marker 為 m 的值，marker 本身已讀取，或是由 container 的 $
+// 指定而省略
+func (d *decoder) typed(m byte) (msgpack.Value, error) {
+	switch m {
+	case markerNull:
+		return msgpack.Nil(), nil
+	case markerTrue, markerFalse:
+		return msgpack.Bool(m == markerTrue), nil
+	case markerInt8, markerUint8, markerInt16, markerInt32, markerInt64:
+		n, err := d.int(m)
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		return msgpack.Int(n), nil
+	case markerFloat32:
+		b, err := d.read(4)
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		return msgpack.Float32(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case markerFloat64:
+		b, err := d.read(8)
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		return msgpack.Float64(math.Float64frombits(binary.BigEndian.Uint64(b))), nil
+	case markerHighPrecision:
+		return d.highPrecision()
+	case markerChar:
+		b, err := d.read(1)
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		if b[0] >= utf8.RuneSelf {
+			d.off--
+			return msgpack.Value{}, d.errorf("char 0x%02x is not ASCII", b[0])
+		}
+		return msgpack.Str(string(b)), nil
+	case markerString:
+		s, err := d.string()
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		return msgpack.Str(s), nil
+	case markerArray:
+		return d.array()
+	case markerObject:
+		return d.object()
+	}
+	d.off--
+	return msgpack.Value{}, d.errorf("unexpected marker %q", m)
+}
+
+func (d *decoder) int(m byte) (int64, error) {
+	size := 1
+	switch m {
+	case markerInt16:
+		size = 2
+	case markerInt32:
+		size = 4
+	case markerInt64:
+		size = 8
+	}
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	switch m {
+	case markerInt8:
+		return int64(int8(b[0])), nil
+	case markerUint8:
+		return int64(b[0]), nil
+	case markerInt16:
+		return int64(int16(binary.BigEndian.Uint16(b))), nil
+	case markerInt32:
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+// length 讀取 str、high-precision number 或 container 的長度，長度為帶
+// marker 的非負整數
+func (d *decoder) length() (int, error) {
+	start := d.off
+	m, err := d.marker()
+	if err != nil {
+		return 0, err
+	}
+	switch m {
+	case markerInt8, markerUint8, markerInt16, markerInt32, markerInt64:
+	default:
+		d.off = start
+		return 0, d.errorf("length marker %q is not an integer", m)
+	}
+	n, err := d.int(m)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || uint64(n) > math.MaxInt32 {
+		d.off = start
+		return 0, d.errorf("length %d out of range", n)
+	}
+	return int(n), nil
+}
+
+func (d *decoder) string() (string, error) {
+	n, err := d.length()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.read(n)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		d.off -= n
+		return "", d.errorf("string is not valid UTF-8")
+	}
+	return string(b), nil
+}
+
+// highPrecision 以 JSON number 的語法讀取數字，64 bits 無法精確表示的數字
+// 為 BigIntExtType 或 BigFloatExtType 的 ext
+func (d *decoder) highPrecision() (msgpack.Value, error) {
+	start := d.off
+	s, err := d.string()
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	// JSON 允許數字前後的空白，這裡不允許
+	if s == "" || strings.Trim(s, "0123456789+-.eE") != "" {
+		d.off = start
+		return msgpack.Value{}, d.errorf("high-precision number %q", s)
+	}
+	b, err := msgpack.FromJSONWithOptions([]byte(s), msgpack.FromJSONOptions{
+		UseNumberLiterals: true,
+		BigNumbers:        msgpack.BigNumbersExt,
+	})
+	if err != nil {
+		d.off = start
+		return msgpack.Value{}, d.errorf("high-precision number %q", s)
+	}
+	return msgpack.DecodeValue(b)
+}
+
+// container 讀取 [ 或 { 之後的 $ 與 #，沒有 # 時 count 為 -1
+func (d *decoder) container(object bool) (typ byte, count int, err error) {
+	if d.peek() == markerType {
+		d.off++
+		if typ, err = d.marker(); err != nil {
+			return 0, 0, err
+		}
+		switch typ {
+		case markerNoOp, markerArrayEnd, markerObjectEnd, markerType, markerCount:
+			d.off--
+			return 0, 0, d.errorf("container type %q", typ)
+		}
+		if d.peek() != markerCount {
+			return 0, 0, d.errorf("container type without a count")
+		}
+	}
+	if d.peek() != markerCount {
+		return 0, -1, nil
+	}
+	d.off++
+	start := d.off
+	if count, err = d.length(); err != nil {
+		return 0, 0, err
+	}
+	// 除了 typed array 中的 Z、T、F 以外，每個值至少 1 byte，先確認長度合理
+	// 再配置記憶體。Z、T、F 共用整個輸入的長度，巢狀的 container 無法各自
+	// 展開為與輸入等長的 array
+	if !object && (typ == markerNull || typ == markerTrue || typ == markerFalse) {
+		if !d.more(d.zeroItems+count-d.off) || count > len(d.data)-d.zeroItems {
+			d.off = start
+			return 0, 0, d.errorf("count %d exceeds the data", count)
+		}
+		d.zeroItems += count
+	} else if !d.more(count) {
+		d.off = start
+		return 0, 0, d.errorf("count %d exceeds the data", count)
+	}
+	return typ, count, nil
+}
+
+// item 讀取 container 中的值，typ 為 0 時值帶有 marker
+func (d *decoder) item(typ byte) (msgpack.Value, error) {
+	if typ == 0 {
+		return d.value()
+	}
+	return d.typed(typ)
+}
+
+func (d *decoder) array() (msgpack.Value, error) {
+	typ, count, err := d.container(false)
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	// uint8 的 typed array 為 binary data
+	if typ == markerUint8 {
+		b, err := d.read(count)
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		return msgpack.Bin(append([]byte{}, b...)), nil
+	}
+	items := []msgpack.Value{}
+	for i := 0; count < 0 || i < count; i++ {
+		if count < 0 {
+			d.skipNoOp()
+			if d.peek() == markerArrayEnd {
+				d.off++
+				break
+			}
+		}
+		item, err := d.item(typ)
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		items = append(items, item)
+	}
+	return msgpack.Array(items...), nil
+}
+
+func (d *decoder) object() (msgpack.Value, error) {
+	typ, count, err := d.container(true)
+	if err != nil {
+		return msgpack.Value{}, err
+	}
+	entries := []msgpack.Entry{}
+	for i := 0; count < 0 || i < count; i++ {
+		if count < 0 {
+			d.skipNoOp()
+			if d.peek() == markerObjectEnd {
+				d.off++
+				break
+			}
+		}
+		// key 為省略 S marker 的 string
+		key, err := d.string()
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		v, err := d.item(typ)
+		if err != nil {
+			return msgpack.Value{}, err
+		}
+		entries = append(entries, msgpack.Entry{Key: msgpack.Str(key), Value: v})
+	}
+	return msgpack.Map(entries...), nil
+}
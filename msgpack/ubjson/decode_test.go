@@ -0,0 +1,90 @@
+package ubjson_test
+
+import (
+	"msgpackconv/msgpack"
+	. "msgpackconv/msgpack/ubjson"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		ubjson string
+		want   string
+	}{
+		{"null", "Z", `nil`},
+		{"bool", "[TF]", `[true, false]`},
+		{"int8", "i\xff", `-1`},
+		{"uint8", "U\xff", `255`},
+		{"int16", "I\x80\x00", `-32768`},
+		{"int32", "l\x00\x01\x00\x00", `65536`},
+		{"int64", "L\x00\x00\x00\x01\x00\x00\x00\x00", `4294967296`},
+		{"float32", "d\x3f\xc0\x00\x00", `1.5f32`},
+		{"float64", "D\x3f\xf1\x99\x99\x99\x99\x99\x9a", `1.1`},
+		{"char", "Ca", `"a"`},
+		{"string", "Si\x05hello", `"hello"`},
+		{"string with int16 length", "SI\x00\x02hi", `"hi"`},
+		{"high-precision int", "Hi\x0218", `18`},
+		{"high-precision float", "Hi\x031.5", `1.5`},
+		{"high-precision big int", "Hi\x1418446744073709551616", `ext(16, h'00010000000000000000')`},
+		{"array", "[i\x01Si\x01a[]]", `[1, "a", []]`},
+		{"object", "{i\x01aZi\x01b{}}", `{"a": nil, "b": {}}`},
+		{"no-op", "N[Ni\x01N]N", `[1]`},
+		{"counted array", "[#i\x02i\x01Z", `[1, nil]`},
+		{"typed array", "[$i#i\x03\x01\x02\x03", `[1, 2, 3]`},
+		{"typed null array", "[$Z#i\x02", `[nil, nil]`},
+		{"typed nested array", "[$[#i\x02#i\x00#i\x01Z", `[[], [nil]]`},
+		{"uint8 array", "[$U#i\x03\x01\x02\xff", `h'0102ff'`},
+		{"counted object", "{#i\x01i\x01aT", `{"a": true}`},
+		{"typed object", "{$d#i\x02i\x01x\x3f\xc0\x00\x00i\x01y\x40\x20\x00\x00", `{"x": 1.5f32, "y": 2.5f32}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := DecodeValue([]byte(tt.ubjson))
+			assert.NoError(t, err)
+			b, err := v.MarshalMsgpack()
+			assert.NoError(t, err)
+			diag, err := msgpack.ToDiagnostic(b)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, diag)
+		})
+	}
+}
+
+func TestDecodeValueFail(t *testing.T) {
+	tests := []struct {
+		name   string
+		ubjson string
+		err    string
+	}{
+		{"empty", "", "invalid UBJSON at offset 0: unexpected end of data"},
+		{"unknown marker", "x", `invalid UBJSON at offset 0: unexpected marker 'x'`},
+		{"trailing data", "ZZ", "invalid UBJSON: 1 bytes after the value"},
+		{"short int", "I\x00", "invalid UBJSON at offset 1: unexpected end of data"},
+		{"negative length", "Si\xff", "invalid UBJSON at offset 1: length -1 out of range"},
+		{"float length", "Sd\x00\x00\x00\x00", `invalid UBJSON at offset 1: length marker 'd' is not an integer`},
+		{"invalid UTF-8", "Si\x01\xff", "invalid UBJSON at offset 3: string is not valid UTF-8"},
+		{"non-ASCII char", "C\xe9", "invalid UBJSON at offset 1: char 0xe9 is not ASCII"},
+		{"high-precision text", "Hi\x03abc", `invalid UBJSON at offset 1: high-precision number "abc"`},
+		{"high-precision syntax", "Hi\x021-", `invalid UBJSON at offset 1: high-precision number "1-"`},
+		{"unclosed array", "[Z", "invalid UBJSON at offset 2: unexpected end of data"},
+		{"closed by }", "[}", `invalid UBJSON at offset 1: unexpected marker '}'`},
+		{"type without count", "[$iZ", "invalid UBJSON at offset 3: container type without a count"},
+		{"invalid type", "[$N#i\x00", `invalid UBJSON at offset 2: container type 'N'`},
+		{"count beyond data", "[#U\xffZ", "invalid UBJSON at offset 2: count 255 exceeds the data"},
+		{"typed null count beyond data", "[$Z#I\x7f\xff", "invalid UBJSON at offset 4: count 32767 exceeds the data"},
+		// 1000 個 4096 個 null 的 array，null 的總數受輸入的長度限制
+		{"nested typed null arrays", "[$[#I\x03\xe8" + strings.Repeat("$Z#I\x10\x00", 1000), "invalid UBJSON at offset 16: count 4096 exceeds the data"},
+		{"key with marker", "{Si\x01aZ}", `invalid UBJSON at offset 1: length marker 'S' is not an integer`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeValue([]byte(tt.ubjson))
+			assert.ErrorIs(t, err, ErrInvalidUBJSON)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
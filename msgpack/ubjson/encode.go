@@ -0,0 +1,152 @@
+package ubjson
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"msgpackconv/msgpack"
+)
+
+// EncodeValue encodes v as UBJSON. Ints use the smallest integer type, bin
+// is a strongly typed uint8 array and msgpack.BigIntExtType and
+// msgpack.BigFloatExtType exts are high-precision numbers. Other exts, str
+// that is not valid UTF-8 and maps with keys other than str fail with
+// ErrUnrepresentable.
+func EncodeValue(v msgpack.Value, opts Options) ([]byte, error) {
+	return appendValue(nil, v, opts)
+}
+
+func appendInt(ans []byte, i int64) []byte {
+	switch {
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(ans, markerInt8, byte(i))
+	case i >= 0 && i <= math.MaxUint8:
+		return append(ans, markerUint8, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(ans, markerInt16), uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(ans, markerInt32), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(ans, markerInt64), uint64(i))
+}
+
+// appendString 寫入省略 S marker 的 string，用於 S、H 與 object 的 key
+func appendString(ans []byte, s string) []byte {
+	return append(appendInt(ans, int64(len(s))), s...)
+}
+
+func appendValue(ans []byte, v msgpack.Value, opts Options) ([]byte, error) {
+	switch v.Kind() {
+	case msgpack.NilKind:
+		return append(ans, markerNull), nil
+	case msgpack.BoolKind:
+		if b, _ := v.Bool(); b {
+			return append(ans, markerTrue), nil
+		}
+		return append(ans, markerFalse), nil
+	case msgpack.IntKind:
+		i, _ := v.Int()
+		return appendInt(ans, i), nil
+	case msgpack.UintKind:
+		u, _ := v.Uint()
+		if u > math.MaxInt64 {
+			return appendString(append(ans, markerHighPrecision), strconv.FormatUint(u, 10)), nil
+		}
+		return appendInt(ans, int64(u)), nil
+	case msgpack.Float32Kind:
+		f, _ := v.Float()
+		return binary.BigEndian.AppendUint32(append(ans, markerFloat32), math.Float32bits(float32(f))), nil
+	case msgpack.Float64Kind:
+		f, _ := v.Float()
+		return binary.BigEndian.AppendUint64(append(ans, markerFloat64), math.Float64bits(f)), nil
+	case msgpack.StrKind:
+		s, _ := v.Str()
+		if !utf8.ValidString(s) {
+			return nil, fmt.Errorf("%w: str %q is not valid UTF-8", ErrUnrepresentable, s)
+		}
+		return appendString(append(ans, markerString), s), nil
+	case msgpack.BinKind:
+		b, _ := v.Bytes()
+		ans = appendInt(append(ans, markerArray, markerType, markerUint8, markerCount), int64(len(b)))
+		return append(ans, b...), nil
+	case msgpack.ArrayKind:
+		items := make([][]byte, 0, v.Len())
+		for _, item := range v.Elements() {
+			b, err := appendValue(nil, item, opts)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, b)
+		}
+		return appendContainer(ans, markerArray, markerArrayEnd, nil, items, opts), nil
+	case msgpack.MapKind:
+		keys := make([]string, 0, v.Len())
+		values := make([][]byte, 0, v.Len())
+		for key, val := range v.Entries() {
+			k, ok := key.Str()
+			if !ok {
+				return nil, fmt.Errorf("%w: map key of kind %s", ErrUnrepresentable, key.Kind())
+			}
+			b, err := appendValue(nil, val, opts)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+			values = append(values, b)
+		}
+		return appendContainer(ans, markerObject, markerObjectEnd, keys, values, opts), nil
+	case msgpack.ExtKind:
+		typ, _ := v.ExtType()
+		if typ != msgpack.BigIntExtType && typ != msgpack.BigFloatExtType {
+			return nil, fmt.Errorf("%w: ext type %d", ErrUnrepresentable, typ)
+		}
+		// JSON 的轉換將 bignum 寫為精確的數字
+		text, err := v.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return appendString(append(ans, markerHighPrecision), string(text)), nil
+	}
+	return nil, fmt.Errorf("%w: value of kind %s", ErrUnrepresentable, v.Kind())
+}
+
+// appendContainer 寫入已編碼的值，keys 為 nil 時為 array。optimize 時寫入
+// count，所有值的 marker 相同時寫入 type 並省略各個值的 marker。typed
+// uint8 array 會讀回為 bin，因此 array 不以 U 為 type；讀取時 Z、T、F 的
+// typed array 長度不能超過資料本身，因此也不以它們為 type
+func appendContainer(ans []byte, start, end byte, keys []string, values [][]byte, opts Options) []byte {
+	ans = append(ans, start)
+	typed := false
+	if opts.Optimize {
+		typed = len(values) > 0
+		if typed && keys == nil {
+			switch values[0][0] {
+			case markerUint8, markerNull, markerTrue, markerFalse:
+				typed = false
+			}
+		}
+		for _, b := range values {
+			typed = typed && b[0] == values[0][0]
+		}
+		if typed {
+			ans = append(ans, markerType, values[0][0])
+		}
+		ans = appendInt(append(ans, markerCount), int64(len(values)))
+	}
+	for i, b := range values {
+		if keys != nil {
+			ans = appendString(ans, keys[i])
+		}
+		if typed {
+			b = b[1:]
+		}
+		ans = append(ans, b...)
+	}
+	if !opts.Optimize {
+		ans = append(ans, end)
+	}
+	return ans
+}
@@ -0,0 +1,140 @@
+package ubjson_test
+
+import (
+	"bytes"
+	"io"
+	"msgpackconv/msgpack"
+	. "msgpackconv/msgpack/ubjson"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		diag   string
+		opts   Options
+		ubjson string
+	}{
+		{"null", `nil`, Options{}, "Z"},
+		{"bool", `[true, false]`, Options{}, "[TF]"},
+		{"int8", `-128`, Options{}, "i\x80"},
+		{"uint8", `200`, Options{}, "U\xc8"},
+		{"int16", `-129`, Options{}, "I\xff\x7f"},
+		{"int32", `65536`, Options{}, "l\x00\x01\x00\x00"},
+		{"int64", `-4294967296`, Options{}, "L\xff\xff\xff\xff\x00\x00\x00\x00"},
+		{"uint beyond int64", `18446744073709551615`, Options{}, "Hi\x1418446744073709551615"},
+		{"float32", `1.5f32`, Options{}, "d\x3f\xc0\x00\x00"},
+		{"float64", `1.1`, Options{}, "D\x3f\xf1\x99\x99\x99\x99\x99\x9a"},
+		{"str", `"hello"`, Options{}, "Si\x05hello"},
+		{"bin", `h'01ff'`, Options{}, "[$U#i\x02\x01\xff"},
+		{"big int", `ext(16, h'00010000000000000000')`, Options{}, "Hi\x1418446744073709551616"},
+		{"array", `[1, "a", []]`, Options{}, "[i\x01Si\x01a[]]"},
+		{"map", `{"a": nil, "b": {}}`, Options{}, "{i\x01aZi\x01b{}}"},
+		{"counted array", `[1, nil]`, Options{Optimize: true}, "[#i\x02i\x01Z"},
+		{"typed array", `[1, 2, 3]`, Options{Optimize: true}, "[$i#i\x03\x01\x02\x03"},
+		{"uint8 array is not typed", `[200, 201]`, Options{Optimize: true}, "[#i\x02U\xc8U\xc9"},
+		{"typed nested array", `[[], [nil]]`, Options{Optimize: true}, "[$[#i\x02#i\x00#i\x01Z"},
+		{"empty array", `[]`, Options{Optimize: true}, "[#i\x00"},
+		{"typed map", `{"x": 1.5f32, "y": 2.5f32}`, Options{Optimize: true},
+			"{$d#i\x02i\x01x\x3f\xc0\x00\x00i\x01y\x40\x20\x00\x00"},
+		{"typed uint8 map", `{"a": 200}`, Options{Optimize: true}, "{$U#i\x01i\x01a\xc8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := msgpack.FromDiagnostic(tt.diag)
+			assert.NoError(t, err)
+			v, err := msgpack.DecodeValue(b)
+			assert.NoError(t, err)
+			got, err := EncodeValue(v, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.ubjson, string(got))
+
+			back, err := ToMsgpack(got)
+			assert.NoError(t, err)
+			diag, err := msgpack.ToDiagnostic(back)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.diag, diag)
+		})
+	}
+}
+
+func TestEncodeValueFail(t *testing.T) {
+	tests := []struct {
+		name  string
+		value msgpack.Value
+	}{
+		{"timestamp", msgpack.Timestamp(time.Unix(1, 0))},
+		{"ext", msgpack.Ext(5, []byte{1})},
+		{"invalid UTF-8", msgpack.Str("\xff")},
+		{"int key", msgpack.Map(msgpack.Entry{Key: msgpack.Int(1), Value: msgpack.Nil()})},
+		{"nested", msgpack.Array(msgpack.Map(msgpack.Pair("t", msgpack.Ext(5, nil))))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := EncodeValue(tt.value, Options{})
+			assert.ErrorIs(t, err, ErrUnrepresentable)
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	msgpackconv := []byte{
+		0x82, 0xa1, 0x61, 0x01, 0xa1, 0x62, 0x92, 0xc3, 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		0xc4, 0x02, 0x01, 0x02,
+		0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}
+	var ubjson bytes.Buffer
+	assert.NoError(t, FromMsgpackStream(&ubjson, bytes.NewReader(msgpackconv), Options{Optimize: true}))
+	assert.Equal(t, "{#i\x02i\x01ai\x01i\x01b[#i\x02TD\x3f\xf8\x00\x00\x00\x00\x00\x00"+
+		"[$U#i\x02\x01\x02"+
+		"Hi\x1418446744073709551615", ubjson.String())
+
+	var back bytes.Buffer
+	assert.NoError(t, ToMsgpackStream(&back, bytes.NewReader(append([]byte("N"), ubjson.Bytes()...))))
+	assert.Equal(t, msgpackconv, back.Bytes())
+
+	assert.ErrorIs(t, ToMsgpackStream(&back, bytes.NewReader([]byte("Z["))), ErrInvalidUBJSON)
+	assert.ErrorIs(t, FromMsgpackStream(&back, bytes.NewReader([]byte{0xd4, 0x05, 0x00}), Options{}), ErrUnrepresentable)
+	_, err := FromMsgpack([]byte{0xc1})
+	assert.ErrorIs(t, err, msgpack.ErrInvalidMsgPack)
+	// offset 由串流的開頭計算
+	assert.EqualError(t, ToMsgpackStream(&back, strings.NewReader("ZZx")), "invalid UBJSON at offset 2: unexpected marker 'x'")
+}
+
+// firstWriteSignal 在第一次寫入時關閉 started
+type firstWriteSignal struct {
+	bytes.Buffer
+	started chan struct{}
+}
+
+func (w *firstWriteSignal) Write(b []byte) (int, error) {
+	if w.Len() == 0 {
+		close(w.started)
+	}
+	return w.Buffer.Write(b)
+}
+
+func TestToMsgpackStreamIncremental(t *testing.T) {
+	// 每個值讀取後即轉換，不等待輸入結束
+	r, w := io.Pipe()
+	out := &firstWriteSignal{started: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() { done <- ToMsgpackStream(out, r) }()
+	s := strings.Repeat("a", 5000)
+	w.Write([]byte("SI\x13\x88" + s))
+	select {
+	case <-out.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no output before the end of the input")
+	}
+	w.Write([]byte("Z"))
+	w.Close()
+	assert.NoError(t, <-done)
+	want, err := msgpack.Str(s).MarshalMsgpack()
+	assert.NoError(t, err)
+	assert.Equal(t, append(want, 0xc0), out.Bytes())
+}
@@ -0,0 +1,125 @@
+// Package ubjson converts Universal Binary JSON (draft 12) to message pack
+// and back through msgpack.Value. Strongly typed uint8 arrays are bin,
+// high-precision numbers become ints or msgpack.BigIntExtType and
+// msgpack.BigFloatExtType exts, and uint values beyond int64 are written
+// as high-precision numbers.
+package ubjson
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"msgpackconv/msgpack"
+)
+
+var (
+	ErrInvalidUBJSON   = errors.New("invalid UBJSON")
+	ErrUnrepresentable = errors.New("value has no UBJSON representation")
+)
+
+// Options controls how values are encoded as UBJSON.
+type Options struct {
+	// Optimize writes every array and object with a count (#) instead of
+	// an end marker, and with a type ($) when all of its values have the
+	// same marker, so the markers are not repeated. Arrays of uint8, null
+	// or booleans are counted but not typed.
+	Optimize bool
+}
+
+// markers
+const (
+	markerNull          = 'Z'
+	markerNoOp          = 'N'
+	markerTrue          = 'T'
+	markerFalse         = 'F'
+	markerInt8          = 'i'
+	markerUint8         = 'U'
+	markerInt16         = 'I'
+	markerInt32         = 'l'
+	markerInt64         = 'L'
+	markerFloat32       = 'd'
+	markerFloat64       = 'D'
+	markerHighPrecision = 'H'
+	markerChar          = 'C'
+	markerString        = 'S'
+	markerArray         = '['
+	markerArrayEnd      = ']'
+	markerObject        = '{'
+	markerObjectEnd     = '}'
+	markerType          = '$'
+	markerCount         = '#'
+)
+
+// ToMsgpack converts exactly one UBJSON value to message pack.
+func ToMsgpack(data []byte) ([]byte, error) {
+	v, err := DecodeValue(data)
+	if err != nil {
+		return nil, err
+	}
+	return v.MarshalMsgpack()
+}
+
+// FromMsgpack converts exactly one message pack value to UBJSON.
+func FromMsgpack(msgpackconv []byte) ([]byte, error) {
+	return FromMsgpackWithOptions(msgpackconv, Options{})
+}
+
+func FromMsgpackWithOptions(msgpackconv []byte, opts Options) ([]byte, error) {
+	v, err := msgpack.DecodeValue(msgpackconv)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeValue(v, opts)
+}
+
+// ToMsgpackStream converts a sequence of UBJSON values to a sequence of
+// message pack values, converting each value as soon as it is read.
+func ToMsgpackStream(dst io.Writer, src io.Reader) error {
+	w := bufio.NewWriter(dst)
+	d := &decoder{r: bufio.NewReader(src)}
+	for {
+		// 值之間可以有 no-op
+		d.skipNoOp()
+		if !d.more(1) {
+			if d.err != nil {
+				return d.err
+			}
+			return w.Flush()
+		}
+		v, err := d.value()
+		if err != nil {
+			return err
+		}
+		d.next()
+		b, err := v.MarshalMsgpack()
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+}
+
+// FromMsgpackStream converts a sequence of message pack values to a
+// sequence of UBJSON values.
+func FromMsgpackStream(dst io.Writer, src io.Reader, opts Options) error {
+	dec := msgpack.NewDecoder(src)
+	w := bufio.NewWriter(dst)
+	for {
+		var v msgpack.Value
+		if err := dec.Decode(&v); err == io.EOF {
+			return w.Flush()
+		} else if err != nil {
+			return err
+		}
+		b, err := EncodeValue(v, opts)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+}
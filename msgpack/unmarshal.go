@@ -0,0 +1,547 @@
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Unmarshaler is implemented by types that decode themselves. The input is
+// the complete encoding of a single value and must be copied if retained.
+type Unmarshaler interface {
+	UnmarshalMsgpack([]byte) error
+}
+
+// Unmarshal decodes message pack data into the value pointed to by v.
+//
+// It follows the rules of Marshal in reverse: Unmarshaler is used at any
+// depth, str is passed to an encoding.TextUnmarshaler and bin to an
+// encoding.BinaryUnmarshaler. Struct fields are matched by name, falling
+// back to a case-insensitive match, and unknown keys are skipped; an array
+// fills the fields in declaration order and must have one element per
+// field, whether or not the struct has the asarray tag. Into an
+// empty interface, ints decode as int64, uints as uint64, bin as []byte,
+// maps with str keys as map[string]interface{} and other maps as
+// map[interface{}]interface{}; ext values decode as Value.
+func Unmarshal(msgpackconv []byte, v interface{}) error {
+	return UnmarshalWithOptions(msgpackconv, v, UnmarshalOptions{})
+}
+
+// UnmarshalOptions changes how UnmarshalWithOptions decodes. The zero value
+// gives the behavior of Unmarshal.
+type UnmarshalOptions struct {
+	// DuplicateKeys selects what happens when a map decoded into a Go map,
+	// struct or empty interface repeats a key.
+	DuplicateKeys DuplicateKeyPolicy
+}
+
+// UnmarshalWithOptions decodes message pack data into the value pointed to
+// by v like Unmarshal, with the policies selected by opts.
+func UnmarshalWithOptions(msgpackconv []byte, v interface{}, opts UnmarshalOptions) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: %T", ErrInvalidUnmarshal, v)
+	}
+	d := decodeState{duplicateKeys: opts.DuplicateKeys}
+	n, err := d.unmarshalValue(msgpackconv, rv.Elem())
+	if err != nil {
+		return err
+	}
+	if n != len(msgpackconv) {
+		return ErrInvalidMsgPack
+	}
+	return nil
+}
+
+// A Decoder reads message pack values from an input stream.
+type Decoder struct {
+	r *bufio.Reader
+	decodeState
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// SetDuplicateKeys selects what happens when a map decoded into a Go map,
+// struct or empty interface repeats a key. By default the last value is
+// kept.
+func (dec *Decoder) SetDuplicateKeys(policy DuplicateKeyPolicy) {
+	dec.duplicateKeys = policy
+}
+
+// Decode reads the next value from the stream and stores it in the value
+// pointed to by v with the rules of Unmarshal. At the end of the stream it
+// returns io.EOF.
+func (dec *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: %T", ErrInvalidUnmarshal, v)
+	}
+	if _, err := dec.r.Peek(1); err == io.EOF {
+		return io.EOF
+	}
+	data, err := dec.readValue()
+	if err != nil {
+		return err
+	}
+	_, err = dec.unmarshalValue(data, rv.Elem())
+	return err
+}
+
+// readValue 依照 header 讀取下一個完整的值
+func (dec *Decoder) readValue() ([]byte, error) {
+	var buf bytes.Buffer
+	for remaining := 1; remaining > 0; remaining-- {
+		h, err := peekHeader(dec.r)
+		if err != nil {
+			return nil, err
+		}
+		n := h.size + h.length
+		switch h.format {
+		case "fixarray", "array16", "array32":
+			remaining += h.length
+			n = h.size
+		case "fixmap", "map16", "map32":
+			remaining += 2 * h.length
+			n = h.size
+		}
+		if _, err := io.CopyN(&buf, dec.r, int64(n)); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// decoderFunc 將 msgpackconv 的第一個值解碼到 rv，回傳讀取的 byte 數
+type decoderFunc func(d *decodeState, msgpackconv []byte, rv reflect.Value) (int, error)
+
+// decodeState 保存解碼時的設定
+type decodeState struct {
+	duplicateKeys DuplicateKeyPolicy
+}
+
+func (d *decodeState) unmarshalValue(msgpackconv []byte, rv reflect.Value) (int, error) {
+	return cachedCodec(rv.Type()).decode(d, msgpackconv, rv)
+}
+
+var (
+	unmarshalerType       = reflect.TypeFor[Unmarshaler]()
+	binaryUnmarshalerType = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+	textUnmarshalerType   = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+func newDecoder(t reflect.Type) decoderFunc {
+	isPointer := t.Kind() == reflect.Pointer
+	checkUnmarshaler := !isPointer && mayImplement(t, unmarshalerType)
+	checkEncoding := !isPointer && (mayImplement(t, textUnmarshalerType) || mayImplement(t, binaryUnmarshalerType))
+	dec := kindDecoder(t)
+	return func(d *decodeState, msgpackconv []byte, rv reflect.Value) (int, error) {
+		if checkUnmarshaler {
+			if u, ok := implementer[Unmarshaler](rv); ok {
+				n, err := skip(msgpackconv)
+				if err != nil {
+					return 0, err
+				}
+				return n, u.UnmarshalMsgpack(msgpackconv[:n])
+			}
+		}
+
+		h, err := readHeader(msgpackconv)
+		if err != nil {
+			return 0, err
+		}
+		if checkEncoding {
+			if n, ok, err := unmarshalEncoding(msgpackconv, h, rv); ok {
+				return n, err
+			}
+		}
+		if h.format == "nil" {
+			switch rv.Kind() {
+			case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+				rv.SetZero()
+			}
+			return 1, nil
+		}
+		return dec(d, msgpackconv, h, rv)
+	}
+}
+
+// kindDecoder 回傳依照 t 的 Kind 解碼非 nil 值的函式
+func kindDecoder(t reflect.Type) func(*decodeState, []byte, header, reflect.Value) (int, error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := cachedCodec(t.Elem())
+		return func(d *decodeState, msgpackconv []byte, h header, rv reflect.Value) (int, error) {
+			if rv.IsNil() {
+				rv.Set(reflect.New(t.Elem()))
+			}
+			return elem.decode(d, msgpackconv, rv.Elem())
+		}
+	case reflect.Interface:
+		return func(d *decodeState, msgpackconv []byte, h header, rv reflect.Value) (int, error) {
+			if rv.NumMethod() != 0 {
+				return 0, fmt.Errorf("%w: %s", ErrUnsupportedType, rv.Type())
+			}
+			val, n, err := decodeValue(msgpackconv)
+			if err != nil {
+				return 0, err
+			}
+			i, err := d.interfaceOf(val)
+			if err != nil {
+				return 0, err
+			}
+			if i == nil {
+				rv.SetZero()
+			} else {
+				rv.Set(reflect.ValueOf(i))
+			}
+			return n, nil
+		}
+	}
+
+	var decodeArray, decodeMap func(*decodeState, []byte, header, reflect.Value) (int, error)
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		decodeArray = arrayDecoder(t)
+	case reflect.Struct:
+		decodeArray, decodeMap = structDecoder(t)
+	case reflect.Map:
+		decodeMap = mapDecoder(t)
+	}
+	return func(d *decodeState, msgpackconv []byte, h header, rv reflect.Value) (int, error) {
+		switch h.format {
+		case "fixarray", "array16", "array32":
+			if decodeArray == nil {
+				return 0, fmt.Errorf("%w: array into %s", ErrUnmarshalType, rv.Type())
+			}
+			return decodeArray(d, msgpackconv, h, rv)
+		case "fixmap", "map16", "map32":
+			if decodeMap == nil {
+				return 0, fmt.Errorf("%w: map into %s", ErrUnmarshalType, rv.Type())
+			}
+			return decodeMap(d, msgpackconv, h, rv)
+		}
+		val, n, err := decodeValue(msgpackconv)
+		if err != nil {
+			return 0, err
+		}
+		return n, setScalar(val, rv)
+	}
+}
+
+// unmarshalEncoding 將 str 交給 encoding.TextUnmarshaler，bin 交給
+// encoding.BinaryUnmarshaler
+func unmarshalEncoding(msgpackconv []byte, h header, rv reflect.Value) (int, bool, error) {
+	idxOfEnd := h.size + h.length
+	switch h.format {
+	case "fixstr", "str8", "str16", "str32":
+		u, ok := implementer[encoding.TextUnmarshaler](rv)
+		if !ok {
+			return 0, false, nil
+		}
+		if len(msgpackconv) < idxOfEnd {
+			return 0, true, ErrInvalidMsgPack
+		}
+		return idxOfEnd, true, u.UnmarshalText(msgpackconv[h.size:idxOfEnd])
+	case "bin8", "bin16", "bin32":
+		u, ok := implementer[encoding.BinaryUnmarshaler](rv)
+		if !ok {
+			return 0, false, nil
+		}
+		if len(msgpackconv) < idxOfEnd {
+			return 0, true, ErrInvalidMsgPack
+		}
+		return idxOfEnd, true, u.UnmarshalBinary(msgpackconv[h.size:idxOfEnd])
+	}
+	return 0, false, nil
+}
+
+func setScalar(val Value, rv reflect.Value) error {
+	ok := false
+	switch rv.Kind() {
+	case reflect.Bool:
+		var b bool
+		if b, ok = val.Bool(); ok {
+			rv.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, ok = val.Int(); ok && !rv.OverflowInt(i) {
+			rv.SetInt(i)
+		} else {
+			ok = false
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		if u, ok = val.Uint(); ok && !rv.OverflowUint(u) {
+			rv.SetUint(u)
+		} else {
+			ok = false
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, ok = val.Float()
+		// FromJSON 會把整數值的 float 編碼為 int
+		if i, isInt := val.Int(); isInt {
+			f, ok = float64(i), true
+		} else if u, isUint := val.Uint(); isUint {
+			f, ok = float64(u), true
+		}
+		if ok {
+			rv.SetFloat(f)
+		}
+	case reflect.String:
+		var s string
+		if s, ok = val.Str(); ok {
+			rv.SetString(s)
+		} else if val.Kind() == BinKind {
+			rv.SetString(string(val.bytes))
+			ok = true
+		}
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			var s string
+			if s, ok = val.Str(); ok {
+				rv.SetBytes([]byte(s))
+			} else if val.Kind() == BinKind {
+				rv.SetBytes(val.bytes)
+				ok = true
+			}
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s into %s", ErrUnmarshalType, val.Kind(), rv.Type())
+	}
+	return nil
+}
+
+func arrayDecoder(t reflect.Type) func(*decodeState, []byte, header, reflect.Value) (int, error) {
+	elem := cachedCodec(t.Elem())
+	return func(d *decodeState, msgpackconv []byte, h header, rv reflect.Value) (int, error) {
+		if rv.Kind() == reflect.Slice {
+			rv.Set(reflect.MakeSlice(rv.Type(), 0, min(h.length, len(msgpackconv))))
+		}
+
+		idxOfEnd := h.size
+		for i := range h.length {
+			var n int
+			var err error
+			switch {
+			case rv.Kind() == reflect.Slice:
+				rv.Set(reflect.Append(rv, reflect.Zero(rv.Type().Elem())))
+				n, err = elem.decode(d, msgpackconv[idxOfEnd:], rv.Index(i))
+			case i < rv.Len():
+				n, err = elem.decode(d, msgpackconv[idxOfEnd:], rv.Index(i))
+			default:
+				// Go array 的長度不足，略過多的元素
+				n, err = skip(msgpackconv[idxOfEnd:])
+			}
+			if err != nil {
+				return 0, err
+			}
+			idxOfEnd += n
+		}
+		for i := h.length; rv.Kind() == reflect.Array && i < rv.Len(); i++ {
+			rv.Index(i).SetZero()
+		}
+		return idxOfEnd, nil
+	}
+}
+
+// structDecoder 回傳將 array 與 map 解碼到 struct 的函式
+func structDecoder(t reflect.Type) (func(*decodeState, []byte, header, reflect.Value) (int, error), func(*decodeState, []byte, header, reflect.Value) (int, error)) {
+	fields := cachedStruct(t).fields
+	codecs := make([]*codec, len(fields))
+	for i, f := range fields {
+		codecs[i] = cachedCodec(t.Field(f.index).Type)
+	}
+
+	decodeArray := func(d *decodeState, msgpackconv []byte, h header, rv reflect.Value) (int, error) {
+		if h.length != len(fields) {
+			return 0, fmt.Errorf("%w: array of %d elements into %s with %d fields", ErrArrayLength, h.length, rv.Type(), len(fields))
+		}
+		idxOfEnd := h.size
+		for i, f := range fields {
+			n, err := codecs[i].decode(d, msgpackconv[idxOfEnd:], rv.Field(f.index))
+			if err != nil {
+				return 0, err
+			}
+			idxOfEnd += n
+		}
+		return idxOfEnd, nil
+	}
+
+	decodeMap := func(d *decodeState, msgpackconv []byte, h header, rv reflect.Value) (int, error) {
+		keys := keyTracker[string]{policy: d.duplicateKeys}
+		idxOfEnd := h.size
+		for range h.length {
+			key, n, err := decodeValue(msgpackconv[idxOfEnd:])
+			if err != nil {
+				return 0, err
+			}
+			idxOfEnd += n
+			name, isStr := key.Str()
+			ignore := false
+			if isStr {
+				if ignore, err = keys.ignore(name); err != nil {
+					return 0, err
+				}
+			}
+			if i, ok := fieldByName(fields, name); ok && !ignore {
+				n, err = codecs[i].decode(d, msgpackconv[idxOfEnd:], rv.Field(fields[i].index))
+			} else {
+				n, err = skip(msgpackconv[idxOfEnd:])
+			}
+			if err != nil {
+				return 0, err
+			}
+			idxOfEnd += n
+		}
+		return idxOfEnd, nil
+	}
+	return decodeArray, decodeMap
+}
+
+func mapDecoder(t reflect.Type) func(*decodeState, []byte, header, reflect.Value) (int, error) {
+	key, elem := cachedCodec(t.Key()), cachedCodec(t.Elem())
+	return func(d *decodeState, msgpackconv []byte, h header, rv reflect.Value) (int, error) {
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(t))
+		}
+		keys := keyTracker[any]{policy: d.duplicateKeys}
+		idxOfEnd := h.size
+		for range h.length {
+			k := reflect.New(t.Key()).Elem()
+			n, err := key.decode(d, msgpackconv[idxOfEnd:], k)
+			if err != nil {
+				return 0, err
+			}
+			idxOfEnd += n
+			// array 或 map 的 key 解碼到 interface{} 時無法存入 map
+			if !k.Comparable() {
+				return 0, fmt.Errorf("%w: %s map key into %s", ErrUnmarshalType, k.Elem().Kind(), rv.Type())
+			}
+			ignore, err := keys.ignore(k.Interface())
+			if err != nil {
+				return 0, err
+			}
+			if ignore {
+				if n, err = skip(msgpackconv[idxOfEnd:]); err != nil {
+					return 0, err
+				}
+				idxOfEnd += n
+				continue
+			}
+			v := reflect.New(t.Elem()).Elem()
+			if n, err = elem.decode(d, msgpackconv[idxOfEnd:], v); err != nil {
+				return 0, err
+			}
+			idxOfEnd += n
+			rv.SetMapIndex(k, v)
+		}
+		return idxOfEnd, nil
+	}
+}
+
+// fieldByName 回傳名稱相同的欄位，其次為不分大小寫相同的欄位
+func fieldByName(fields []field, name string) (int, bool) {
+	for i, f := range fields {
+		if f.name == name {
+			return i, true
+		}
+	}
+	for i, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// interfaceOf 將 Value 轉為 Unmarshal 到 empty interface 時使用的 Go 值
+func (d *decodeState) interfaceOf(val Value) (interface{}, error) {
+	switch val.kind {
+	case NilKind:
+		return nil, nil
+	case BoolKind:
+		b, _ := val.Bool()
+		return b, nil
+	case IntKind:
+		return int64(val.num), nil
+	case UintKind:
+		return val.num, nil
+	case Float32Kind:
+		f, _ := val.Float()
+		return float32(f), nil
+	case Float64Kind:
+		f, _ := val.Float()
+		return f, nil
+	case StrKind:
+		return val.str, nil
+	case BinKind:
+		return val.bytes, nil
+	case ArrayKind:
+		s := make([]interface{}, len(val.items))
+		for i, item := range val.items {
+			var err error
+			if s[i], err = d.interfaceOf(item); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case MapKind:
+		strKeys := true
+		for _, e := range val.entries {
+			strKeys = strKeys && e.Key.kind == StrKind
+		}
+		if strKeys {
+			keys := keyTracker[string]{policy: d.duplicateKeys}
+			m := make(map[string]interface{}, len(val.entries))
+			for _, e := range val.entries {
+				ignore, err := keys.ignore(e.Key.str)
+				if err != nil {
+					return nil, err
+				}
+				if ignore {
+					continue
+				}
+				v, err := d.interfaceOf(e.Value)
+				if err != nil {
+					return nil, err
+				}
+				m[e.Key.str] = v
+			}
+			return m, nil
+		}
+		keys := keyTracker[interface{}]{policy: d.duplicateKeys}
+		m := make(map[interface{}]interface{}, len(val.entries))
+		for _, e := range val.entries {
+			k, err := d.interfaceOf(e.Key)
+			if err != nil {
+				return nil, err
+			}
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, fmt.Errorf("%w: %s map key into interface{}", ErrUnmarshalType, e.Key.kind)
+			}
+			ignore, err := keys.ignore(k)
+			if err != nil {
+				return nil, err
+			}
+			if ignore {
+				continue
+			}
+			v, err := d.interfaceOf(e.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	}
+	return val, nil
+}
@@ -0,0 +1,531 @@
+package msgpack
+
+import (
+	"encoding/base64"
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Kind 為 Value 的資料類型，對應 message pack 的 type system
+type Kind uint8
+
+const (
+	InvalidKind Kind = iota
+	NilKind
+	BoolKind
+	IntKind
+	UintKind
+	Float32Kind
+	Float64Kind
+	StrKind
+	BinKind
+	ArrayKind
+	MapKind
+	ExtKind
+)
+
+var kindNames = []string{
+	InvalidKind: "invalid",
+	NilKind:     "nil",
+	BoolKind:    "bool",
+	IntKind:     "int",
+	UintKind:    "uint",
+	Float32Kind: "float32",
+	Float64Kind: "float64",
+	StrKind:     "str",
+	BinKind:     "bin",
+	ArrayKind:   "array",
+	MapKind:     "map",
+	ExtKind:     "ext",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Value is a message pack document held as a tree. The zero Value is
+// invalid; accessors on a Value of the wrong kind return the zero result
+// and false instead of panicking, so lookups can be chained.
+type Value struct {
+	kind Kind
+	// bool, int, uint 與 float 的 bits
+	num     uint64
+	str     string
+	bytes   []byte
+	extType int8
+	items   []Value
+	entries []Entry
+}
+
+// Entry is a key-value pair of a map Value. Maps keep their entries in
+// wire order and may have keys of any kind.
+type Entry struct {
+	Key   Value
+	Value Value
+}
+
+func Nil() Value {
+	return Value{kind: NilKind}
+}
+
+func Bool(b bool) Value {
+	v := Value{kind: BoolKind}
+	if b {
+		v.num = 1
+	}
+	return v
+}
+
+func Int(i int64) Value {
+	return Value{kind: IntKind, num: uint64(i)}
+}
+
+func Uint(u uint64) Value {
+	return Value{kind: UintKind, num: u}
+}
+
+func Float32(f float32) Value {
+	return Value{kind: Float32Kind, num: uint64(math.Float32bits(f))}
+}
+
+func Float64(f float64) Value {
+	return Value{kind: Float64Kind, num: math.Float64bits(f)}
+}
+
+func Str(s string) Value {
+	return Value{kind: StrKind, str: s}
+}
+
+func Bin(b []byte) Value {
+	return Value{kind: BinKind, bytes: b}
+}
+
+func Ext(typ int8, data []byte) Value {
+	return Value{kind: ExtKind, extType: typ, bytes: data}
+}
+
+func Array(items ...Value) Value {
+	return Value{kind: ArrayKind, items: items}
+}
+
+func Map(entries ...Entry) Value {
+	return Value{kind: MapKind, entries: entries}
+}
+
+// Pair builds a map entry with a str key.
+func Pair(key string, v Value) Entry {
+	return Entry{Key: Str(key), Value: v}
+}
+
+// DecodeValue decodes exactly one message pack value. Maps keep every
+// entry, including repeated keys; Get and Set use the last one.
+func DecodeValue(msgpackconv []byte) (Value, error) {
+	v, n, err := decodeValue(msgpackconv)
+	if err != nil {
+		return Value{}, err
+	}
+	if n != len(msgpackconv) {
+		return Value{}, ErrInvalidMsgPack
+	}
+	return v, nil
+}
+
+func (v *Value) UnmarshalMsgpack(msgpackconv []byte) error {
+	val, err := DecodeValue(msgpackconv)
+	if err != nil {
+		return err
+	}
+	*v = val
+	return nil
+}
+
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+func (v Value) IsNil() bool {
+	return v.kind == NilKind
+}
+
+func (v Value) Bool() (bool, bool) {
+	if v.kind != BoolKind {
+		return false, false
+	}
+	return v.num == 1, true
+}
+
+// Int returns the value as int64; uint values are accepted when they fit.
+func (v Value) Int() (int64, bool) {
+	switch v.kind {
+	case IntKind:
+		return int64(v.num), true
+	case UintKind:
+		if v.num <= math.MaxInt64 {
+			return int64(v.num), true
+		}
+	}
+	return 0, false
+}
+
+// Uint returns the value as uint64; non-negative int values are accepted.
+func (v Value) Uint() (uint64, bool) {
+	switch v.kind {
+	case UintKind:
+		return v.num, true
+	case IntKind:
+		if int64(v.num) >= 0 {
+			return v.num, true
+		}
+	}
+	return 0, false
+}
+
+func (v Value) Float() (float64, bool) {
+	switch v.kind {
+	case Float32Kind:
+		return float64(math.Float32frombits(uint32(v.num))), true
+	case Float64Kind:
+		return math.Float64frombits(v.num), true
+	}
+	return 0, false
+}
+
+func (v Value) Str() (string, bool) {
+	return v.str, v.kind == StrKind
+}
+
+// Bytes returns the data of a bin or ext value.
+func (v Value) Bytes() ([]byte, bool) {
+	return v.bytes, v.kind == BinKind || v.kind == ExtKind
+}
+
+func (v Value) ExtType() (int8, bool) {
+	return v.extType, v.kind == ExtKind
+}
+
+// Len returns the number of elements of an array, entries of a map or
+// bytes of a str, bin or ext; it is 0 for other kinds.
+func (v Value) Len() int {
+	switch v.kind {
+	case ArrayKind:
+		return len(v.items)
+	case MapKind:
+		return len(v.entries)
+	case StrKind:
+		return len(v.str)
+	case BinKind, ExtKind:
+		return len(v.bytes)
+	}
+	return 0
+}
+
+// Index returns the i-th element of an array, or an invalid Value.
+func (v Value) Index(i int) Value {
+	if v.kind != ArrayKind || i < 0 || i >= len(v.items) {
+		return Value{}
+	}
+	return v.items[i]
+}
+
+// Get returns the value of the last entry whose key is the str key, or an
+// invalid Value. A repeated key thus reads like Unmarshal into a Go map.
+func (v Value) Get(key string) Value {
+	if i := v.find(key); i >= 0 {
+		return v.entries[i].Value
+	}
+	return Value{}
+}
+
+func (v Value) find(key string) int {
+	if v.kind != MapKind {
+		return -1
+	}
+	// 重複的 key 以最後一個為準，與 DuplicateKeysLast 相同
+	for i := len(v.entries) - 1; i >= 0; i-- {
+		if s, ok := v.entries[i].Key.Str(); ok && s == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Elements iterates over the elements of an array.
+func (v Value) Elements() iter.Seq2[int, Value] {
+	return func(yield func(int, Value) bool) {
+		if v.kind != ArrayKind {
+			return
+		}
+		for i, item := range v.items {
+			if !yield(i, item) {
+				return
+			}
+		}
+	}
+}
+
+// Entries iterates over the entries of a map in wire order.
+func (v Value) Entries() iter.Seq2[Value, Value] {
+	return func(yield func(Value, Value) bool) {
+		if v.kind != MapKind {
+			return
+		}
+		for _, e := range v.entries {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Set replaces the value of the last entry with the str key, or appends a
+// new entry.
+func (v *Value) Set(key string, val Value) error {
+	if v.kind != MapKind {
+		return ErrKindMismatch
+	}
+	if i := v.find(key); i >= 0 {
+		v.entries[i].Value = val
+		return nil
+	}
+	v.entries = append(v.entries, Pair(key, val))
+	return nil
+}
+
+// Delete removes every entry with the str key.
+func (v *Value) Delete(key string) error {
+	if v.kind != MapKind {
+		return ErrKindMismatch
+	}
+	for i := v.find(key); i >= 0; i = v.find(key) {
+		v.entries = append(v.entries[:i], v.entries[i+1:]...)
+	}
+	return nil
+}
+
+func (v *Value) SetIndex(i int, val Value) error {
+	if v.kind != ArrayKind {
+		return ErrKindMismatch
+	}
+	if i < 0 || i >= len(v.items) {
+		return fmt.Errorf("index %d out of range [0:%d]", i, len(v.items))
+	}
+	v.items[i] = val
+	return nil
+}
+
+func (v *Value) Append(items ...Value) error {
+	if v.kind != ArrayKind {
+		return ErrKindMismatch
+	}
+	v.items = append(v.items, items...)
+	return nil
+}
+
+func (v Value) MarshalMsgpack() ([]byte, error) {
+	return appendValue(nil, v)
+}
+
+func appendValue(ans []byte, v Value) ([]byte, error) {
+	var err error
+	switch v.kind {
+	case NilKind:
+		ans = append(ans, FirstByte["nil"])
+	case BoolKind:
+		ans = append(ans, getBoolFormat(v.num == 1))
+	case IntKind:
+		if int64(v.num) >= 0 {
+			ans = append(ans, getPositiveIntFormat(v.num)...)
+		} else {
+			ans = append(ans, getNegativeIntFormat(int64(v.num))...)
+		}
+	case UintKind:
+		ans = append(ans, getPositiveIntFormat(v.num)...)
+	case Float32Kind:
+		ans = append(ans, getFloat32Format(math.Float32frombits(uint32(v.num)))...)
+	case Float64Kind:
+		ans = append(ans, getFloatFormat(math.Float64frombits(v.num))...)
+	case StrKind:
+		ans = append(ans, getStrFormat(v.str)...)
+	case BinKind:
+		ans = append(ans, getBinFormat(v.bytes)...)
+	case ExtKind:
+		ans = append(ans, getExtFormat(v.extType, v.bytes)...)
+	case ArrayKind:
+		ans = append(ans, getArrayFormat(len(v.items))...)
+		for _, item := range v.items {
+			if ans, err = appendValue(ans, item); err != nil {
+				return nil, err
+			}
+		}
+	case MapKind:
+		ans = append(ans, getMapFormat(len(v.entries))...)
+		for _, e := range v.entries {
+			if ans, err = appendValue(ans, e.Key); err != nil {
+				return nil, err
+			}
+			if ans, err = appendValue(ans, e.Value); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, ErrKindMismatch
+	}
+	return ans, nil
+}
+
+// MarshalJSON converts the value to JSON, keeping map entries in wire
+// order. Int and uint map keys become JSON strings and bin is base64
+// encoded, as encoding/json does; ext values have no JSON form.
+func (v Value) MarshalJSON() ([]byte, error) {
+	return appendValueJSON(nil, v, ToJSONOptions{})
+}
+
+func appendValueJSON(ans []byte, v Value, opts ToJSONOptions) ([]byte, error) {
+	var err error
+	switch v.kind {
+	case NilKind:
+		ans = append(ans, "null"...)
+	case BoolKind:
+		ans = strconv.AppendBool(ans, v.num == 1)
+	case IntKind:
+		ans = strconv.AppendInt(ans, int64(v.num), 10)
+	case UintKind:
+		ans = strconv.AppendUint(ans, v.num, 10)
+	case Float32Kind:
+		return appendFloatJSON(ans, float64(math.Float32frombits(uint32(v.num))), 32, opts.NonFinite)
+	case Float64Kind:
+		return appendFloatJSON(ans, math.Float64frombits(v.num), 64, opts.NonFinite)
+	case StrKind:
+		return appendStringJSON(ans, v.str, opts), nil
+	case BinKind:
+		ans = append(ans, '"')
+		ans = base64.StdEncoding.AppendEncode(ans, v.bytes)
+		ans = append(ans, '"')
+	case ArrayKind:
+		ans = append(ans, '[')
+		for i, item := range v.items {
+			if i > 0 {
+				ans = append(ans, ',')
+			}
+			if ans, err = appendValueJSON(ans, item, opts); err != nil {
+				return nil, err
+			}
+		}
+		ans = append(ans, ']')
+	case MapKind:
+		ans = append(ans, '{')
+		for i, e := range v.entries {
+			if i > 0 {
+				ans = append(ans, ',')
+			}
+			switch e.Key.kind {
+			case StrKind:
+				ans = appendStringJSON(ans, e.Key.str, opts)
+			case IntKind, UintKind:
+				ans = append(ans, '"')
+				ans, err = appendValueJSON(ans, e.Key, opts)
+				ans = append(ans, '"')
+			default:
+				err = fmt.Errorf("%w: %s map key", ErrNotJSONCompatible, e.Key.kind)
+			}
+			if err != nil {
+				return nil, err
+			}
+			ans = append(ans, ':')
+			if ans, err = appendValueJSON(ans, e.Value, opts); err != nil {
+				return nil, err
+			}
+		}
+		ans = append(ans, '}')
+	case ExtKind:
+		text, err := bigNumberText(v.extType, v.bytes)
+		if err != nil {
+			return nil, err
+		}
+		ans = append(ans, text...)
+	default:
+		return nil, ErrKindMismatch
+	}
+	return ans, nil
+}
+
+func appendStringJSON(ans []byte, s string, opts ToJSONOptions) []byte {
+	ans = append(ans, '"')
+	ans, _ = appendEscaped(ans, []byte(s), true, opts)
+	return append(ans, '"')
+}
+
+func decodeValue(msgpackconv []byte) (Value, int, error) {
+	h, err := readHeader(msgpackconv)
+	if err != nil {
+		return Value{}, 0, err
+	}
+	switch h.format {
+	case "array16", "array32", "fixarray":
+		items := make([]Value, 0, min(h.length, len(msgpackconv)))
+		idxOfEnd := h.size
+		for range h.length {
+			item, n, err := decodeValue(msgpackconv[idxOfEnd:])
+			if err != nil {
+				return Value{}, 0, err
+			}
+			items = append(items, item)
+			idxOfEnd += n
+		}
+		return Array(items...), idxOfEnd, nil
+	case "map16", "map32", "fixmap":
+		entries := make([]Entry, 0, min(h.length, len(msgpackconv)))
+		idxOfEnd := h.size
+		for range h.length {
+			key, n, err := decodeValue(msgpackconv[idxOfEnd:])
+			if err != nil {
+				return Value{}, 0, err
+			}
+			idxOfEnd += n
+			value, n, err := decodeValue(msgpackconv[idxOfEnd:])
+			if err != nil {
+				return Value{}, 0, err
+			}
+			idxOfEnd += n
+			entries = append(entries, Entry{Key: key, Value: value})
+		}
+		return Map(entries...), idxOfEnd, nil
+	}
+
+	idxOfEnd := h.size + h.length
+	if len(msgpackconv) < idxOfEnd {
+		return Value{}, 0, ErrInvalidMsgPack
+	}
+	data := msgpackconv[h.size:idxOfEnd]
+	switch h.format {
+	case "nil":
+		return Nil(), idxOfEnd, nil
+	case "false", "true":
+		return Bool(h.format == "true"), idxOfEnd, nil
+	case "positiveFixint":
+		return Uint(uint64(msgpackconv[0])), idxOfEnd, nil
+	case "negativeFixint":
+		return Int(int64(int8(msgpackconv[0]))), idxOfEnd, nil
+	case "uint8", "uint16", "uint32", "uint64":
+		return Uint(bytesToUint64(data, true)), idxOfEnd, nil
+	case "int8", "int16", "int32", "int64":
+		return Int(bytesToInt64(data)), idxOfEnd, nil
+	case "float32":
+		return Float32(bitsToFloat32(data)), idxOfEnd, nil
+	case "float64":
+		return Float64(bitsToFloat64(data)), idxOfEnd, nil
+	case "fixstr", "str8", "str16", "str32":
+		return Str(string(data)), idxOfEnd, nil
+	case "bin8", "bin16", "bin32":
+		return Bin(slices.Clone(data)), idxOfEnd, nil
+	default:
+		// fixext 與 ext
+		return Ext(h.extType, slices.Clone(data)), idxOfEnd, nil
+	}
+}
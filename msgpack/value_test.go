@@ -0,0 +1,294 @@
+package msgpack_test
+
+import (
+	"encoding/json"
+	. "msgpackconv/msgpack"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeValue(t *testing.T) {
+	type args struct {
+		msgpackconv []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want Value
+	}{
+		{
+			"positive fixint",
+			args{[]byte{0x01}},
+			Uint(1),
+		},
+		{
+			"uint64",
+			args{[]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+			Uint(18446744073709551615),
+		},
+		{
+			"negative fixint",
+			args{[]byte{0xff}},
+			Int(-1),
+		},
+		{
+			"positive int8",
+			args{[]byte{0xd0, 0x05}},
+			Int(5),
+		},
+		{
+			"int16",
+			args{[]byte{0xd1, 0xff, 0x80}},
+			Int(-128),
+		},
+		{
+			"float32",
+			args{[]byte{0xca, 0x3f, 0xc0, 0x00, 0x00}},
+			Float32(1.5),
+		},
+		{
+			"float64",
+			args{[]byte{0xcb, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+			Float64(0.1),
+		},
+		{
+			"bin8",
+			args{[]byte{0xc4, 0x02, 0x00, 0xff}},
+			Bin([]byte{0x00, 0xff}),
+		},
+		{
+			"fixext1",
+			args{[]byte{0xd4, 0x05, 0x01}},
+			Ext(5, []byte{0x01}),
+		},
+		{
+			"ext8",
+			args{[]byte{0xc7, 0x03, 0xff, 0x01, 0x02, 0x03}},
+			Ext(-1, []byte{0x01, 0x02, 0x03}),
+		},
+		{
+			"nested",
+			args{[]byte{0x82, 0xa1, 0x62, 0x92, 0xa1, 0x61, 0xc3, 0x01, 0xc0}},
+			Map(Pair("b", Array(Str("a"), Bool(true))), Entry{Key: Uint(1), Value: Nil()}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeValue(tt.args.msgpackconv)
+			assert.NoError(t, err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeValueFail(t *testing.T) {
+	type args struct {
+		msgpackconv []byte
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"empty", args{[]byte{}}},
+		{"never used", args{[]byte{0xc1}}},
+		{"short str", args{[]byte{0xa3, 0x61}}},
+		{"short array", args{[]byte{0x92, 0x01}}},
+		{"short map", args{[]byte{0x81, 0xa3, 0x69}}},
+		{"trailing bytes", args{[]byte{0x01, 0x02}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeValue(tt.args.msgpackconv)
+			assert.ErrorIs(t, err, ErrInvalidMsgPack)
+		})
+	}
+}
+
+func TestValueMarshalMsgpack(t *testing.T) {
+	tests := []struct {
+		name  string
+		value Value
+		want  []byte
+	}{
+		{"nil", Nil(), []byte{0xc0}},
+		{"int", Int(-33), []byte{0xd0, 0xdf}},
+		{"uint", Uint(256), []byte{0xcd, 0x01, 0x00}},
+		{"float32", Float32(1.5), []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}},
+		{"bin", Bin([]byte{0x01}), []byte{0xc4, 0x01, 0x01}},
+		{"fixext4", Ext(1, []byte{1, 2, 3, 4}), []byte{0xd6, 0x01, 0x01, 0x02, 0x03, 0x04}},
+		{"ext8", Ext(1, []byte{1, 2, 3}), []byte{0xc7, 0x03, 0x01, 0x01, 0x02, 0x03}},
+		{
+			"map in wire order",
+			Map(Pair("z", Uint(1)), Pair("a", Array(Bool(false)))),
+			[]byte{0x82, 0xa1, 0x7a, 0x01, 0xa1, 0x61, 0x91, 0xc2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.value.MarshalMsgpack()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	_, err := Value{}.MarshalMsgpack()
+	assert.ErrorIs(t, err, ErrKindMismatch)
+}
+
+func TestValueMarshalJSON(t *testing.T) {
+	v := Map(
+		Pair("z", Float32(0.1)),
+		Pair("a", Array(Int(-1), Uint(2), Nil(), Str("<b>"))),
+		Entry{Key: Uint(7), Value: Bin([]byte("hi"))},
+	)
+	got, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"z":0.1,"a":[-1,2,null,"\u003cb\u003e"],"7":"aGk="}`, string(got))
+
+	_, err = json.Marshal(Ext(1, []byte{1}))
+	assert.ErrorIs(t, err, ErrNotJSONCompatible)
+	_, err = json.Marshal(Map(Entry{Key: Array(), Value: Nil()}))
+	assert.ErrorIs(t, err, ErrNotJSONCompatible)
+}
+
+func TestValueAccessors(t *testing.T) {
+	v, err := DecodeValue(FromJSON([]byte(`{"list": [1, -2, "x"]}`)))
+	assert.NoError(t, err)
+	assert.Equal(t, MapKind, v.Kind())
+	assert.Equal(t, 1, v.Len())
+
+	list := v.Get("list")
+	assert.Equal(t, 3, list.Len())
+	u, ok := list.Index(0).Uint()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), u)
+	i, ok := list.Index(1).Int()
+	assert.True(t, ok)
+	assert.Equal(t, int64(-2), i)
+	_, ok = list.Index(1).Uint()
+	assert.False(t, ok)
+	s, ok := list.Index(2).Str()
+	assert.True(t, ok)
+	assert.Equal(t, "x", s)
+
+	// 不存在或類型不符時回傳 invalid Value
+	assert.Equal(t, InvalidKind, v.Get("missing").Index(3).Kind())
+	_, ok = v.Get("list").Str()
+	assert.False(t, ok)
+
+	kinds := []Kind{}
+	for _, item := range list.Elements() {
+		kinds = append(kinds, item.Kind())
+	}
+	assert.Equal(t, []Kind{UintKind, IntKind, StrKind}, kinds)
+	for key := range v.Entries() {
+		assert.Equal(t, Str("list"), key)
+	}
+}
+
+func TestValueAccessorsWrongKind(t *testing.T) {
+	// 類型不符或超出範圍時回傳零值
+	b, ok := Int(1).Bool()
+	assert.False(t, b)
+	assert.False(t, ok)
+	u, ok := Int(-1).Uint()
+	assert.Equal(t, uint64(0), u)
+	assert.False(t, ok)
+	i, ok := Uint(1 << 63).Int()
+	assert.Equal(t, int64(0), i)
+	assert.False(t, ok)
+	i, ok = Bool(true).Int()
+	assert.Equal(t, int64(0), i)
+	assert.False(t, ok)
+	f, ok := Int(1).Float()
+	assert.Equal(t, 0.0, f)
+	assert.False(t, ok)
+	s, ok := Bin([]byte("x")).Str()
+	assert.Equal(t, "", s)
+	assert.False(t, ok)
+	data, ok := Str("x").Bytes()
+	assert.Nil(t, data)
+	assert.False(t, ok)
+	typ, ok := Bin(nil).ExtType()
+	assert.Equal(t, int8(0), typ)
+	assert.False(t, ok)
+}
+
+func TestValueDuplicateKeys(t *testing.T) {
+	// {"a": 1, "b": 2, "a": 3}
+	msg := []byte{0x83, 0xa1, 0x61, 0x01, 0xa1, 0x62, 0x02, 0xa1, 0x61, 0x03}
+	v, err := DecodeValue(msg)
+	assert.NoError(t, err)
+	var m map[string]int
+	assert.NoError(t, Unmarshal(msg, &m))
+	n, _ := v.Get("a").Int()
+	assert.Equal(t, int64(m["a"]), n)
+
+	assert.NoError(t, v.Set("a", Uint(4)))
+	assert.Equal(t, Map(Pair("a", Uint(1)), Pair("b", Uint(2)), Pair("a", Uint(4))), v)
+	assert.Equal(t, Uint(4), v.Get("a"))
+}
+
+func TestValueEdit(t *testing.T) {
+	v := Map(Pair("a", Uint(1)))
+	assert.NoError(t, v.Set("a", Uint(2)))
+	assert.NoError(t, v.Set("b", Array()))
+	list := v.Get("b")
+	assert.NoError(t, list.Append(Str("x"), Str("y")))
+	assert.NoError(t, list.SetIndex(0, Nil()))
+	assert.Error(t, list.SetIndex(2, Nil()))
+	assert.NoError(t, v.Set("b", list))
+	assert.NoError(t, v.Delete("a"))
+	assert.ErrorIs(t, list.Set("a", Nil()), ErrKindMismatch)
+	assert.ErrorIs(t, v.Append(Nil()), ErrKindMismatch)
+
+	got, err := v.MarshalMsgpack()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x81, 0xa1, 0x62, 0x92, 0xc0, 0xa1, 0x79}, got)
+}
+
+func TestTimestamp(t *testing.T) {
+	tests := []struct {
+		name string
+		time time.Time
+		want []byte
+	}{
+		{
+			"timestamp 32",
+			time.Unix(1, 0),
+			[]byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x01},
+		},
+		{
+			"timestamp 64",
+			time.Unix(1, 1),
+			[]byte{0xd7, 0xff, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01},
+		},
+		{
+			"timestamp 96",
+			time.Unix(-1, 0),
+			[]byte{0xc7, 0x0c, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := Timestamp(tt.time)
+			got, err := v.MarshalMsgpack()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			decoded, err := DecodeValue(got)
+			assert.NoError(t, err)
+			back, ok := decoded.Time()
+			assert.True(t, ok)
+			assert.True(t, tt.time.Equal(back))
+		})
+	}
+
+	_, ok := Ext(TimestampExtType, []byte{0x01}).Time()
+	assert.False(t, ok)
+	_, ok = Ext(1, []byte{0, 0, 0, 1}).Time()
+	assert.False(t, ok)
+}
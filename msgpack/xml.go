@@ -0,0 +1,305 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// XMLOptions controls FromXML and ToXML.
+//
+// The document is a map with one entry, the root element. An element
+// without attributes and children is nil when empty and its text as a str
+// otherwise; any other element is a map of its attributes, each key being
+// AttrPrefix followed by the attribute name, its child elements, and its
+// text under TextKey. Repeated children become an array at the position of
+// the first one. Names keep their namespace prefixes as written and xmlns
+// declarations are attributes like any other, so a document converts back
+// to the same elements, attributes and text, except that repeated children
+// are moved next to each other, text between child elements is joined and
+// trimmed, and comments and processing instructions are dropped.
+type XMLOptions struct {
+	// AttrPrefix marks attribute keys. Empty means "@". FromXML fails on a
+	// child element whose name starts with it.
+	AttrPrefix string
+	// TextKey is the key of the text of an element with attributes or
+	// children. Empty means "#text". FromXML fails on a child element with
+	// this name.
+	TextKey string
+	// Indent makes ToXML put each element on its own line, indented by
+	// Indent per level.
+	Indent string
+}
+
+func (opts XMLOptions) attrPrefix() string {
+	if opts.AttrPrefix == "" {
+		return "@"
+	}
+	return opts.AttrPrefix
+}
+
+func (opts XMLOptions) textKey() string {
+	if opts.TextKey == "" {
+		return "#text"
+	}
+	return opts.TextKey
+}
+
+// xmlFrame 為尚未結束的 element
+type xmlFrame struct {
+	name    string
+	entries []Entry
+	// key 在 entries 中的位置，重複的 child 合併為 array
+	index    map[string]int
+	repeated map[string]bool
+	children bool
+	text     strings.Builder
+}
+
+func (f *xmlFrame) add(key string, v Value) {
+	i, ok := f.index[key]
+	if !ok {
+		f.index[key] = len(f.entries)
+		f.entries = append(f.entries, Pair(key, v))
+		return
+	}
+	if !f.repeated[key] {
+		f.repeated[key] = true
+		f.entries[i].Value = Array(f.entries[i].Value)
+	}
+	f.entries[i].Value.items = append(f.entries[i].Value.items, v)
+}
+
+func (f *xmlFrame) value(opts XMLOptions) Value {
+	text := f.text.String()
+	if len(f.entries) == 0 {
+		if text == "" {
+			return Nil()
+		}
+		return Str(text)
+	}
+	// 有 child element 時，text 多半只是縮排
+	if f.children {
+		text = strings.TrimSpace(text)
+	}
+	if text != "" {
+		f.entries = append(f.entries, Pair(opts.textKey(), Str(text)))
+	}
+	return Map(f.entries...)
+}
+
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// FromXML converts an XML document to message pack with the convention
+// described by XMLOptions. All text, including attribute values, is str.
+func FromXML(data []byte, opts XMLOptions) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	stack := []*xmlFrame{}
+	var root *Entry
+	for {
+		// RawToken 保留 namespace 的 prefix，但不檢查 end element 是否對應
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidXML, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && root != nil {
+				return nil, fmt.Errorf("%w: line %d: second root element <%s>", ErrInvalidXML, xmlLine(dec), xmlName(t.Name))
+			}
+			f := &xmlFrame{name: xmlName(t.Name), index: map[string]int{}, repeated: map[string]bool{}}
+			// child 的 key 與 attribute 或 text 的 key 相同時無法轉回
+			if len(stack) > 0 && strings.HasPrefix(f.name, opts.attrPrefix()) {
+				return nil, fmt.Errorf("%w: line %d: child element <%s> starts with the attribute prefix", ErrInvalidXML, xmlLine(dec), f.name)
+			}
+			if len(stack) > 0 && f.name == opts.textKey() {
+				return nil, fmt.Errorf("%w: line %d: child element <%s> has the name of the text key", ErrInvalidXML, xmlLine(dec), f.name)
+			}
+			for _, attr := range t.Attr {
+				f.add(opts.attrPrefix()+xmlName(attr.Name), Str(attr.Value))
+			}
+			stack = append(stack, f)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: line %d: unexpected </%s>", ErrInvalidXML, xmlLine(dec), xmlName(t.Name))
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if xmlName(t.Name) != f.name {
+				return nil, fmt.Errorf("%w: line %d: <%s> closed by </%s>", ErrInvalidXML, xmlLine(dec), f.name, xmlName(t.Name))
+			}
+			v := f.value(opts)
+			if len(stack) == 0 {
+				root = &Entry{Key: Str(f.name), Value: v}
+				continue
+			}
+			parent := stack[len(stack)-1]
+			parent.children = true
+			parent.add(f.name, v)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			} else if len(bytes.TrimSpace(t)) > 0 {
+				return nil, fmt.Errorf("%w: line %d: text outside the root element", ErrInvalidXML, xmlLine(dec))
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: <%s> is not closed", ErrInvalidXML, stack[len(stack)-1].name)
+	}
+	if root == nil {
+		return nil, fmt.Errorf("%w: no root element", ErrInvalidXML)
+	}
+	return Map(*root).MarshalMsgpack()
+}
+
+func xmlLine(dec *xml.Decoder) int {
+	line, _ := dec.InputPos()
+	return line
+}
+
+// ToXML converts a map with a single entry, the root element, to an XML
+// document with the convention described by XMLOptions. Ints, floats,
+// booleans, bin and timestamp exts are written as text as ToCSV writes
+// them.
+func ToXML(msgpackconv []byte, opts XMLOptions) ([]byte, error) {
+	v, err := DecodeValue(msgpackconv)
+	if err != nil {
+		return nil, err
+	}
+	if v.kind != MapKind || len(v.entries) != 1 {
+		return nil, fmt.Errorf("%w: document must be a map with one entry", ErrNotXMLCompatible)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", opts.Indent)
+	root := v.entries[0]
+	name, ok := root.Key.Str()
+	if !ok {
+		return nil, fmt.Errorf("%w: element name of kind %s", ErrNotXMLCompatible, root.Key.kind)
+	}
+	if root.Value.kind == ArrayKind {
+		return nil, fmt.Errorf("%w: root element <%s> is an array", ErrNotXMLCompatible, name)
+	}
+	if err := writeXMLElement(enc, name, root.Value, opts); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	if opts.Indent != "" {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func writeXMLElement(enc *xml.Encoder, name string, v Value, opts XMLOptions) error {
+	if !isXMLName(name) {
+		return fmt.Errorf("%w: %q is not an element name", ErrNotXMLCompatible, name)
+	}
+	if v.kind == ArrayKind {
+		for _, item := range v.items {
+			if item.kind == ArrayKind {
+				return fmt.Errorf("%w: <%s> holds nested arrays", ErrNotXMLCompatible, name)
+			}
+			if err := writeXMLElement(enc, name, item, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	text := ""
+	children := []Entry{}
+	if v.kind == MapKind {
+		for _, e := range v.entries {
+			key, ok := e.Key.Str()
+			if !ok {
+				return fmt.Errorf("%w: key of kind %s in <%s>", ErrNotXMLCompatible, e.Key.kind, name)
+			}
+			switch {
+			case key == opts.textKey():
+				s, err := xmlText(name, key, e.Value)
+				if err != nil {
+					return err
+				}
+				text += s
+			case strings.HasPrefix(key, opts.attrPrefix()):
+				attr := strings.TrimPrefix(key, opts.attrPrefix())
+				if !isXMLName(attr) {
+					return fmt.Errorf("%w: %q is not an attribute name", ErrNotXMLCompatible, attr)
+				}
+				s, err := xmlText(name, key, e.Value)
+				if err != nil {
+					return err
+				}
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr}, Value: s})
+			default:
+				children = append(children, e)
+			}
+		}
+	} else {
+		s, err := xmlText(name, "", v)
+		if err != nil {
+			return err
+		}
+		text = s
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if text != "" {
+		if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+	for _, e := range children {
+		if err := writeXMLElement(enc, e.Key.str, e.Value, opts); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlText 回傳 element 或 attribute 的文字
+func xmlText(element, key string, v Value) (string, error) {
+	s, ok := scalarText(v)
+	if ok {
+		return s, nil
+	}
+	if key == "" {
+		key = "text"
+	}
+	if v.kind == ExtKind {
+		return "", fmt.Errorf("%w: %s of <%s> is ext type %d", ErrNotXMLCompatible, key, element, v.extType)
+	}
+	return "", fmt.Errorf("%w: %s of <%s> is a %s", ErrNotXMLCompatible, key, element, v.kind)
+}
+
+// isXMLName 大致檢查 XML 的 name，允許 namespace prefix 的冒號
+func isXMLName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r), r == '_', r == ':':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package msgpack_test
+
+import (
+	. "msgpackconv/msgpack"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const soapEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns:m="https://example.com/stock">
+  <soap:Body>
+    <m:GetPrice m:currency="EUR">
+      <m:Item>Apple</m:Item>
+      <m:Item>Pear</m:Item>
+      <m:Note><![CDATA[<fresh> & ripe]]></m:Note>
+    </m:GetPrice>
+  </soap:Body>
+</soap:Envelope>`
+
+func TestFromXML(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		opts XMLOptions
+		want string
+	}{
+		{"empty element", `<a/>`, XMLOptions{}, `{"a": nil}`},
+		{"text", `<a> x &amp; y </a>`, XMLOptions{}, `{"a": " x & y "}`},
+		{"attributes and text", `<a id="1" lang="en">hi</a>`, XMLOptions{},
+			`{"a": {"@id": "1", "@lang": "en", "#text": "hi"}}`},
+		{"children in order", `<a><c>1</c><b/></a>`, XMLOptions{}, `{"a": {"c": "1", "b": nil}}`},
+		{"repeated children", `<a><b>1</b><c/><b>2</b></a>`, XMLOptions{}, `{"a": {"b": ["1", "2"], "c": nil}}`},
+		{"mixed content", "<a>\n  x <b/> y\n</a>", XMLOptions{}, `{"a": {"b": nil, "#text": "x  y"}}`},
+		{"custom keys", `<a id="1"><b/>t</a>`, XMLOptions{AttrPrefix: "-", TextKey: "_"}, `{"a": {"-id": "1", "b": nil, "_": "t"}}`},
+		{"comments and instructions", `<?xml version="1.0"?><!-- c --><a><?pi x?>t<!-- c --></a>`, XMLOptions{}, `{"a": "t"}`},
+		{"namespaces", soapEnvelope, XMLOptions{},
+			`{"soap:Envelope": {"@xmlns:soap": "http://www.w3.org/2003/05/soap-envelope", "@xmlns:m": "https://example.com/stock", ` +
+				`"soap:Body": {"m:GetPrice": {"@m:currency": "EUR", "m:Item": ["Apple", "Pear"], "m:Note": "<fresh> & ripe"}}}}`},
+		{"default namespace", `<feed xmlns="http://www.w3.org/2005/Atom"><title>t</title></feed>`, XMLOptions{},
+			`{"feed": {"@xmlns": "http://www.w3.org/2005/Atom", "title": "t"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromXML([]byte(tt.xml), tt.opts)
+			assert.NoError(t, err)
+			diag, err := ToDiagnostic(got)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, diag)
+		})
+	}
+}
+
+func TestFromXMLFail(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		opts XMLOptions
+		err  string
+	}{
+		{"empty", ``, XMLOptions{}, "invalid XML: no root element"},
+		{"mismatched end", `<a><b></a></b>`, XMLOptions{}, "invalid XML: line 1: <b> closed by </a>"},
+		{"unclosed", `<a><b/>`, XMLOptions{}, "invalid XML: <a> is not closed"},
+		{"two roots", `<a/><b/>`, XMLOptions{}, "invalid XML: line 1: second root element <b>"},
+		{"text outside root", `<a/>x`, XMLOptions{}, "invalid XML: line 1: text outside the root element"},
+		{"syntax", `<a x=1/>`, XMLOptions{}, "invalid XML: XML syntax error on line 1: unquoted or missing attribute value in element"},
+		{"child with attribute prefix", `<r><attr_x>1</attr_x></r>`, XMLOptions{AttrPrefix: "attr_"}, "invalid XML: line 1: child element <attr_x> starts with the attribute prefix"},
+		{"child named text key", `<r><text>1</text><x/></r>`, XMLOptions{TextKey: "text"}, "invalid XML: line 1: child element <text> has the name of the text key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromXML([]byte(tt.xml), tt.opts)
+			assert.ErrorIs(t, err, ErrInvalidXML)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestToXML(t *testing.T) {
+	tests := []struct {
+		name string
+		diag string
+		opts XMLOptions
+		want string
+	}{
+		{"scalars", `{"a": {"n": 1, "f": 2.0, "b": true, "s": "x<y", "nil": nil, "bin": h'ff'}}`, XMLOptions{},
+			`<a><n>1</n><f>2.0</f><b>true</b><s>x&lt;y</s><nil></nil><bin>/w==</bin></a>`},
+		{"attributes and text", `{"a": {"#text": "hi", "@id": 1}}`, XMLOptions{}, `<a id="1">hi</a>`},
+		{"repeated children", `{"a": {"b": ["1", {"@x": "y"}]}}`, XMLOptions{}, `<a><b>1</b><b x="y"></b></a>`},
+		{"timestamp", `{"t": ext(-1, h'00000001')}`, XMLOptions{}, `<t>1970-01-01T00:00:01Z</t>`},
+		{"indent", `{"a": {"@id": "1", "b": "x", "c": {"d": nil}}}`, XMLOptions{Indent: "  "},
+			"<a id=\"1\">\n  <b>x</b>\n  <c>\n    <d></d>\n  </c>\n</a>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgpackconv, err := FromDiagnostic(tt.diag)
+			assert.NoError(t, err)
+			got, err := ToXML(msgpackconv, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+tt.want, string(got))
+		})
+	}
+}
+
+func TestToXMLFail(t *testing.T) {
+	tests := []struct {
+		name string
+		diag string
+	}{
+		{"not a map", `[1]`},
+		{"two roots", `{"a": 1, "b": 2}`},
+		{"root array", `{"a": [1, 2]}`},
+		{"int key", `{"a": {1: 2}}`},
+		{"invalid name", `{"a b": 1}`},
+		{"invalid attribute name", `{"a": {"@": 1}}`},
+		{"nested arrays", `{"a": {"b": [[1]]}}`},
+		{"map attribute", `{"a": {"@x": {}}}`},
+		{"ext", `{"a": ext(5, h'01')}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgpackconv, err := FromDiagnostic(tt.diag)
+			assert.NoError(t, err)
+			_, err = ToXML(msgpackconv, XMLOptions{})
+			assert.ErrorIs(t, err, ErrNotXMLCompatible)
+		})
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	msgpackconv, err := FromXML([]byte(soapEnvelope), XMLOptions{})
+	assert.NoError(t, err)
+	out, err := ToXML(msgpackconv, XMLOptions{Indent: "  "})
+	assert.NoError(t, err)
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns:m="https://example.com/stock">
+  <soap:Body>
+    <m:GetPrice m:currency="EUR">
+      <m:Item>Apple</m:Item>
+      <m:Item>Pear</m:Item>
+      <m:Note>&lt;fresh&gt; &amp; ripe</m:Note>
+    </m:GetPrice>
+  </soap:Body>
+</soap:Envelope>
+`
+	assert.Equal(t, want, string(out))
+
+	back, err := FromXML(out, XMLOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, msgpackconv, back)
+}
@@ -0,0 +1,366 @@
+package msgpack
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FromYAML converts the first document of a YAML stream to message pack.
+// Mapping entries keep their document order and keys of any type, merge
+// keys and aliases are expanded, !!binary becomes bin and an explicitly
+// tagged !!timestamp becomes a timestamp ext. An empty document is nil.
+// Aliases that expand to more than 10000 nodes plus 100 per node of the
+// document fail with ErrInvalidYAML.
+func FromYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidYAML, err)
+	}
+	if len(node.Content) == 0 {
+		return []byte{FirstByte["nil"]}, nil
+	}
+	var v Value
+	if err := v.UnmarshalYAML(node.Content[0]); err != nil {
+		return nil, err
+	}
+	return v.MarshalMsgpack()
+}
+
+// ToYAML converts exactly one message pack value to a YAML document. Bin
+// is written as !!binary and timestamp exts as !!timestamp; other ext
+// values have no YAML form. Float32 values are read back as float64.
+func ToYAML(msgpackconv []byte) ([]byte, error) {
+	v, err := DecodeValue(msgpackconv)
+	if err != nil {
+		return nil, err
+	}
+	node, err := v.yamlNode()
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(node)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler with the conversions of
+// FromYAML.
+func (v *Value) UnmarshalYAML(node *yaml.Node) error {
+	c := newYAMLConverter(node)
+	val, err := c.value(node)
+	if err != nil {
+		return err
+	}
+	*v = val
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler with the conversions of ToYAML.
+func (v Value) MarshalYAML() (any, error) {
+	return v.yamlNode()
+}
+
+// yamlConverter 將 yaml.Node 轉為 Value，expanding 記錄展開中的 alias 以
+// 偵測循環參照
+type yamlConverter struct {
+	expanding map[*yaml.Node]bool
+	// 已轉換的 anchor，每個 alias 共用同一個 Value
+	anchors map[*yaml.Node]yamlAnchor
+	// 展開 alias 後的 node 數量，超過 maxNodes 時失敗，以免少量的 alias
+	// 互相參照展開為極大的值
+	nodes, maxNodes int
+}
+
+type yamlAnchor struct {
+	v     Value
+	nodes int
+}
+
+func newYAMLConverter(root *yaml.Node) *yamlConverter {
+	return &yamlConverter{
+		expanding: map[*yaml.Node]bool{},
+		anchors:   map[*yaml.Node]yamlAnchor{},
+		maxNodes:  10000 + 100*countYAMLNodes(root),
+	}
+}
+
+// countYAMLNodes 計算不展開 alias 時的 node 數量
+func countYAMLNodes(n *yaml.Node) int {
+	count := 1
+	for _, child := range n.Content {
+		count += countYAMLNodes(child)
+	}
+	return count
+}
+
+func (c *yamlConverter) value(n *yaml.Node) (Value, error) {
+	if a, ok := c.anchors[n]; ok {
+		c.nodes += a.nodes
+		if c.nodes > c.maxNodes {
+			return Value{}, fmt.Errorf("%w: line %d: aliases expand to more than %d nodes", ErrInvalidYAML, n.Line, c.maxNodes)
+		}
+		return a.v, nil
+	}
+	start := c.nodes
+	c.nodes++
+	if c.nodes > c.maxNodes {
+		return Value{}, fmt.Errorf("%w: line %d: aliases expand to more than %d nodes", ErrInvalidYAML, n.Line, c.maxNodes)
+	}
+	v, err := c.convert(n)
+	if err == nil && n.Anchor != "" {
+		c.anchors[n] = yamlAnchor{v: v, nodes: c.nodes - start}
+	}
+	return v, err
+}
+
+func (c *yamlConverter) convert(n *yaml.Node) (Value, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return Nil(), nil
+		}
+		return c.value(n.Content[0])
+	case yaml.AliasNode:
+		if c.expanding[n] {
+			return Value{}, fmt.Errorf("%w: line %d: alias *%s refers to itself", ErrInvalidYAML, n.Line, n.Value)
+		}
+		c.expanding[n] = true
+		defer delete(c.expanding, n)
+		return c.value(n.Alias)
+	case yaml.SequenceNode:
+		items := make([]Value, 0, len(n.Content))
+		for _, item := range n.Content {
+			v, err := c.value(item)
+			if err != nil {
+				return Value{}, err
+			}
+			items = append(items, v)
+		}
+		return Array(items...), nil
+	case yaml.MappingNode:
+		entries, err := c.mapping(n)
+		if err != nil {
+			return Value{}, err
+		}
+		return Map(entries...), nil
+	case yaml.ScalarNode:
+		return c.scalar(n)
+	}
+	return Value{}, fmt.Errorf("%w: line %d: unknown node kind %d", ErrInvalidYAML, n.Line, n.Kind)
+}
+
+// mapping 依文件順序轉換 mapping 的 entries。merge key (<<) 的位置放入被
+// 合併的 entries，但 mapping 本身有的 key 優先，多個被合併的 mapping 則以
+// 前面的優先，與 yaml.v3 相同
+func (c *yamlConverter) mapping(n *yaml.Node) ([]Entry, error) {
+	keys := make([]Value, len(n.Content)/2)
+	seen := map[string]bool{}
+	for i := range keys {
+		keyNode := n.Content[2*i]
+		if keyNode.Kind == yaml.ScalarNode && keyNode.ShortTag() == "!!merge" {
+			continue
+		}
+		key, err := c.value(keyNode)
+		if err != nil {
+			return nil, err
+		}
+		b, err := key.MarshalMsgpack()
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+		seen[string(b)] = true
+	}
+	entries := make([]Entry, 0, len(keys))
+	for i, key := range keys {
+		valNode := n.Content[2*i+1]
+		if key.kind == InvalidKind {
+			merged, err := c.merge(valNode)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range merged {
+				b, err := e.Key.MarshalMsgpack()
+				if err != nil {
+					return nil, err
+				}
+				if !seen[string(b)] {
+					seen[string(b)] = true
+					entries = append(entries, e)
+				}
+			}
+			continue
+		}
+		val, err := c.value(valNode)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Key: key, Value: val})
+	}
+	return entries, nil
+}
+
+// merge 回傳 merge key 的值，一個 mapping 或 mapping 的 sequence，的所有
+// entries
+func (c *yamlConverter) merge(n *yaml.Node) ([]Entry, error) {
+	v, err := c.value(n)
+	if err != nil {
+		return nil, err
+	}
+	switch v.kind {
+	case MapKind:
+		return v.entries, nil
+	case ArrayKind:
+		entries := []Entry{}
+		for _, item := range v.items {
+			if item.kind != MapKind {
+				return nil, fmt.Errorf("%w: line %d: merge of a %s", ErrInvalidYAML, n.Line, item.kind)
+			}
+			entries = append(entries, item.entries...)
+		}
+		return entries, nil
+	}
+	return nil, fmt.Errorf("%w: line %d: merge of a %s", ErrInvalidYAML, n.Line, v.kind)
+}
+
+func (c *yamlConverter) scalar(n *yaml.Node) (Value, error) {
+	switch n.ShortTag() {
+	case "!!null":
+		return Nil(), nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return Value{}, fmt.Errorf("%w: %v", ErrInvalidYAML, err)
+		}
+		return Bool(b), nil
+	case "!!int":
+		var i int64
+		if err := n.Decode(&i); err == nil {
+			if i < 0 {
+				return Int(i), nil
+			}
+			return Uint(uint64(i)), nil
+		}
+		var u uint64
+		if err := n.Decode(&u); err != nil {
+			return Value{}, fmt.Errorf("%w: line %d: %s", ErrBigNumber, n.Line, n.Value)
+		}
+		return Uint(u), nil
+	case "!!float":
+		var f float64
+		if err := n.Decode(&f); err != nil {
+			return Value{}, fmt.Errorf("%w: %v", ErrInvalidYAML, err)
+		}
+		return Float64(f), nil
+	case "!!binary":
+		// 多行的 base64 會被折成以空白分隔，解碼前先移除所有空白
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(n.Value), ""))
+		if err != nil {
+			return Value{}, fmt.Errorf("%w: line %d: !!binary: %v", ErrInvalidYAML, n.Line, err)
+		}
+		return Bin(b), nil
+	case "!!timestamp":
+		// 與 yaml.v3 相同，沒有明確標記的時間仍視為字串
+		if n.Style&yaml.TaggedStyle == 0 {
+			return Str(n.Value), nil
+		}
+		var t time.Time
+		if err := n.Decode(&t); err != nil {
+			return Value{}, fmt.Errorf("%w: %v", ErrInvalidYAML, err)
+		}
+		return Timestamp(t), nil
+	}
+	return Str(n.Value), nil
+}
+
+func scalarNode(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+func (v Value) yamlNode() (*yaml.Node, error) {
+	switch v.kind {
+	case NilKind:
+		return scalarNode("!!null", "null"), nil
+	case BoolKind:
+		return scalarNode("!!bool", strconv.FormatBool(v.num == 1)), nil
+	case IntKind:
+		return scalarNode("!!int", strconv.FormatInt(int64(v.num), 10)), nil
+	case UintKind:
+		return scalarNode("!!int", strconv.FormatUint(v.num, 10)), nil
+	case Float32Kind, Float64Kind:
+		f, _ := v.Float()
+		return scalarNode("!!float", yamlFloat(f, v.kind)), nil
+	case StrKind:
+		if !utf8.ValidString(v.str) {
+			return nil, fmt.Errorf("%w: str is not valid UTF-8", ErrNotYAMLCompatible)
+		}
+		n := scalarNode("!!str", v.str)
+		if v.str == "<<" {
+			// yaml.v3 不會為 << 加上引號，讀回時會變成 merge key
+			n.Style = yaml.DoubleQuotedStyle
+		}
+		return n, nil
+	case BinKind:
+		return scalarNode("!!binary", base64.StdEncoding.EncodeToString(v.bytes)), nil
+	case ExtKind:
+		t, ok := v.Time()
+		if !ok {
+			return nil, fmt.Errorf("%w: ext type %d", ErrNotYAMLCompatible, v.extType)
+		}
+		// 加上明確的 tag，讀回時才會是 timestamp 而非字串
+		n := scalarNode("!!timestamp", t.Format(time.RFC3339Nano))
+		n.Style = yaml.TaggedStyle
+		return n, nil
+	case ArrayKind:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v.items {
+			child, err := item.yamlNode()
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, child)
+		}
+		return n, nil
+	case MapKind:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, e := range v.entries {
+			key, err := e.Key.yamlNode()
+			if err != nil {
+				return nil, err
+			}
+			val, err := e.Value.yamlNode()
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, key, val)
+		}
+		return n, nil
+	}
+	return nil, ErrKindMismatch
+}
+
+// yamlFloat 回傳 YAML 的浮點數，整數值加上 .0 才不會被讀回為 !!int
+func yamlFloat(f float64, kind Kind) string {
+	switch {
+	case math.IsNaN(f):
+		return ".nan"
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	}
+	bits := 64
+	if kind == Float32Kind {
+		bits = 32
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
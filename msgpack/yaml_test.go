@@ -0,0 +1,145 @@
+package msgpack_test
+
+import (
+	"fmt"
+	. "msgpackconv/msgpack"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFromYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"empty document", "", `nil`},
+		{"scalars", "[~, null, true, no, 1, -2, 0x1f, 1_000, 1.5, .inf, -.inf, hi, '1']",
+			`[nil, nil, true, "no", 1, -2, 31, 1000, 1.5, Infinity, -Infinity, "hi", "1"]`},
+		{"uint64", "18446744073709551615", `18446744073709551615`},
+		{"int beyond 64 bits", "18446744073709551616", `18446744073709552000.0`},
+		{"key order", "b: 1\na: 2\nc: 3\n", `{"b": 1, "a": 2, "c": 3}`},
+		{"non-string keys", "1: one\ntrue: yes\n~: none\n[a, b]: seq\n",
+			`{1: "one", true: "yes", nil: "none", ["a", "b"]: "seq"}`},
+		{"binary", "!!binary aGVsbG8=", `h'68656c6c6f'`},
+		{"folded binary", "b: !!binary |\n  aGVs\n  bG8=\n", `{"b": h'68656c6c6f'}`},
+		{"plain timestamp", "2001-12-14", `"2001-12-14"`},
+		{"tagged timestamp", "!!timestamp 2001-12-14T21:59:43.1Z", `ext(-1, h'17d784003c1a764f')`},
+		{"alias", "a: &x [1]\nb: *x\n", `{"a": [1], "b": [1]}`},
+		{"merge", "base: &b {x: 1, y: 2}\nv:\n  y: 3\n  <<: *b\n  z: 4\n",
+			`{"base": {"x": 1, "y": 2}, "v": {"y": 3, "x": 1, "z": 4}}`},
+		{"merge list", "- &a {x: 1}\n- &b {x: 2, y: 2}\n- <<: [*a, *b]\n",
+			`[{"x": 1}, {"x": 2, "y": 2}, {"x": 1, "y": 2}]`},
+		{"quoted merge key", "'<<': 1", `{"<<": 1}`},
+		{"custom tag", "!color red", `"red"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromYAML([]byte(tt.yaml))
+			assert.NoError(t, err)
+			diag, err := ToDiagnostic(got)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, diag)
+		})
+	}
+}
+
+func TestFromYAMLFail(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  error
+	}{
+		{"syntax", "a: [1", ErrInvalidYAML},
+		{"recursive alias", "&a [*a]", ErrInvalidYAML},
+		{"bad binary", "!!binary '*'", ErrInvalidYAML},
+		{"merge of scalar", "<<: 1", ErrInvalidYAML},
+		{"big tagged int", "!!int 18446744073709551616", ErrBigNumber},
+		{"excessive aliasing", nestedAliases(7), ErrInvalidYAML},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromYAML([]byte(tt.yaml))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+// nestedAliases 產生 levels 層的 anchor，每層以 10 個 alias 參照上一層
+func nestedAliases(levels int) string {
+	var b strings.Builder
+	b.WriteString("a0: &a0 [x]\n")
+	for i := 1; i <= levels; i++ {
+		fmt.Fprintf(&b, "a%d: &a%d [", i, i)
+		for j := range 10 {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "*a%d", i-1)
+		}
+		b.WriteString("]\n")
+	}
+	return b.String()
+}
+
+func TestFromYAMLAliasesShared(t *testing.T) {
+	// 展開後的 node 數量在上限內時，alias 正常展開
+	got, err := FromYAML([]byte(nestedAliases(3)))
+	assert.NoError(t, err)
+	v, err := DecodeValue(got)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, v.Get("a3").Len())
+	assert.Equal(t, Str("x"), v.Get("a3").Index(9).Index(9).Index(9).Index(0))
+}
+
+func TestToYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		diag string
+		want string
+	}{
+		{"scalars", `[nil, true, 1, -2, 1.0, 1.5, -Infinity, "1", "a: b"]`,
+			"- null\n- true\n- 1\n- -2\n- 1.0\n- 1.5\n- -.inf\n- \"1\"\n- 'a: b'\n"},
+		{"key order", `{"b": 1, "a": {}}`, "b: 1\na: {}\n"},
+		{"merge key as str", `{"<<": 1}`, "\"<<\": 1\n"},
+		{"non-string keys", `{1: "one", ["a"]: nil}`, "1: one\n?   - a\n: null\n"},
+		{"bin", `h'68656c6c6f'`, "!!binary aGVsbG8=\n"},
+		{"timestamp", `ext(-1, h'17d784003c1a764f')`, "!!timestamp 2001-12-14T21:59:43.1Z\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgpackconv, err := FromDiagnostic(tt.diag)
+			assert.NoError(t, err)
+			got, err := ToYAML(msgpackconv)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+
+			back, err := FromYAML(got)
+			assert.NoError(t, err)
+			diag, err := ToDiagnostic(back)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.diag, diag)
+		})
+	}
+
+	_, err := ToYAML([]byte{0xd4, 0x05, 0x01})
+	assert.ErrorIs(t, err, ErrNotYAMLCompatible)
+	_, err = ToYAML([]byte{0xa1, 0xff})
+	assert.ErrorIs(t, err, ErrNotYAMLCompatible)
+}
+
+func TestValueYAML(t *testing.T) {
+	var config struct {
+		Name  string `yaml:"name"`
+		Extra Value  `yaml:"extra"`
+	}
+	assert.NoError(t, yaml.Unmarshal([]byte("name: a\nextra: {z: 1, a: !!binary AQ==}\n"), &config))
+	assert.Equal(t, Map(Pair("z", Uint(1)), Pair("a", Bin([]byte{1}))), config.Extra)
+
+	out, err := yaml.Marshal(config)
+	assert.NoError(t, err)
+	assert.Equal(t, "name: a\nextra:\n    z: 1\n    a: !!binary AQ==\n", string(out))
+}